@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/grins/parkranger/internal/config"
+	"github.com/grins/parkranger/internal/engine"
+	"github.com/grins/parkranger/internal/mux"
+	"github.com/grins/parkranger/internal/session"
+)
+
+var (
+	configMu     sync.Mutex
+	configCache  = make(map[string]config.Config)          // main root → merged config
+	profileCache = make(map[string][]session.AgentProfile) // main root → agent profiles
+	repoRoots    = make(map[string]string)                 // repo name → main root
+)
+
+// repoConfig returns the merged config for the repo at root ("" for global
+// only). A broken config file is reported once and defaults are used, so a
+// typo never locks the user out of the dashboard.
+func repoConfig(root string) config.Config {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	if cfg, ok := configCache[root]; ok {
+		return cfg
+	}
+	cfg, err := config.Load(root)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: config: %v\n(using defaults; run 'parkranger config validate' for details)\n", err)
+		cfg = config.Default()
+	}
+	for _, w := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "warning: config: %s\n", w)
+	}
+	configCache[root] = cfg
+	return cfg
+}
+
+// repoProfiles returns the agent profiles for the repo at root, the one new
+// windows launch first.
+func repoProfiles(root string) []session.AgentProfile {
+	cfg := repoConfig(root)
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	if p, ok := profileCache[root]; ok {
+		return p
+	}
+	// Validation already compiled the rules, so this only fails on a config
+	// that repoConfig replaced with defaults anyway.
+	profiles, err := session.Profiles(cfg)
+	if err != nil {
+		profiles = session.DefaultProfiles()
+	}
+	profileCache[root] = profiles
+	return profiles
+}
+
+// namedRepoProfiles returns the agent profiles for a repo by name.
+func namedRepoProfiles(name string) []session.AgentProfile {
+	configMu.Lock()
+	root := repoRoots[name]
+	configMu.Unlock()
+	return repoProfiles(root)
+}
+
+// resetConfig drops cached configs so the next lookup rereads the files.
+func resetConfig() {
+	configMu.Lock()
+	clear(configCache)
+	clear(profileCache)
+	configMu.Unlock()
+}
+
+// dashboardConfig returns the config for the repo containing the current
+// directory, or the global config outside a repo. The dashboard spans repos,
+// so this is what its key bindings come from.
+func dashboardConfig() config.Config {
+	root, err := cwdRepoRoot()
+	if err != nil {
+		root = ""
+	}
+	return repoConfig(root)
+}
+
+// rememberRepo records a repo's root so config can be looked up by name.
+func rememberRepo(name, root string) {
+	configMu.Lock()
+	repoRoots[name] = root
+	configMu.Unlock()
+}
+
+// namedRepoConfig returns the config for a repo by name.
+func namedRepoConfig(name string) config.Config {
+	configMu.Lock()
+	root := repoRoots[name]
+	configMu.Unlock()
+	return repoConfig(root)
+}
+
+// notifyConfig returns a repo's notification settings.
+func notifyConfig(repo string) config.Notify {
+	return namedRepoConfig(repo).Notify
+}
+
+// newEngine creates a detection engine with the global config's timing and
+// each repo's agent profiles, talking to tmux in control mode when it is the
+// multiplexer.
+func newEngine() *engine.Engine {
+	cfg := repoConfig("")
+	eng := engine.New(cfg.PollInterval.Duration)
+	eng.SetProfiles(namedRepoProfiles)
+	eng.SetDebounce(session.Debounce{Hysteresis: cfg.Hysteresis, QuietAfter: cfg.QuietAfter.Duration})
+	_, tmux := mux.Default().(mux.Tmux)
+	eng.SetControl(tmux)
+	return eng
+}
+
+// useMultiplexer makes the multiplexer named in the global config the
+// default. A bad name was already reported by repoConfig and leaves tmux.
+func useMultiplexer() {
+	if m, err := mux.New(repoConfig("").Multiplexer); err == nil {
+		mux.SetDefault(m)
+	}
+}
+
+// cmdConfig handles `parkranger config show|edit|validate`.
+func cmdConfig(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: parkranger config show|edit|validate")
+	}
+
+	// Outside a repo only the global file applies
+	root, err := cwdRepoRoot()
+	if err != nil {
+		root = ""
+	}
+
+	switch args[0] {
+	case "show":
+		cfg, err := config.Load(root)
+		if err != nil {
+			return err
+		}
+		for _, path := range config.Paths(root) {
+			note := ""
+			if _, err := os.Stat(path); err != nil {
+				note = " (not found)"
+			}
+			fmt.Printf("# %s%s\n", path, note)
+		}
+		if n := len(cfg.Rules.Rules); n > 0 || !cfg.Rules.KeepBuiltin() {
+			note := ""
+			if !cfg.Rules.KeepBuiltin() {
+				note = ", built-in rules off"
+			}
+			fmt.Printf("# %s (%d rules%s)\n", config.RulesPath(), n, note)
+		}
+		fmt.Println()
+		return cfg.Encode(os.Stdout)
+
+	case "edit":
+		path := config.GlobalPath()
+		if root != "" && !slices.Contains(args[1:], "--global") {
+			path = config.RepoPath(root)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		// Run through the shell so editors with flags ("code --wait") work
+		cmd := exec.Command("sh", "-c", `$PARKRANGER_EDITOR "$1"`, "sh", path)
+		cmd.Env = append(os.Environ(), "PARKRANGER_EDITOR="+repoConfig(root).EditorCommand())
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("editor: %w", err)
+		}
+		return validateConfig(root)
+
+	case "validate":
+		return validateConfig(root)
+
+	default:
+		return fmt.Errorf("unknown config command: %s", args[0])
+	}
+}
+
+// validateConfig loads and checks the config for root, printing every problem.
+func validateConfig(root string) error {
+	cfg, err := config.Load(root)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		problems := strings.Split(err.Error(), "\n")
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "  %s\n", p)
+		}
+		return fmt.Errorf("config has %d problem(s)", len(problems))
+	}
+	for _, w := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "  warning: %s\n", w)
+	}
+	fmt.Printf("Config OK (%s)\n", strings.Join(config.Paths(root), ", "))
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/grins/parkranger/internal/daemon"
+	"github.com/grins/parkranger/internal/engine"
+	"github.com/grins/parkranger/internal/notify"
+	"github.com/grins/parkranger/internal/session"
+)
+
+// statusSource is where the dashboard reads live state from: the in-process
+// engine, or a daemon mirror when `parkranger daemon` is running.
+type statusSource interface {
+	State(key string) (engine.State, bool)
+	Refresh()
+}
+
+// repoTargets returns one engine target per worktree across repos.
+func repoTargets(repos []repoInfo) []engine.Target {
+	var targets []engine.Target
+	for _, r := range repos {
+		for _, wt := range r.wts {
+			targets = append(targets, engine.Target{Repo: r.name, Worktree: wt.Name, Path: wt.Path, Dirty: wt.Dirty})
+		}
+	}
+	return targets
+}
+
+// dialDaemon returns a client for the running daemon, or nil if none answers.
+func dialDaemon() *daemon.Client {
+	c := daemon.NewClient(daemon.SocketPath())
+	if err := c.Ping(context.Background()); err != nil {
+		return nil
+	}
+	return c
+}
+
+// cmdDaemon runs the detection loop in the foreground and serves it on the
+// daemon socket until interrupted.
+func cmdDaemon() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	eng := newEngine()
+	if st := loadStore(); st != nil {
+		eng.SetStore(st)
+	}
+	notes, _ := eng.Subscribe(64)
+	go notify.New(notifyConfig).Run(notes)
+	agentEvents, _ := eng.Subscribe(64)
+	go runAgentHooks(agentEvents)
+
+	srv := daemon.NewServer(eng, func() ([]engine.Target, error) {
+		// Pick up config edits along with new repos and worktrees
+		resetConfig()
+		repos, err := dashboardRepos()
+		if err != nil {
+			return nil, err
+		}
+		return repoTargets(repos), nil
+	})
+	srv.SetHooks(&hookRunner)
+
+	socket := daemon.SocketPath()
+	fmt.Printf("parkranger daemon listening on %s\n", socket)
+	err := srv.Serve(ctx, socket)
+	if errors.Is(err, daemon.ErrRunning) {
+		return fmt.Errorf("%w on %s", err, socket)
+	}
+	return err
+}
+
+// pollStates returns the live state of every target, from the daemon if one
+// is running (telling it about any targets it isn't watching yet), otherwise
+// from a one-off local engine poll.
+func pollStates(targets []engine.Target) map[string]engine.State {
+	states := make(map[string]engine.State, len(targets))
+
+	if c := dialDaemon(); c != nil {
+		ctx := context.Background()
+		if err := c.Watch(ctx, targets); err == nil {
+			if remote, err := c.State(ctx, false); err == nil {
+				for _, st := range remote {
+					states[st.Key()] = st
+				}
+				return states
+			}
+		}
+	}
+
+	eng := engine.New(0)
+	eng.SetProfiles(namedRepoProfiles)
+	eng.SetTargets(targets)
+	eng.Poll()
+	for _, st := range eng.Snapshot() {
+		states[st.Key()] = st
+	}
+	return states
+}
+
+// cmdStatus prints a one-line agent summary for tmux status bars, e.g.
+// "2 waiting · 1 busy". Prints nothing when no agent is running.
+func cmdStatus() error {
+	repos, err := dashboardRepos()
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[session.AgentStatus]int)
+	for _, st := range pollStates(repoTargets(repos)) {
+		if st.Live.HasClaude {
+			counts[st.Live.Status]++
+		}
+	}
+
+	var parts []string
+	for _, s := range []session.AgentStatus{session.StatusWaiting, session.StatusError, session.StatusExited, session.StatusBusy, session.StatusIdle} {
+		if n := counts[s]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, s))
+		}
+	}
+	if len(parts) > 0 {
+		fmt.Println(strings.Join(parts, " · "))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/grins/parkranger/internal/config"
+	"github.com/grins/parkranger/internal/mux"
+	"github.com/grins/parkranger/internal/session"
+)
+
+// cmdDetect handles `parkranger detect [--explain] <target>`: it classifies
+// the agent panes of a worktree window, or a single tmux pane ("%12",
+// "pr-app:feat.1"), and with --explain shows which rule fired.
+func cmdDetect(args []string) error {
+	explain := slices.Contains(args, "--explain")
+	args = slices.DeleteFunc(slices.Clone(args), func(a string) bool { return a == "--explain" })
+	if len(args) != 1 {
+		return fmt.Errorf("usage: parkranger detect [--explain] <worktree|pane>")
+	}
+	target := args[0]
+
+	var explanations []session.Explanation
+	if strings.HasPrefix(target, "%") || strings.Contains(target, ":") {
+		// A raw pane is read as the default agent of the current repo
+		root, _ := cwdRepoRoot()
+		e, err := session.ExplainPane(target, repoProfiles(root)[0])
+		if err != nil {
+			return err
+		}
+		explanations = append(explanations, e)
+	} else {
+		r, wt, err := resolveWorktree(target)
+		if err != nil {
+			return err
+		}
+		m := mux.Default()
+		explanations, err = session.ExplainWindow(m.SessionName(r.name), m.WindowName(wt.Name), repoProfiles(r.root)...)
+		if err != nil {
+			return err
+		}
+		if len(explanations) == 0 {
+			fmt.Printf("No agent panes in %s/%s\n", r.name, wt.Name)
+			return nil
+		}
+	}
+
+	for _, e := range explanations {
+		fmt.Printf("%s (%s): %s\n", e.PaneID, e.Agent, e.Status)
+		if explain {
+			printMatch(e)
+		}
+	}
+	return nil
+}
+
+// printMatch describes the rule behind an explanation.
+func printMatch(e session.Explanation) {
+	m := e.Match
+	switch {
+	case !e.Rules:
+		fmt.Println("  classified by the agent's own logic, not by rules")
+		return
+	case m.Index < 0:
+		fmt.Println("  no rule matched (while the screen keeps changing, the dashboard reads it as busy)")
+		return
+	}
+	agent := ""
+	if !m.HasAgent {
+		agent = ", agent UI not shown"
+	}
+	fmt.Printf("  rule %d %q → %s%s\n", m.Index, m.Rule, m.Status, agent)
+	for i, c := range m.When {
+		fmt.Printf("    %s matched %q\n", describeCondition(c), m.Evidence[i])
+	}
+}
+
+// describeCondition renders a rule condition: its pattern and region.
+func describeCondition(c config.Condition) string {
+	region := "anywhere"
+	if c.Bottom > 0 {
+		region = fmt.Sprintf("bottom %d", c.Bottom)
+	}
+	var flags []string
+	if c.IgnoreCase {
+		flags = append(flags, "ignore case")
+	}
+	if c.Highlighted {
+		flags = append(flags, "highlighted")
+	}
+	if len(flags) > 0 {
+		region += ", " + strings.Join(flags, ", ")
+	}
+	return fmt.Sprintf("/%s/ (%s)", c.Pattern, region)
+}
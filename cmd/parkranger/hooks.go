@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grins/parkranger/internal/daemon"
+	"github.com/grins/parkranger/internal/engine"
+	"github.com/grins/parkranger/internal/git"
+	"github.com/grins/parkranger/internal/hooks"
+	"github.com/grins/parkranger/internal/session"
+	"github.com/grins/parkranger/internal/worktree"
+)
+
+// hookRunner keeps recent hook results for the dashboard footer.
+var hookRunner hooks.Runner
+
+// hookDisplayWindow is how long hook results stay visible on the dashboard.
+const hookDisplayWindow = 10 * time.Minute
+
+// notifyFailure is the dashboard notifier's latest sink failure, shown in
+// the footer with the hook results. Printing it would tear the dashboard.
+var notifyFailure struct {
+	sync.Mutex
+	msg string
+	at  time.Time
+}
+
+// recordNotifyFailure is the dashboard notifier's OnError.
+func recordNotifyFailure(sink string, err error) {
+	notifyFailure.Lock()
+	defer notifyFailure.Unlock()
+	notifyFailure.msg = fmt.Sprintf("notify %s: ✗ %s", sink, lastLine(err.Error()))
+	notifyFailure.at = time.Now()
+}
+
+// recentNotifyFailure returns the footer line for a notification sink that
+// failed within hookDisplayWindow, or "".
+func recentNotifyFailure() string {
+	notifyFailure.Lock()
+	defer notifyFailure.Unlock()
+	if time.Since(notifyFailure.at) > hookDisplayWindow {
+		return ""
+	}
+	return notifyFailure.msg
+}
+
+// recentHooks returns the hook results for the dashboard footer: this
+// process's and, when state comes from the daemon, the ones the daemon ran.
+func recentHooks(source statusSource) []hooks.Result {
+	results := hookRunner.Recent(3, time.Now().Add(-hookDisplayWindow))
+	if m, ok := source.(*daemon.Mirror); ok {
+		since := time.Now().Add(-hookDisplayWindow)
+		for _, res := range m.HookResults() {
+			if !res.Started.Before(since) {
+				results = append(results, res)
+			}
+		}
+		slices.SortStableFunc(results, func(a, b hooks.Result) int { return a.Started.Compare(b.Started) })
+		if len(results) > 3 {
+			results = results[len(results)-3:]
+		}
+	}
+	return results
+}
+
+// runHooks runs the repo's hooks for ev on wt and prints their results.
+func runHooks(repoName, mainRoot string, wt *worktree.Worktree, ev hooks.Event, sessionID string) {
+	cfg := repoConfig(mainRoot).Hooks
+	if len(hooks.Commands(cfg, ev)) == 0 {
+		return
+	}
+
+	results := hookRunner.Run(cfg, hooks.Payload{
+		Event:     ev,
+		Repo:      repoName,
+		RepoPath:  mainRoot,
+		Worktree:  wt.Name,
+		Path:      wt.Path,
+		Branch:    wt.Branch,
+		SessionID: sessionID,
+	})
+	for _, res := range results {
+		fmt.Println(formatHookResult(res))
+		if res.Output != "" {
+			for _, line := range strings.Split(res.Output, "\n") {
+				fmt.Println("  " + line)
+			}
+		}
+	}
+}
+
+// runAgentHooks fires agent_waiting hooks for every transition into waiting
+// until events is closed. Hooks run in the background so a slow command
+// never delays the next event.
+func runAgentHooks(events <-chan engine.Event) {
+	for ev := range events {
+		if ev.Kind != engine.EventStatusChanged || ev.State.Live.Status != session.StatusWaiting {
+			continue
+		}
+
+		cfg := namedRepoConfig(ev.State.Repo)
+		if len(hooks.Commands(cfg.Hooks, hooks.AgentWaiting)) == 0 {
+			continue
+		}
+
+		p := hooks.Payload{
+			Event:    hooks.AgentWaiting,
+			Repo:     ev.State.Repo,
+			Worktree: ev.State.Worktree,
+			Path:     ev.State.Path,
+			Status:   ev.State.Live.Status.String(),
+		}
+		configMu.Lock()
+		p.RepoPath = repoRoots[p.Repo]
+		configMu.Unlock()
+		if branch, err := git.CurrentBranch(p.Path); err == nil {
+			p.Branch = branch
+		}
+		if st := loadStore(); st != nil {
+			if rec, ok := st.Get(p.Path); ok {
+				p.SessionID = rec.SessionID
+			}
+		}
+
+		go hookRunner.Run(cfg.Hooks, p)
+	}
+}
+
+// formatHookResult renders a one-line summary, e.g.
+// "hook worktree_created: npm install ✓ 3.2s".
+func formatHookResult(res hooks.Result) string {
+	mark := "✓"
+	if res.Err != nil {
+		mark = "✗ " + res.Err.Error()
+	}
+	return fmt.Sprintf("hook %s: %s %s %s", res.Event, res.Command, mark, res.Duration.Round(100*time.Millisecond))
+}
+
+// lastLine returns the last non-empty line of s.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
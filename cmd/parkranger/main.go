@@ -1,18 +1,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 
+	"github.com/grins/parkranger/internal/config"
+	"github.com/grins/parkranger/internal/daemon"
+	"github.com/grins/parkranger/internal/engine"
 	"github.com/grins/parkranger/internal/git"
+	"github.com/grins/parkranger/internal/hooks"
+	"github.com/grins/parkranger/internal/layout"
+	"github.com/grins/parkranger/internal/mux"
+	"github.com/grins/parkranger/internal/notify"
 	"github.com/grins/parkranger/internal/session"
-	"github.com/grins/parkranger/internal/tmux"
+	"github.com/grins/parkranger/internal/store"
 	"github.com/grins/parkranger/internal/worktree"
 )
 
@@ -25,6 +35,12 @@ func main() {
 
 func run() error {
 	args := os.Args[1:]
+	defer session.SaveIndex() // best effort: it is only a cache
+
+	// config and help must work with a broken config, without warnings
+	if len(args) == 0 || !slices.Contains([]string{"config", "help", "-h", "--help"}, args[0]) {
+		useMultiplexer()
+	}
 
 	if len(args) == 0 {
 		return interactive()
@@ -53,6 +69,20 @@ func run() error {
 			return fmt.Errorf("usage: parkranger delete <name>")
 		}
 		return cmdDelete(args[1])
+	case "repo":
+		return cmdRepo(args[1:])
+	case "config":
+		return cmdConfig(args[1:])
+	case "detect":
+		return cmdDetect(args[1:])
+	case "record":
+		return cmdRecord(args[1:])
+	case "usage":
+		return cmdUsage(args[1:])
+	case "daemon":
+		return cmdDaemon()
+	case "status":
+		return cmdStatus()
 	case "help", "-h", "--help":
 		printUsage()
 		return nil
@@ -65,40 +95,72 @@ func printUsage() {
 	fmt.Print(`parkranger — manage parallel worktree + tmux sessions
 
 Usage:
-  parkranger              interactive picker
+  parkranger              interactive dashboard (all registered repos)
   parkranger ls           list worktrees with status
   parkranger open <name>  open/attach tmux session for worktree
   parkranger new <name>   create worktree + open session
   parkranger merge <name> merge worktree branch into default branch
   parkranger delete <name> kill session + remove worktree
+  parkranger repo add <path>   register a repo with the dashboard
+  parkranger repo rm <name>    unregister a repo
+  parkranger repo ls           list registered repos
+  parkranger config show       print the merged config for this repo
+  parkranger config edit       edit .parkranger.toml (--global: the global file)
+  parkranger config validate   check config files for mistakes
+  parkranger detect <target>   classify a worktree's agent panes (or one tmux
+                               pane); --explain shows which rule fired
+  parkranger record <target>   record an agent pane as a replay test fixture
+  parkranger usage        token usage and estimated cost of Claude sessions;
+                          --since 7d, --by repo|worktree|day
+  parkranger daemon       run the detection loop in the background, serving
+                          state on a Unix socket for ls, the dashboard, etc.
+  parkranger status       one-line agent summary (for tmux status bars)
+
+Worktree names may be qualified as <repo>/<name> to target a registered
+repo from anywhere; otherwise the repo containing the current directory is used.
 `)
 }
 
-// resolveRepo detects the repo from CWD and returns (mainRoot, repoName, worktrees).
-func resolveRepo() (mainRoot, repoName string, wts []worktree.Worktree, err error) {
-	cwd, err := os.Getwd()
+// repoInfo is a resolved repository: its main root, name, and worktrees.
+type repoInfo struct {
+	root string
+	name string
+	wts  []worktree.Worktree
+}
+
+// loadRepo lists the worktrees of the repo whose main root is mainRoot.
+func loadRepo(mainRoot string) (repoInfo, error) {
+	wts, err := worktree.List(mainRoot)
 	if err != nil {
-		return "", "", nil, fmt.Errorf("getwd: %w", err)
+		return repoInfo{}, err
 	}
+	name := git.RepoName(mainRoot)
+	rememberRepo(name, mainRoot)
+	return repoInfo{root: mainRoot, name: name, wts: wts}, nil
+}
 
-	root, err := git.RepoRoot(cwd)
+// cwdRepoRoot returns the main repo root for the current directory.
+func cwdRepoRoot() (string, error) {
+	cwd, err := os.Getwd()
 	if err != nil {
-		return "", "", nil, fmt.Errorf("not in a git repo")
+		return "", fmt.Errorf("getwd: %w", err)
 	}
 
-	mainRoot, err = git.MainRepoRoot(root)
+	root, err := git.RepoRoot(cwd)
 	if err != nil {
-		return "", "", nil, err
+		return "", fmt.Errorf("not in a git repo")
 	}
 
-	repoName = git.RepoName(mainRoot)
+	return git.MainRepoRoot(root)
+}
 
-	wts, err = worktree.List(mainRoot)
+// resolveRepo detects the repo from CWD.
+func resolveRepo() (repoInfo, error) {
+	mainRoot, err := cwdRepoRoot()
 	if err != nil {
-		return "", "", nil, err
+		return repoInfo{}, err
 	}
-
-	return mainRoot, repoName, wts, nil
+	return loadRepo(mainRoot)
 }
 
 // --- Session picker (Bubble Tea) ---
@@ -119,11 +181,31 @@ type pickerModel struct {
 	quitting  bool
 	width     int
 	height    int
+	viewer    *transcriptView // open transcript of the item under the cursor
 }
 
 func (m pickerModel) Init() tea.Cmd { return nil }
 
 func (m pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.viewer != nil {
+		if size, ok := msg.(tea.WindowSizeMsg); ok {
+			m.width, m.height = size.Width, size.Height
+		}
+		v, cmd := m.viewer.Update(msg)
+		switch {
+		case v.resume:
+			m.chosen = m.items[m.cursor].value
+			m.confirmed = true
+			m.quitting = true
+			return m, tea.Quit
+		case v.done:
+			m.viewer = nil
+		default:
+			m.viewer = &v
+		}
+		return m, cmd
+	}
+
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -138,6 +220,12 @@ func (m pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.cursor < len(m.items)-1 {
 				m.cursor++
 			}
+		case "v":
+			if s := m.items[m.cursor].session; s != nil && s.Path != "" {
+				v, load := newTranscriptView(s.Path, m.width, m.height)
+				m.viewer = &v
+				return m, load
+			}
 		case "enter":
 			m.chosen = m.items[m.cursor].value
 			m.confirmed = true
@@ -163,6 +251,9 @@ func (m pickerModel) View() string {
 	if m.quitting {
 		return ""
 	}
+	if m.viewer != nil {
+		return m.viewer.View()
+	}
 
 	var b strings.Builder
 
@@ -224,16 +315,19 @@ func (m pickerModel) renderPreview(item pickerItem, width, maxLines int) string
 
 	// New session
 	if item.session == nil {
-		return pickerHeaderStyle.Render("[n] New session") + "\n\n" + "Start a fresh Claude session"
+		return pickerHeaderStyle.Render("[n] New session") + "\n\n" + "Start a fresh agent session"
 	}
 
 	// Session preview
 	s := item.session
-	header := s.ID[:8]
+	header := shortID(s.ID)
 	if s.GitBranch != "" {
 		header += " · " + s.GitBranch
 	}
 	header += " · " + formatAge(s.ModTime)
+	if s.Path != "" {
+		header += " · v transcript"
+	}
 
 	var body string
 	if s.FullPrompt != "" {
@@ -303,16 +397,31 @@ func wordWrap(s string, width int) string {
 	return result.String()
 }
 
+// shortID abbreviates a session ID for lists. Claude's are UUIDs; other
+// agents' may be shorter than the abbreviation.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
 // sessionPicker shows a session picker for the worktree and returns a choice:
-//   - "live"         → attach to existing tmux window
-//   - "<session-id>" → resume with claude --resume <id>
-//   - ""             → start bare claude
+//   - "live"         → attach to existing window
+//   - "<session-id>" → resume it with the repo's agent
+//   - ""             → start a fresh agent session
 func sessionPicker(repoName string, wt *worktree.Worktree) (string, error) {
-	sessName := tmux.SessionName(repoName)
-	winName := tmux.WindowName(wt.Name)
-	live := session.DetectLive(sessName, winName)
-
-	sessions, _ := session.ListSessions(wt.Path)
+	mx := mux.Default()
+	sessName := mx.SessionName(repoName)
+	winName := mx.WindowName(wt.Name)
+	profiles := namedRepoProfiles(repoName)
+	live := session.DetectLive(sessName, winName, profiles...)
+
+	// Past sessions are only offered if the agent can resume them
+	sessions, _ := profiles[0].Sessions(wt.Path)
+	sessions = slices.DeleteFunc(sessions, func(s session.Session) bool {
+		return profiles[0].ResumeCommand(s.ID) == ""
+	})
 
 	// Nothing to pick from — skip picker
 	if !live.Exists && len(sessions) == 0 {
@@ -339,13 +448,20 @@ func sessionPicker(repoName string, wt *worktree.Worktree) (string, error) {
 		})
 	}
 
+	// The session last resumed in this window (persisted across runs)
+	boundID := boundSession(wt.Path)
+
+	price := namedRepoConfig(repoName).PriceFor
 	for i, s := range sessions {
 		age := formatAge(s.ModTime)
 		prompt := s.FirstPrompt
 		if prompt == "" {
-			prompt = s.ID[:8]
+			prompt = shortID(s.ID)
+		}
+		label := fmt.Sprintf("%s  %-40s  %-9s  %s", shortID(s.ID), prompt, age, formatCost(sessionUsage(s, time.Time{}, price)))
+		if s.ID == boundID {
+			label += "  (last)"
 		}
-		label := fmt.Sprintf("%s  %-40s  %s", s.ID[:8], prompt, age)
 		items = append(items, pickerItem{
 			label:   label,
 			value:   s.ID,
@@ -377,35 +493,67 @@ func sessionPicker(repoName string, wt *worktree.Worktree) (string, error) {
 
 // openSession creates (if needed) and attaches to a tmux window for the worktree.
 func openSession(repoName, mainRoot string, wt *worktree.Worktree) error {
-	sessName := tmux.SessionName(repoName)
-	winName := tmux.WindowName(wt.Name)
-	winTarget := tmux.WindowTarget(repoName, wt.Name)
-
 	choice, err := sessionPicker(repoName, wt)
 	if err != nil {
 		return err
 	}
+	return startSession(repoName, mainRoot, wt, choice)
+}
+
+// startSession acts on the session picker's choice: "live" attaches to the
+// worktree's window, "" starts a fresh agent session and anything else
+// resumes that session, creating the window from the layout if needed.
+func startSession(repoName, mainRoot string, wt *worktree.Worktree, choice string) error {
+	m := mux.Default()
+	sessName := m.SessionName(repoName)
+	winName := m.WindowName(wt.Name)
+	winTarget := sessName + ":" + winName
 
 	// Live window — just attach
 	if choice == "live" {
+		runHooks(repoName, mainRoot, wt, hooks.SessionOpened, boundSession(wt.Path))
 		fmt.Printf("Attaching to %s\n", winTarget)
-		return tmux.AttachWindow(sessName, winName)
+		return m.AttachWindow(sessName, winName)
+	}
+
+	cfg := repoConfig(mainRoot)
+	agent := repoProfiles(mainRoot)[0]
+	agentCmd := agent.LaunchCommand()
+	if choice != "" {
+		agentCmd = agent.ResumeCommand(choice)
+		if agentCmd == "" {
+			return fmt.Errorf("%s can't resume sessions", agent.Name())
+		}
+	}
+
+	// Remember which Claude session runs in this window ("" = fresh session)
+	if st := loadStore(); st != nil {
+		st.BindSession(wt.Path, repoName, wt.Name, winTarget, choice)
+		if err := st.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: could not save state: %v\n", err)
+		}
 	}
 
 	// Window already exists but user picked a resume/new option
-	if tmux.WindowExists(sessName, winName) {
-		claudeCmd := "claude"
-		if choice != "" {
-			claudeCmd = fmt.Sprintf("claude --resume %s", choice)
+	if m.WindowExists(sessName, winName) {
+		// Without a tagged agent pane, the agent goes to the second pane
+		var target string
+		if agents := mux.AgentPanes(m, sessName, winName); len(agents) > 0 {
+			target = agents[0]
+		} else if panes := m.ListPanes(sessName, winName); len(panes) > 1 {
+			target = panes[1].ID
+		} else if len(panes) == 1 {
+			target = panes[0].ID
 		}
-		if err := tmux.SendKeys(winTarget+".1", claudeCmd); err != nil {
+		if err := m.SendKeys(target, agentCmd); err != nil {
 			return err
 		}
-		return tmux.AttachWindow(sessName, winName)
+		runHooks(repoName, mainRoot, wt, hooks.SessionOpened, choice)
+		return m.AttachWindow(sessName, winName)
 	}
 
 	// Ensure the repo-level session exists (with dashboard window 0)
-	created, err := tmux.EnsureSession(repoName, mainRoot)
+	created, err := m.EnsureSession(sessName, mainRoot)
 	if err != nil {
 		return err
 	}
@@ -413,105 +561,163 @@ func openSession(repoName, mainRoot string, wt *worktree.Worktree) error {
 	// Launch parkranger in the dashboard window so Ctrl-b 0 shows the TUI
 	if created {
 		if exe, err := os.Executable(); err == nil {
-			_ = tmux.SendKeys(sessName+":dashboard", exe)
+			if panes := m.ListPanes(sessName, mux.DashboardWindow); len(panes) > 0 {
+				_ = m.SendKeys(panes[0].ID, exe)
+			}
 		}
 	}
 
-	// Create new window for this worktree
+	// Create the worktree window (and any extra windows) from the layout
 	fmt.Printf("Creating window %s in %s\n", winTarget, wt.Path)
-	if err := tmux.CreateWindow(sessName, winName, wt.Path); err != nil {
-		return err
+	vars := layout.Vars{Editor: cfg.EditorCommand(), Agent: agentCmd}
+	if _, err := layout.Open(m, sessName, winName, wt.Path, cfg.SelectedLayout(), vars); err != nil {
+		return fmt.Errorf("layout: %w", err)
 	}
 
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		editor = "nvim"
-	}
+	// Hooks run before attaching: outside tmux, attach replaces this process.
+	runHooks(repoName, mainRoot, wt, hooks.SessionOpened, choice)
+	return m.AttachWindow(sessName, winName)
+}
 
-	// Pane 0: editor with current directory
-	if err := tmux.SendKeys(winTarget+".0", editor+" ."); err != nil {
-		return err
-	}
+// --- Subcommands ---
 
-	// Split and launch claude in pane 1
-	if err := tmux.SplitVertical(winTarget, wt.Path); err != nil {
-		return err
-	}
+// hasExited reports whether any agent pane in the window has exited.
+func hasExited(live session.LiveInfo) bool {
+	return slices.ContainsFunc(live.Panes, func(p session.PaneStatus) bool {
+		return p.Status == session.StatusExited
+	})
+}
 
-	claudeCmd := "claude"
-	if choice != "" {
-		claudeCmd = fmt.Sprintf("claude --resume %s", choice)
+// restartAgent restarts the exited agent panes of a worktree's window,
+// resuming the session bound to the window, or else the latest one. An
+// agent that can't resume starts a fresh session.
+func restartAgent(r repoInfo, wt *worktree.Worktree) error {
+	m := mux.Default()
+	sessName := m.SessionName(r.name)
+	winName := m.WindowName(wt.Name)
+	profiles := repoProfiles(r.root)
+	agent := profiles[0]
+
+	id := boundSession(wt.Path)
+	if id == "" {
+		if sessions, _ := agent.Sessions(wt.Path); len(sessions) > 0 {
+			id = sessions[0].ID
+		}
 	}
-	if err := tmux.SendKeys(winTarget+".1", claudeCmd); err != nil {
-		return err
+	agentCmd := agent.LaunchCommand()
+	if id != "" {
+		if resume := agent.ResumeCommand(id); resume != "" {
+			agentCmd = resume
+		} else {
+			id = ""
+		}
 	}
 
-	return tmux.AttachWindow(sessName, winName)
+	dead := make(map[string]bool)
+	for _, p := range m.ListPanes(sessName, winName) {
+		dead[p.ID] = p.Dead
+	}
+	restarted := 0
+	for _, p := range session.DetectLive(sessName, winName, profiles...).Panes {
+		if p.Status != session.StatusExited {
+			continue
+		}
+		var err error
+		if dead[p.ID] {
+			err = m.RespawnPane(p.ID, wt.Path, agentCmd)
+		} else {
+			err = m.SendKeys(p.ID, agentCmd)
+		}
+		if err != nil {
+			return err
+		}
+		restarted++
+	}
+	if restarted == 0 {
+		return fmt.Errorf("no exited agent in %s/%s", r.name, wt.Name)
+	}
+	// The agent was relaunched: later sessions are its own
+	if st := loadStore(); st != nil {
+		st.BindSession(wt.Path, r.name, wt.Name, sessName+":"+winName, id)
+		st.Save() // best effort: the agent is already running
+	}
+	fmt.Printf("Restarted %s in %s/%s\n", agentCmd, r.name, wt.Name)
+	runHooks(r.name, r.root, wt, hooks.SessionOpened, id)
+	return nil
 }
 
-// --- Subcommands ---
-
 func cmdList() error {
-	_, repoName, wts, err := resolveRepo()
+	repos, err := dashboardRepos()
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf(" %s\n\n", repoName)
+	states := pollStates(repoTargets(repos))
 
-	for _, wt := range wts {
-		status := formatStatus(wt)
-		sessInfo := formatSessionInfo(repoName, wt)
-		marker := "  "
-		if wt.IsMain {
-			marker = "* "
+	for i, r := range repos {
+		if i > 0 {
+			fmt.Println()
 		}
+		fmt.Printf(" %s\n\n", r.name)
+
+		for _, wt := range r.wts {
+			status := formatStatus(wt)
+			st := states[engine.Target{Repo: r.name, Worktree: wt.Name}.Key()]
+			sessInfo := formatSessionInfo(st.Live, wt, repoProfiles(r.root)[0])
+			marker := "  "
+			if wt.IsMain {
+				marker = "* "
+			}
 
-		line := fmt.Sprintf(" %s%-24s", marker, wt.Name)
-		if sessInfo != "" {
-			line += "  " + sessInfo
-		}
-		if status != "" {
-			line += "  " + status
+			line := fmt.Sprintf(" %s%-24s", marker, wt.Name)
+			if sessInfo != "" {
+				line += "  " + sessInfo
+			}
+			if cost := formatCost(worktreeUsage(r.root, wt)); cost != "" {
+				line += "  " + cost
+			}
+			if status != "" {
+				line += "  " + status
+			}
+			fmt.Println(line)
 		}
-		fmt.Println(line)
 	}
 
 	return nil
 }
 
 func cmdOpen(name string) error {
-	mainRoot, repoName, wts, err := resolveRepo()
+	r, wt, err := resolveWorktree(name)
 	if err != nil {
 		return err
 	}
-
-	wt := worktree.FindByName(wts, name)
-	if wt == nil {
-		return fmt.Errorf("worktree %q not found", name)
-	}
-
-	return openSession(repoName, mainRoot, wt)
+	return openSession(r.name, r.root, wt)
 }
 
 func cmdNew(name string) error {
-	mainRoot, repoName, _, err := resolveRepo()
+	r, name, err := resolveRepoArg(name)
 	if err != nil {
 		return err
 	}
+	return newWorktree(r, name)
+}
 
-	baseBranch, err := pickBaseBranch(mainRoot)
+// newWorktree creates a worktree named name in r and opens a session for it.
+func newWorktree(r repoInfo, name string) error {
+	baseBranch, err := pickBaseBranch(r.root)
 	if err != nil {
 		return err
 	}
 
 	fmt.Printf("Creating worktree %q from origin/%s\n", name, baseBranch)
-	wt, err := worktree.Add(mainRoot, name, "origin/"+baseBranch)
+	path := repoConfig(r.root).WorktreePath(r.root, name)
+	wt, err := worktree.AddAt(r.root, path, name, "origin/"+baseBranch)
 	if err != nil {
 		return err
 	}
+	runHooks(r.name, r.root, &wt, hooks.WorktreeCreated, "")
 
-	return openSession(repoName, mainRoot, &wt)
+	return openSession(r.name, r.root, &wt)
 }
 
 // pickBaseBranch shows a picker for remote branches, defaulting to the repo's default branch.
@@ -561,15 +767,16 @@ func pickBaseBranch(mainRoot string) (string, error) {
 }
 
 func cmdMerge(name string) error {
-	mainRoot, _, wts, err := resolveRepo()
+	r, wt, err := resolveWorktree(name)
 	if err != nil {
 		return err
 	}
+	return mergeWorktree(r, wt)
+}
 
-	wt := worktree.FindByName(wts, name)
-	if wt == nil {
-		return fmt.Errorf("worktree %q not found", name)
-	}
+// mergeWorktree merges wt's branch into r's default branch, then offers cleanup.
+func mergeWorktree(r repoInfo, wt *worktree.Worktree) error {
+	mainRoot := r.root
 	if wt.IsMain {
 		return fmt.Errorf("cannot merge the main worktree")
 	}
@@ -595,6 +802,7 @@ func cmdMerge(name string) error {
 	if err := git.MergeBranch(mainRoot, wt.Branch, defaultBranch); err != nil {
 		return err
 	}
+	runHooks(r.name, mainRoot, wt, hooks.MergeCompleted, "")
 
 	// Offer to clean up
 	var cleanup bool
@@ -610,15 +818,13 @@ func cmdMerge(name string) error {
 		return nil
 	}
 
-	sessName := tmux.SessionName(git.RepoName(mainRoot))
-	winName := tmux.WindowName(wt.Name)
-	if tmux.WindowExists(sessName, winName) {
-		tmux.KillWindow(sessName, winName)
-	}
+	killWindows(r, wt)
 
 	if err := worktree.Remove(mainRoot, wt.Path); err != nil {
 		return fmt.Errorf("remove worktree: %w", err)
 	}
+	forgetWorktree(wt.Path)
+	runHooks(r.name, mainRoot, wt, hooks.WorktreeDeleted, "")
 
 	if err := git.DeleteBranch(mainRoot, wt.Branch); err != nil {
 		return fmt.Errorf("delete branch: %w", err)
@@ -628,22 +834,43 @@ func cmdMerge(name string) error {
 	return nil
 }
 
+// killWindows kills wt's window and the extra windows its layout opened.
+// Reports whether the main window existed.
+func killWindows(r repoInfo, wt *worktree.Worktree) bool {
+	m := mux.Default()
+	sessName := m.SessionName(r.name)
+	winName := m.WindowName(wt.Name)
+	for _, w := range repoConfig(r.root).SelectedLayout().Windows {
+		extra := layout.ExtraWindowName(m, winName, w.Name)
+		if m.WindowExists(sessName, extra) {
+			m.KillWindow(sessName, extra)
+		}
+	}
+	if !m.WindowExists(sessName, winName) {
+		return false
+	}
+	m.KillWindow(sessName, winName)
+	return true
+}
+
 func cmdDelete(name string) error {
-	mainRoot, _, wts, err := resolveRepo()
+	r, wt, err := resolveWorktree(name)
 	if err != nil {
 		return err
 	}
+	return deleteWorktree(r, wt)
+}
 
-	wt := worktree.FindByName(wts, name)
-	if wt == nil {
-		return fmt.Errorf("worktree %q not found", name)
-	}
+// deleteWorktree kills wt's tmux window, removes the worktree and its branch.
+func deleteWorktree(r repoInfo, wt *worktree.Worktree) error {
+	mainRoot := r.root
+	name := wt.Name
 	if wt.IsMain {
 		return fmt.Errorf("cannot delete the main worktree")
 	}
 
 	var confirm bool
-	err = huh.NewConfirm().
+	err := huh.NewConfirm().
 		Title(fmt.Sprintf("Delete worktree %q and kill tmux session?", name)).
 		Value(&confirm).
 		Run()
@@ -654,17 +881,17 @@ func cmdDelete(name string) error {
 		return nil
 	}
 
-	sessName := tmux.SessionName(git.RepoName(mainRoot))
-	winName := tmux.WindowName(wt.Name)
-	if tmux.WindowExists(sessName, winName) {
-		fmt.Printf("Killing window %s:%s\n", sessName, winName)
-		tmux.KillWindow(sessName, winName)
+	if killWindows(r, wt) {
+		m := mux.Default()
+		fmt.Printf("Killed window %s:%s\n", m.SessionName(r.name), m.WindowName(wt.Name))
 	}
 
 	fmt.Printf("Removing worktree %s\n", wt.Path)
 	if err := worktree.Remove(mainRoot, wt.Path); err != nil {
 		return err
 	}
+	forgetWorktree(wt.Path)
+	runHooks(r.name, mainRoot, wt, hooks.WorktreeDeleted, "")
 
 	if err := git.DeleteBranch(mainRoot, wt.Branch); err != nil {
 		fmt.Printf("Warning: could not delete branch %s: %v\n", wt.Branch, err)
@@ -678,28 +905,43 @@ func cmdDelete(name string) error {
 // --- Interactive mode ---
 
 type menuChoice struct {
-	action string // "open", "new", "merge", "delete"
-	name   string // worktree name (for open)
+	action string // "open", "new", "merge", "delete", "restart"
+	repo   string // repo the action applies to
+	name   string // worktree name (for open and restart)
 }
 
 type menuItem struct {
-	name     string
-	live     session.LiveInfo
-	sessNum  int
-	ahead    int
-	behind   int
-	dirty    bool
-	isMain   bool
-	choice   menuChoice
-	detector *session.Detector // stateful detector for hash-based change tracking
+	key     string // engine target key: "<repo>/<worktree>"
+	repo    string // repo name — items are grouped by repo in the view
+	root    string // repo main worktree, for its config
+	name    string
+	path    string
+	live    session.LiveInfo
+	sessNum int
+	active  time.Time // last write to any of its sessions
+	ahead   int
+	behind  int
+	dirty   bool
+	isMain  bool
+	cost    string // estimated cost of the worktree's sessions
+	choice  menuChoice
+	last    store.Record // persisted state, shown when the window is gone
 }
 
-// tickMsg triggers a poll cycle.
-type tickMsg struct{}
+// engineEventMsg carries an event published by the engine.
+type engineEventMsg engine.Event
 
-// pollResultMsg carries fresh live detection results.
-type pollResultMsg struct {
-	results map[string]session.LiveInfo // worktree name → LiveInfo
+// sessionEventMsg carries a session file change seen by the watcher.
+type sessionEventMsg session.WatchEvent
+
+// sessionsReadMsg carries a row's session figures, read off the UI
+// goroutine after one of its sessions was written.
+type sessionsReadMsg struct {
+	path    string
+	sessNum int
+	ours    bool // the written session was run in the worktree
+	active  time.Time
+	cost    string
 }
 
 type menuModel struct {
@@ -711,81 +953,129 @@ type menuModel struct {
 	quitting    bool
 	width       int
 	height      int
-	repoName    string
 	showPreview bool
-}
 
-const pollInterval = 500 * time.Millisecond
+	source   statusSource
+	events   <-chan engine.Event
+	sessions <-chan session.WatchEvent
+	keys     config.Keys
 
-func tickCmd() tea.Cmd {
-	return tea.Tick(pollInterval, func(time.Time) tea.Msg {
-		return tickMsg{}
-	})
+	hookResults []hooks.Result // recent hook runs, shown under the hints
+	notifyErr   string         // recent notification sink failure, shown with them
 }
 
-func (m menuModel) pollCmd() tea.Msg {
-	results := make(map[string]session.LiveInfo)
-	sessName := tmux.SessionName(m.repoName)
-	for _, item := range m.items {
-		winName := tmux.WindowName(item.name)
-		live := item.detector.Detect(sessName, winName)
-		results[item.name] = live
+// waitEvent blocks until the engine publishes the next event.
+// Returns nil once the subscription is closed, which ends the wait loop.
+func (m menuModel) waitEvent() tea.Msg {
+	ev, ok := <-m.events
+	if !ok {
+		return nil
 	}
-	return pollResultMsg{results: results}
+	return engineEventMsg(ev)
 }
 
-func (m menuModel) Init() tea.Cmd { return tickCmd() }
+// waitSession blocks until the watcher reports a session change.
+// Returns nil once the watcher stops.
+func (m menuModel) waitSession() tea.Msg {
+	ev, ok := <-m.sessions
+	if !ok {
+		return nil
+	}
+	return sessionEventMsg(ev)
+}
+
+func (m menuModel) Init() tea.Cmd { return tea.Batch(m.waitEvent, m.waitSession) }
 
 func (m menuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-	case tickMsg:
-		return m, m.pollCmd
-	case pollResultMsg:
+	case engineEventMsg:
+		if msg.Kind == engine.EventPolled {
+			m.hookResults = recentHooks(m.source)
+			m.notifyErr = recentNotifyFailure()
+		}
+		for i := range m.items {
+			if msg.Kind != engine.EventPolled && m.items[i].key != msg.Key {
+				continue
+			}
+			if st, ok := m.source.State(m.items[i].key); ok {
+				m.items[i].live = st.Live
+				m.items[i].dirty = st.Dirty
+			}
+			if msg.Kind == engine.EventWindowDisappeared && msg.Prev.Live.HasClaude {
+				m.items[i].last.Status = msg.Prev.Live.Status
+			}
+		}
+		return m, m.waitEvent
+	case sessionEventMsg:
+		cmds := []tea.Cmd{m.waitSession}
+		for _, item := range m.items {
+			if item.path == msg.Worktree {
+				cmds = append(cmds, item.readSessions(session.WatchEvent(msg)))
+			}
+		}
+		return m, tea.Batch(cmds...)
+	case sessionsReadMsg:
 		for i := range m.items {
-			if live, ok := msg.results[m.items[i].name]; ok {
-				m.items[i].live = live
+			item := &m.items[i]
+			if item.path != msg.path {
+				continue
+			}
+			item.sessNum = msg.sessNum
+			if msg.ours {
+				if msg.active.After(item.active) {
+					item.active = msg.active
+				}
+				item.cost = msg.cost
 			}
 		}
-		return m, tickCmd()
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "up", "k":
+		k := m.keys
+		switch key := msg.String(); {
+		case slices.Contains(k.Up, key):
 			if m.cursor > 0 {
 				m.cursor--
 			}
-		case "down", "j":
+		case slices.Contains(k.Down, key):
 			if m.cursor < len(m.items)-1 {
 				m.cursor++
 			}
-		case "enter":
+		case slices.Contains(k.Open, key):
 			m.selected = m.items[m.cursor].choice
 			m.confirmed = true
 			m.quitting = true
 			return m, tea.Quit
-		case "n":
-			m.selected = menuChoice{action: "new"}
+		case slices.Contains(k.New, key):
+			m.selected = menuChoice{action: "new", repo: m.cursorRepo()}
 			m.confirmed = true
 			m.quitting = true
 			return m, tea.Quit
-		case "m":
-			m.selected = menuChoice{action: "merge"}
+		case slices.Contains(k.Merge, key):
+			m.selected = menuChoice{action: "merge", repo: m.cursorRepo()}
 			m.confirmed = true
 			m.quitting = true
 			return m, tea.Quit
-		case "d":
-			m.selected = menuChoice{action: "delete"}
+		case slices.Contains(k.Delete, key):
+			m.selected = menuChoice{action: "delete", repo: m.cursorRepo()}
 			m.confirmed = true
 			m.quitting = true
 			return m, tea.Quit
-		case "r":
+		case slices.Contains(k.Refresh, key):
 			// Immediate refresh
-			return m, m.pollCmd
-		case "p":
+			m.source.Refresh()
+		case slices.Contains(k.Preview, key):
 			m.showPreview = !m.showPreview
-		case "q", "esc", "ctrl+c":
+		case slices.Contains(k.Restart, key):
+			if m.cursor < len(m.items) && hasExited(m.items[m.cursor].live) {
+				item := m.items[m.cursor]
+				m.selected = menuChoice{action: "restart", repo: item.repo, name: item.name}
+				m.confirmed = true
+				m.quitting = true
+				return m, tea.Quit
+			}
+		case slices.Contains(k.Quit, key), key == "ctrl+c":
 			m.quitting = true
 			return m, tea.Quit
 		}
@@ -793,6 +1083,35 @@ func (m menuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// readSessions rereads a row's sessions after one of them was written: the
+// session count, last activity and cost, delivered as a sessionsReadMsg. It
+// also rebinds the window when the live agent has moved on to a new
+// session. The files and the state store are read in the command, off the
+// UI goroutine.
+func (item menuItem) readSessions(ev session.WatchEvent) tea.Cmd {
+	return func() tea.Msg {
+		sessions, _ := repoProfiles(item.root)[0].Sessions(item.path)
+		msg := sessionsReadMsg{path: item.path, sessNum: len(sessions)}
+		if !slices.ContainsFunc(sessions, func(s session.Session) bool { return s.ID == ev.ID }) {
+			return msg // run from another directory
+		}
+		msg.ours, msg.active = true, ev.ModTime
+		msg.cost = formatCost(worktreeUsage(item.root, worktree.Worktree{Path: item.path}))
+		if item.live.HasClaude {
+			followSession(item.path, item.repo, item.name, sessions)
+		}
+		return msg
+	}
+}
+
+// cursorRepo returns the repo of the highlighted row; new/merge/delete act on it.
+func (m menuModel) cursorRepo() string {
+	if m.cursor < len(m.items) {
+		return m.items[m.cursor].repo
+	}
+	return ""
+}
+
 var (
 	menuPanelStyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
@@ -801,11 +1120,13 @@ var (
 
 	menuTitleStyle = lipgloss.NewStyle().Bold(true)
 	menuDimStyle   = lipgloss.NewStyle().Faint(true)
+	menuRepoStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("4"))
 
 	menuAccentColor  = lipgloss.Color("4")
 	menuIdleColor    = lipgloss.Color("2")
 	menuBusyColor    = lipgloss.Color("4")
 	menuWaitingColor = lipgloss.Color("3")
+	menuErrorColor   = lipgloss.Color("1")
 )
 
 func statusColor(s session.AgentStatus) lipgloss.Color {
@@ -816,6 +1137,8 @@ func statusColor(s session.AgentStatus) lipgloss.Color {
 		return menuBusyColor
 	case session.StatusWaiting:
 		return menuWaitingColor
+	case session.StatusError, session.StatusExited:
+		return menuErrorColor
 	default:
 		return lipgloss.Color("8")
 	}
@@ -837,9 +1160,17 @@ func (m menuModel) View() string {
 		maxName = 12
 	}
 
-	// Build rows
+	// Build rows, with a header line whenever the repo changes
+	multiRepo := len(m.items) > 0 && m.items[0].repo != m.items[len(m.items)-1].repo
 	var rows []string
 	for i, item := range m.items {
+		if multiRepo && (i == 0 || m.items[i-1].repo != item.repo) {
+			if i > 0 {
+				rows = append(rows, "")
+			}
+			rows = append(rows, menuRepoStyle.Render(item.repo))
+		}
+
 		// Cursor
 		var cursor string
 		if i == m.cursor {
@@ -858,19 +1189,34 @@ func (m menuModel) View() string {
 		}
 
 		// Status indicator
-		statusWidth := 10
+		statusWidth := 14
 		var statusCol string
 		if item.live.HasClaude {
 			c := statusColor(item.live.Status)
 			dot := lipgloss.NewStyle().Foreground(c).Render("●")
 			label := item.live.Status.String()
 			statusCol = dot + " " + lipgloss.NewStyle().Foreground(c).Render(label)
+			if held := formatHeld(item.live.Since); held != "" {
+				statusCol += menuDimStyle.Render(" " + held)
+				label += " " + held
+			}
 			if pad := statusWidth - 2 - len(label); pad > 0 {
 				statusCol += strings.Repeat(" ", pad)
 			}
+		} else if item.live.Unreadable {
+			// The multiplexer can't read the agent's pane; see the footer
+			statusCol = menuDimStyle.Render("? no status")
+			statusCol += strings.Repeat(" ", statusWidth-11)
 		} else if item.live.Exists {
 			statusCol = menuDimStyle.Render("● live")
 			statusCol += strings.Repeat(" ", statusWidth-6)
+		} else if item.last.Status != session.StatusUnknown {
+			// Window closed — show the last status we saw, hollow and dim
+			label := item.last.Status.String()
+			statusCol = menuDimStyle.Render("○ " + label)
+			if pad := statusWidth - 2 - len(label); pad > 0 {
+				statusCol += strings.Repeat(" ", pad)
+			}
 		} else {
 			statusCol = strings.Repeat(" ", statusWidth)
 		}
@@ -892,6 +1238,21 @@ func (m menuModel) View() string {
 			sessCol = strings.Repeat(" ", sessWidth)
 		}
 
+		// Last activity
+		activeWidth := 10
+		activeCol := strings.Repeat(" ", activeWidth)
+		if !item.active.IsZero() {
+			age := formatAge(item.active)
+			activeCol = menuDimStyle.Render(age) + strings.Repeat(" ", max(activeWidth-len(age), 1))
+		}
+
+		// Cost
+		costWidth := 9
+		costCol := strings.Repeat(" ", costWidth)
+		if item.cost != "" {
+			costCol = menuDimStyle.Render(item.cost) + strings.Repeat(" ", max(costWidth-len(item.cost), 1))
+		}
+
 		// Git badges
 		var badges []string
 		if item.ahead > 0 {
@@ -905,7 +1266,7 @@ func (m menuModel) View() string {
 		}
 		gitCol := strings.Join(badges, " ")
 
-		row := cursor + name + "  " + statusCol + "  " + sessCol + gitCol
+		row := cursor + name + "  " + statusCol + "  " + sessCol + activeCol + costCol + gitCol
 		rows = append(rows, row)
 	}
 
@@ -915,14 +1276,48 @@ func (m menuModel) View() string {
 
 	// Keybind hints with accented keys
 	accent := lipgloss.NewStyle().Foreground(menuAccentColor)
-	hints := accent.Render("n") + menuDimStyle.Render(" new") + "   " +
-		accent.Render("m") + menuDimStyle.Render(" merge") + "   " +
-		accent.Render("d") + menuDimStyle.Render(" delete") + "   " +
-		accent.Render("r") + menuDimStyle.Render(" refresh") + "   " +
-		accent.Render("p") + menuDimStyle.Render(" preview") + "   " +
-		accent.Render("q") + menuDimStyle.Render(" quit")
+	hint := func(keys []string, label string) string {
+		if len(keys) == 0 {
+			return ""
+		}
+		return accent.Render(keys[0]) + menuDimStyle.Render(" "+label)
+	}
+	hints := hint(m.keys.New, "new") + "   " +
+		hint(m.keys.Merge, "merge") + "   " +
+		hint(m.keys.Delete, "delete") + "   " +
+		hint(m.keys.Refresh, "refresh") + "   " +
+		hint(m.keys.Preview, "preview") + "   " +
+		hint(m.keys.Quit, "quit")
+	if m.cursor < len(m.items) && hasExited(m.items[m.cursor].live) {
+		hints = hint(m.keys.Restart, "restart agent") + "   " + hints
+	}
 
 	content := title + "\n\n" + strings.Join(rows, "\n") + "\n\n" + hints
+
+	// Recent hook runs, failures in red with their last line of output
+	if len(m.hookResults) > 0 {
+		var lines []string
+		for _, res := range m.hookResults {
+			line := formatHookResult(res)
+			if res.Err != nil {
+				if out := lastLine(res.Output); out != "" {
+					line += " — " + out
+				}
+				lines = append(lines, lipgloss.NewStyle().Foreground(menuErrorColor).Render(line))
+			} else {
+				lines = append(lines, menuDimStyle.Render(line))
+			}
+		}
+		content += "\n\n" + strings.Join(lines, "\n")
+	}
+	if slices.ContainsFunc(m.items, func(item menuItem) bool { return item.live.Unreadable }) {
+		content += "\n\n" + lipgloss.NewStyle().Foreground(menuWaitingColor).Render(
+			"? no status: Zellij only lets parkranger read the focused pane, so agent status\n"+
+				"  and notifications are unavailable for agents in other tabs or panes")
+	}
+	if m.notifyErr != "" {
+		content += "\n\n" + lipgloss.NewStyle().Foreground(menuErrorColor).Render(m.notifyErr)
+	}
 	panel := menuPanelStyle.Render(content)
 
 	// Preview panel
@@ -953,7 +1348,12 @@ func (m menuModel) View() string {
 
 // renderPanePreview renders a bordered box with the captured Claude pane content.
 func (m menuModel) renderPanePreview(item menuItem) string {
-	paneContent := item.live.PaneContent
+	// Prefer the coloured capture; escapes are SGR only, so they're safe to
+	// pass through and ansi-aware truncation keeps them balanced.
+	paneContent := item.live.PaneStyled
+	if paneContent == "" {
+		paneContent = item.live.PaneContent
+	}
 	if paneContent == "" {
 		return ""
 	}
@@ -962,6 +1362,12 @@ func (m menuModel) renderPanePreview(item menuItem) string {
 	previewTitle := item.name
 	if item.live.HasClaude {
 		previewTitle += " · " + item.live.Status.String()
+		if held := formatHeld(item.live.Since); held != "" {
+			previewTitle += " for " + held
+		}
+	}
+	if n := len(item.live.Panes); n > 1 {
+		previewTitle += fmt.Sprintf(" · %d agents", n)
 	}
 
 	// Calculate available space
@@ -973,16 +1379,20 @@ func (m menuModel) renderPanePreview(item menuItem) string {
 		previewWidth = 120
 	}
 
+	// With several agents, list them all; the content below is the
+	// headline pane's (marked ▸).
+	breakdown := renderPaneBreakdown(item.live, previewWidth-4)
+
 	// Show last N lines that fit. Reserve lines for border (2) + title.
 	// Use roughly half the terminal for the preview.
-	maxLines := m.height/2 - 3
+	maxLines := m.height/2 - 3 - len(breakdown)
 	if maxLines < 5 {
 		maxLines = 5
 	}
 
 	paneLines := strings.Split(paneContent, "\n")
 	// Trim trailing blanks
-	for len(paneLines) > 0 && strings.TrimSpace(paneLines[len(paneLines)-1]) == "" {
+	for len(paneLines) > 0 && strings.TrimSpace(ansi.Strip(paneLines[len(paneLines)-1])) == "" {
 		paneLines = paneLines[:len(paneLines)-1]
 	}
 	if len(paneLines) > maxLines {
@@ -991,10 +1401,7 @@ func (m menuModel) renderPanePreview(item menuItem) string {
 
 	// Truncate long lines
 	for i, line := range paneLines {
-		runes := []rune(line)
-		if len(runes) > previewWidth-4 {
-			paneLines[i] = string(runes[:previewWidth-7]) + "..."
-		}
+		paneLines[i] = ansi.Truncate(line, previewWidth-4, "...")
 	}
 
 	body := strings.Join(paneLines, "\n")
@@ -1006,40 +1413,159 @@ func (m menuModel) renderPanePreview(item menuItem) string {
 		Width(previewWidth)
 
 	header := menuDimStyle.Render(previewTitle)
+	if len(breakdown) > 0 {
+		header += "\n" + strings.Join(breakdown, "\n") + "\n"
+	}
 	return style.Render(header + "\n" + body)
 }
 
+// renderPaneBreakdown returns one line per agent pane — status, pane ID and
+// title — for windows running more than one agent.
+func renderPaneBreakdown(live session.LiveInfo, width int) []string {
+	if len(live.Panes) < 2 {
+		return nil
+	}
+	var lines []string
+	for _, p := range live.Panes {
+		marker := "  "
+		if p.ID == live.PaneID {
+			marker = "▸ "
+		}
+		status := "—"
+		if p.HasClaude {
+			status = strings.TrimSpace(p.Status.String() + " " + formatHeld(p.Since))
+		}
+		line := fmt.Sprintf("%-11s %-4s %s", status, p.ID, p.Title)
+		if runes := []rune(line); len(runes) > width-2 {
+			line = string(runes[:width-5]) + "..."
+		}
+		lines = append(lines, marker+lipgloss.NewStyle().Foreground(statusColor(p.Status)).Render(line))
+	}
+	return lines
+}
+
 func interactive() error {
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	// Without a daemon, the dashboard runs its own engine. It outlives each
+	// dashboard run so detectors keep their hash history while the user is
+	// off opening/merging/deleting worktrees.
+	var eng *engine.Engine
+	persisted := loadStore()
+
 	for {
-		_, repoName, wts, err := resolveRepo()
+		repos, err := dashboardRepos()
 		if err != nil {
 			return err
 		}
 
 		var items []menuItem
-		sessName := tmux.SessionName(repoName)
-		for _, wt := range wts {
-			det := &session.Detector{}
-			winName := tmux.WindowName(wt.Name)
-			live := det.Detect(sessName, winName)
-			sessions, _ := session.ListSessions(wt.Path)
-
-			items = append(items, menuItem{
-				name:     wt.Name,
-				live:     live,
-				sessNum:  len(sessions),
-				ahead:    wt.Ahead,
-				behind:   wt.Behind,
-				dirty:    wt.Dirty,
-				isMain:   wt.IsMain,
-				choice:   menuChoice{action: "open", name: wt.Name},
-				detector: det,
-			})
-		}
-
-		model := menuModel{title: repoName, items: items, repoName: repoName}
+		targets := repoTargets(repos)
+		for _, r := range repos {
+			for _, wt := range r.wts {
+				sessions, _ := repoProfiles(r.root)[0].Sessions(wt.Path)
+				var active time.Time
+				if len(sessions) > 0 {
+					active = sessions[0].ModTime
+				}
+				items = append(items, menuItem{
+					key:     engine.Target{Repo: r.name, Worktree: wt.Name}.Key(),
+					repo:    r.name,
+					root:    r.root,
+					name:    wt.Name,
+					path:    wt.Path,
+					sessNum: len(sessions),
+					active:  active,
+					ahead:   wt.Ahead,
+					behind:  wt.Behind,
+					dirty:   wt.Dirty,
+					isMain:  wt.IsMain,
+					cost:    formatCost(worktreeUsage(r.root, wt)),
+					choice:  menuChoice{action: "open", repo: r.name, name: wt.Name},
+				})
+			}
+		}
+
+		session.SaveIndex() // best effort: it is only a cache
+
+		// Hydrate last known state so closed windows still show where they left off
+		if persisted != nil {
+			for i, t := range targets {
+				if rec, ok := persisted.Get(t.Path); ok {
+					items[i].last = rec
+				}
+			}
+		}
+
+		var (
+			source      statusSource
+			events      <-chan engine.Event
+			unsubscribe func()
+		)
+		if c := dialDaemon(); c != nil && c.Watch(ctx, targets) == nil {
+			mirrorCtx, cancel := context.WithCancel(ctx)
+			if mirror, ch, err := daemon.NewMirror(mirrorCtx, c); err == nil {
+				source, events, unsubscribe = mirror, ch, cancel
+			} else {
+				cancel()
+			}
+		}
+		if source == nil {
+			if eng == nil {
+				eng = newEngine()
+				if persisted != nil {
+					eng.SetStore(persisted)
+				}
+				notes, _ := eng.Subscribe(64)
+				notifier := notify.New(notifyConfig)
+				notifier.OnError = recordNotifyFailure
+				go notifier.Run(notes)
+				agentEvents, _ := eng.Subscribe(64)
+				go runAgentHooks(agentEvents)
+				go eng.Run(ctx)
+			}
+			// Poll once up front so the first frame already shows live status.
+			eng.SetTargets(targets)
+			eng.Poll()
+			source = eng
+			events, unsubscribe = eng.Subscribe(64)
+		}
+
+		for i := range items {
+			if st, ok := source.State(items[i].key); ok {
+				items[i].live = st.Live
+			}
+		}
+
+		// New sessions and turns update the rows while the dashboard is up
+		paths := make([]string, len(targets))
+		for i, t := range targets {
+			paths[i] = t.Path
+		}
+		watcher := session.NewWatcher(paths)
+		watchCtx, stopWatch := context.WithCancel(ctx)
+		go watcher.Run(watchCtx)
+
+		title := repos[0].name
+		if len(repos) > 1 {
+			title = fmt.Sprintf("%d repos", len(repos))
+		}
+
+		model := menuModel{
+			title:       title,
+			items:       items,
+			source:      source,
+			events:      events,
+			sessions:    watcher.Events(),
+			keys:        dashboardConfig().Keys,
+			hookResults: recentHooks(source),
+			notifyErr:   recentNotifyFailure(),
+		}
 		p := tea.NewProgram(model, tea.WithAltScreen())
 		result, err := p.Run()
+		unsubscribe()
+		stopWatch()
 		if err != nil {
 			return err
 		}
@@ -1049,19 +1575,30 @@ func interactive() error {
 			return nil
 		}
 
+		var r repoInfo
+		for _, candidate := range repos {
+			if candidate.name == m.selected.repo {
+				r = candidate
+			}
+		}
+
 		switch m.selected.action {
 		case "open":
 			// open attaches to tmux — if outside tmux, syscall.Exec replaces
 			// the process so the loop won't continue (which is fine).
 			// If inside tmux, switch-client returns and we loop back.
-			if err := cmdOpen(m.selected.name); err != nil {
+			wt := worktree.FindByName(r.wts, m.selected.name)
+			if wt == nil {
+				continue
+			}
+			if err := openSession(r.name, r.root, wt); err != nil {
 				fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			}
 
 		case "new":
 			var name string
 			err := huh.NewInput().
-				Title("Branch name").
+				Title(fmt.Sprintf("Branch name (%s)", r.name)).
 				Value(&name).
 				Run()
 			if err != nil {
@@ -1072,40 +1609,49 @@ func interactive() error {
 			if name == "" {
 				continue
 			}
-			if err := cmdNew(name); err != nil {
+			if err := newWorktree(r, name); err != nil {
 				fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			}
 
 		case "merge":
-			name, err := pickWorktree(wts, "Merge which worktree?")
+			wt, err := pickWorktree(r.wts, fmt.Sprintf("Merge which %s worktree?", r.name))
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "error: %v\n", err)
 				continue
 			}
-			if name == "" {
+			if wt == nil {
 				continue
 			}
-			if err := cmdMerge(name); err != nil {
+			if err := mergeWorktree(r, wt); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			}
+
+		case "restart":
+			wt := worktree.FindByName(r.wts, m.selected.name)
+			if wt == nil {
+				continue
+			}
+			if err := restartAgent(r, wt); err != nil {
 				fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			}
 
 		case "delete":
-			name, err := pickWorktree(wts, "Delete which worktree?")
+			wt, err := pickWorktree(r.wts, fmt.Sprintf("Delete which %s worktree?", r.name))
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "error: %v\n", err)
 				continue
 			}
-			if name == "" {
+			if wt == nil {
 				continue
 			}
-			if err := cmdDelete(name); err != nil {
+			if err := deleteWorktree(r, wt); err != nil {
 				fmt.Fprintf(os.Stderr, "error: %v\n", err)
 			}
 		}
 	}
 }
 
-func pickWorktree(wts []worktree.Worktree, title string) (string, error) {
+func pickWorktree(wts []worktree.Worktree, title string) (*worktree.Worktree, error) {
 	var options []huh.Option[string]
 	for _, wt := range wts {
 		if wt.IsMain {
@@ -1117,7 +1663,7 @@ func pickWorktree(wts []worktree.Worktree, title string) (string, error) {
 
 	if len(options) == 0 {
 		fmt.Println("No worktrees to select (only main).")
-		return "", nil
+		return nil, nil
 	}
 
 	var name string
@@ -1126,16 +1672,16 @@ func pickWorktree(wts []worktree.Worktree, title string) (string, error) {
 		Options(options...).
 		Value(&name).
 		Run()
-	return name, err
+	if err != nil {
+		return nil, err
+	}
+	return worktree.FindByName(wts, name), nil
 }
 
-// formatSessionInfo returns a string like "● 1 live, 7 sessions" or "3 sessions".
-func formatSessionInfo(repoName string, wt worktree.Worktree) string {
-	sessName := tmux.SessionName(repoName)
-	winName := tmux.WindowName(wt.Name)
-	live := session.DetectLive(sessName, winName)
-
-	sessions, _ := session.ListSessions(wt.Path)
+// formatSessionInfo returns a string like "● 1 live, 7 sessions" or "3 sessions",
+// counting the agent's past sessions.
+func formatSessionInfo(live session.LiveInfo, wt worktree.Worktree, agent session.AgentProfile) string {
+	sessions, _ := agent.Sessions(wt.Path)
 	count := len(sessions)
 
 	var parts []string
@@ -1180,6 +1726,23 @@ func formatStatus(wt worktree.Worktree) string {
 	return "(" + strings.Join(parts, ", ") + ")"
 }
 
+// formatHeld returns how long a status has been held, e.g. "45s" or "4m",
+// or "" if its start is unknown.
+func formatHeld(since time.Time) string {
+	if since.IsZero() {
+		return ""
+	}
+	d := time.Since(since)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+}
+
 // formatAge converts a time to a human-readable age string.
 func formatAge(t time.Time) string {
 	d := time.Since(t)
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/grins/parkranger/internal/mux"
+	"github.com/grins/parkranger/internal/session"
+	"github.com/grins/parkranger/internal/worktree"
+)
+
+// TestMain points home, config and state at a scratch directory so the
+// tests never read or write the user's files.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "parkranger-test")
+	if err != nil {
+		panic(err)
+	}
+	for _, env := range []string{"HOME", "XDG_CONFIG_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME", "XDG_DATA_HOME"} {
+		os.Setenv(env, dir)
+	}
+	os.Setenv("EDITOR", "vim")
+	os.Unsetenv("TMUX")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// useFake makes a fake multiplexer the default for one test.
+func useFake(t *testing.T) *mux.Fake {
+	t.Helper()
+	f := mux.NewFake()
+	prev := mux.Default()
+	mux.SetDefault(f)
+	t.Cleanup(func() { mux.SetDefault(prev) })
+	return f
+}
+
+// agentWindow creates a window with an editor pane and a tagged agent pane.
+func agentWindow(t *testing.T, f *mux.Fake, session, window string) (editor, agent string) {
+	t.Helper()
+	if _, err := f.EnsureSession(session, "/repo"); err != nil {
+		t.Fatal(err)
+	}
+	editor, err := f.NewWindow(session, window, "/wt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent, err = f.SplitPane(editor, "/wt", true, ""); err != nil {
+		t.Fatal(err)
+	}
+	f.TagAgentPane(agent)
+	return editor, agent
+}
+
+func TestStartSession_CreatesWindow(t *testing.T) {
+	f := useFake(t)
+	root := t.TempDir()
+	wt := &worktree.Worktree{Name: "feat.x", Path: filepath.Join(root, "wt")}
+
+	if err := startSession("app", root, wt, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.Attached(), "pr-app:feat-x"; got != want {
+		t.Errorf("attached %q, want %q", got, want)
+	}
+	if dash := f.ListPanes("pr-app", mux.DashboardWindow); len(dash) != 1 || len(f.Sent(dash[0].ID)) != 1 {
+		t.Errorf("dashboard not launched in the new session: %+v", dash)
+	}
+
+	panes := f.ListPanes("pr-app", "feat-x")
+	if len(panes) != 2 || !panes[1].Agent {
+		t.Fatalf("panes = %+v, want editor and agent", panes)
+	}
+	if got := f.Sent(panes[0].ID); !reflect.DeepEqual(got, []string{"vim ."}) {
+		t.Errorf("editor pane typed %q", got)
+	}
+	if got := f.Sent(panes[1].ID); !reflect.DeepEqual(got, []string{"claude"}) {
+		t.Errorf("agent pane typed %q", got)
+	}
+}
+
+func TestStartSession_ResumesInOpenWindow(t *testing.T) {
+	f := useFake(t)
+	editor, agent := agentWindow(t, f, "pr-app", "feat")
+	root := t.TempDir()
+	wt := &worktree.Worktree{Name: "feat", Path: filepath.Join(root, "wt")}
+
+	if err := startSession("app", root, wt, "abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Sent(agent); !reflect.DeepEqual(got, []string{"claude --resume abc123"}) {
+		t.Errorf("agent pane typed %q", got)
+	}
+	if got := f.Sent(editor); len(got) != 0 {
+		t.Errorf("editor pane typed %q", got)
+	}
+	if f.Attached() != "pr-app:feat" {
+		t.Errorf("attached %q", f.Attached())
+	}
+	if got := boundSession(wt.Path); got != "abc123" {
+		t.Errorf("bound session = %q, want abc123", got)
+	}
+}
+
+func TestStartSession_NoResume(t *testing.T) {
+	f := useFake(t)
+	_, agent := agentWindow(t, f, "pr-app", "feat")
+	root := t.TempDir()
+	config := "agent = \"aider\"\n\n[[agents]]\nname = \"aider\"\ncommand = \"aider\"\n"
+	if err := os.WriteFile(filepath.Join(root, ".parkranger.toml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	resetConfig()
+	t.Cleanup(resetConfig)
+	wt := &worktree.Worktree{Name: "feat", Path: filepath.Join(root, "wt")}
+
+	if err := startSession("app", root, wt, "abc123"); err == nil {
+		t.Error("resuming with an agent that can't: no error")
+	}
+	if got := f.Sent(agent); len(got) != 0 {
+		t.Errorf("agent pane typed %q", got)
+	}
+	if got := boundSession(wt.Path); got != "" {
+		t.Errorf("bound session = %q, want none", got)
+	}
+}
+
+func TestStartSession_AttachesLive(t *testing.T) {
+	f := useFake(t)
+	_, agent := agentWindow(t, f, "pr-app", "feat")
+	root := t.TempDir()
+	wt := &worktree.Worktree{Name: "feat", Path: filepath.Join(root, "wt")}
+
+	if err := startSession("app", root, wt, "live"); err != nil {
+		t.Fatal(err)
+	}
+	if f.Attached() != "pr-app:feat" || len(f.Sent(agent)) != 0 {
+		t.Errorf("attached %q, typed %q", f.Attached(), f.Sent(agent))
+	}
+}
+
+func TestRestartAgent(t *testing.T) {
+	f := useFake(t)
+	editor, shell := agentWindow(t, f, "pr-app", "feat")
+	dead, _ := f.SplitPane(shell, "/wt", false, "")
+	f.Update(editor, func(p *mux.Pane) { p.Command = "vim" })
+	f.Update(dead, func(p *mux.Pane) { p.Agent, p.Dead = true, true })
+
+	root := t.TempDir()
+	wt := &worktree.Worktree{Name: "feat", Path: filepath.Join(root, "wt")}
+	if err := restartAgent(repoInfo{root: root, name: "app"}, wt); err != nil {
+		t.Fatal(err)
+	}
+	for _, pane := range []string{shell, dead} {
+		if got := f.Sent(pane); !reflect.DeepEqual(got, []string{"claude"}) {
+			t.Errorf("pane %s got %q, want the agent restarted", pane, got)
+		}
+	}
+	if got := f.Sent(editor); len(got) != 0 {
+		t.Errorf("editor pane typed %q", got)
+	}
+
+	// Nothing left to restart once the agents run again
+	f.Update(shell, func(p *mux.Pane) { p.Command = "claude" })
+	f.Update(dead, func(p *mux.Pane) { p.Command = "claude" })
+	if err := restartAgent(repoInfo{root: root, name: "app"}, wt); err == nil {
+		t.Error("restart with no exited agent: no error")
+	}
+}
+
+func TestCmdRecord_FlagsAfterTarget(t *testing.T) {
+	f := useFake(t)
+	_, agent := agentWindow(t, f, "pr-app", "feat")
+	f.SetScreen(agent, "❯ \n")
+	out := filepath.Join(t.TempDir(), "rec.jsonl")
+
+	if err := cmdRecord([]string{agent, "-o", out, "-duration", "1ms", "-interval", "1ms"}); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rec, err := session.ReadRecording(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Header.Target != agent || len(rec.Frames) == 0 {
+		t.Errorf("recording = %+v", rec)
+	}
+}
+
+func TestFollowSession(t *testing.T) {
+	st := loadStore()
+	if st == nil {
+		t.Fatal("no state store")
+	}
+	path := filepath.Join(t.TempDir(), "wt")
+	st.BindSession(path, "app", "feat", "pr-app:feat", "")
+	bound, _ := st.Get(path)
+	before, after := bound.BoundAt.Add(-time.Minute), bound.BoundAt.Add(time.Second)
+
+	// Started before the agent was launched: someone else's session
+	followSession(path, "app", "feat", []session.Session{{ID: "old", Started: before}})
+	if got := boundSession(path); got != "" {
+		t.Errorf("bound to %q, want the fresh binding kept", got)
+	}
+	// Two new sessions: either could be the window's
+	followSession(path, "app", "feat", []session.Session{{ID: "s1", Started: after}, {ID: "s2", Started: after}})
+	if got := boundSession(path); got != "" {
+		t.Errorf("bound to %q with two candidates", got)
+	}
+	followSession(path, "app", "feat", []session.Session{{ID: "s1", Started: after}, {ID: "old", Started: before}})
+	if got := boundSession(path); got != "s1" {
+		t.Errorf("bound to %q, want s1", got)
+	}
+	if rec, _ := st.Get(path); rec.Window != "pr-app:feat" {
+		t.Errorf("window = %q", rec.Window)
+	}
+
+	// No window, nothing to bind
+	other := filepath.Join(t.TempDir(), "wt")
+	followSession(other, "app", "other", []session.Session{{ID: "s3", Started: time.Now()}})
+	if _, ok := st.Get(other); ok {
+		t.Error("unbound worktree got a record")
+	}
+}
+
+// runCmd runs cmd and any commands it batches, returning their messages.
+func runCmd(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		var msgs []tea.Msg
+		for _, c := range batch {
+			msgs = append(msgs, runCmd(c)...)
+		}
+		return msgs
+	}
+	if msg == nil {
+		return nil
+	}
+	return []tea.Msg{msg}
+}
+
+func TestMenuModel_SessionEvent(t *testing.T) {
+	wt := filepath.Join(t.TempDir(), "feat")
+	dir := session.ProjectDir(wt)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "s1.jsonl")
+	line := `{"type":"user","cwd":"` + wt + `","message":{"role":"user","content":"Hi"}}` + "\n"
+	if err := os.WriteFile(file, []byte(line), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A closed watcher channel keeps waitSession from blocking
+	sessions := make(chan session.WatchEvent)
+	close(sessions)
+	m := menuModel{items: []menuItem{{name: "feat", path: wt}, {name: "other", path: "/elsewhere"}}, sessions: sessions}
+	at := time.Now()
+	next, cmd := m.Update(sessionEventMsg{Kind: session.SessionCreated, Worktree: wt, ID: "s1", Path: file, ModTime: at})
+	m = next.(menuModel)
+	if got := m.items[0]; got.sessNum != 0 {
+		t.Errorf("sessions read on the UI goroutine: %+v", got)
+	}
+	msgs := runCmd(cmd)
+	if len(msgs) != 1 {
+		t.Fatalf("messages = %v, want the sessions read", msgs)
+	}
+	next, _ = m.Update(msgs[0])
+	m = next.(menuModel)
+	if got := m.items[0]; got.sessNum != 1 || !got.active.Equal(at) {
+		t.Errorf("item = sessions %d, active %v; want 1 session active at %v", got.sessNum, got.active, at)
+	}
+	if got := m.items[1]; got.sessNum != 0 || !got.active.IsZero() {
+		t.Errorf("other item changed: %+v", got)
+	}
+	if cmd == nil {
+		t.Error("no command to wait for the next session event")
+	}
+}
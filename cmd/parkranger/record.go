@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/grins/parkranger/internal/mux"
+	"github.com/grins/parkranger/internal/session"
+)
+
+// cmdRecord handles `parkranger record [flags] <target>`: it captures an
+// agent pane at the poll interval and writes a replay recording, each frame
+// labelled with the status detected live. Correct the labels that are wrong
+// and drop the file into internal/session/testdata/replay.
+func cmdRecord(args []string) error {
+	fs := flag.NewFlagSet("record", flag.ContinueOnError)
+	out := fs.String("o", "", "write the recording to `file` (default stdout)")
+	interval := fs.Duration("interval", 0, "capture interval (default poll_interval)")
+	duration := fs.Duration("duration", 0, "stop after this long (default: until interrupted)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: parkranger record [flags] <worktree|pane>")
+		fs.PrintDefaults()
+	}
+	// Flags may follow the target, as with the other subcommands
+	var targets []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		targets = append(targets, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(targets) != 1 {
+		fs.Usage()
+		return fmt.Errorf("record needs one target")
+	}
+	target := targets[0]
+
+	pane, profile, err := recordTarget(target)
+	if err != nil {
+		return err
+	}
+
+	cfg := repoConfig("")
+	if *interval <= 0 {
+		*interval = cfg.PollInterval.Duration
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	debounce := session.Debounce{Hysteresis: cfg.Hysteresis, QuietAfter: cfg.QuietAfter.Duration}
+	start := time.Now()
+	rec, err := session.NewRecordingWriter(w, session.RecordingHeader{
+		Agent:        profile.Name(),
+		Target:       target,
+		RecordedAt:   start.UTC(),
+		Hysteresis:   debounce.Hysteresis,
+		QuietAfterMS: debounce.QuietAfter.Milliseconds(),
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	fmt.Fprintf(os.Stderr, "Recording %s (%s) every %s, ctrl+c to stop\n", pane, profile.Name(), *interval)
+	d := session.Detector{Debounce: debounce}
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	frames, last := 0, ""
+	for {
+		screen, err := mux.Default().Capture(mux.Pane{ID: pane}, session.CaptureLines)
+		if err != nil {
+			return fmt.Errorf("capture %s: %w", pane, err)
+		}
+		frame := session.Frame{AtMS: time.Since(start).Milliseconds(), Screen: screen}
+		status := d.Replay([]session.Frame{frame}, profile)[0].Status.String()
+		frame.Want = status
+		if err := rec.WriteFrame(frame); err != nil {
+			return err
+		}
+		frames++
+		if status != last {
+			fmt.Fprintf(os.Stderr, "  %6.1fs  %s\n", float64(frame.AtMS)/1000, status)
+			last = status
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Fprintf(os.Stderr, "Recorded %d frames\n", frames)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordTarget resolves a record target to one agent pane and its profile:
+// a tmux pane is read as the current repo's default agent, a worktree by its
+// first agent pane.
+func recordTarget(target string) (string, session.AgentProfile, error) {
+	if strings.HasPrefix(target, "%") || strings.Contains(target, ":") {
+		root, _ := cwdRepoRoot()
+		return target, repoProfiles(root)[0], nil
+	}
+
+	r, wt, err := resolveWorktree(target)
+	if err != nil {
+		return "", nil, err
+	}
+	profiles := repoProfiles(r.root)
+	m := mux.Default()
+	panes, err := session.ExplainWindow(m.SessionName(r.name), m.WindowName(wt.Name), profiles...)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, p := range panes {
+		for _, prof := range profiles {
+			if prof.Name() == p.Agent {
+				return p.PaneID, prof, nil
+			}
+		}
+	}
+	return "", nil, fmt.Errorf("no agent panes in %s/%s", r.name, wt.Name)
+}
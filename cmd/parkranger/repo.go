@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/grins/parkranger/internal/registry"
+	"github.com/grins/parkranger/internal/worktree"
+)
+
+// cmdRepo handles `parkranger repo add|rm|ls`.
+func cmdRepo(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: parkranger repo add|rm|ls")
+	}
+
+	reg, err := registry.Load(registry.DefaultPath())
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "add":
+		path := "."
+		if len(args) > 1 {
+			path = args[1]
+		}
+		repo, err := reg.Add(path)
+		if err != nil {
+			return err
+		}
+		if err := reg.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("Registered %s (%s)\n", repo.Name, repo.Path)
+		return nil
+
+	case "rm", "remove":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: parkranger repo rm <name|path>")
+		}
+		repo, err := reg.Remove(args[1])
+		if err != nil {
+			return err
+		}
+		if err := reg.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("Unregistered %s\n", repo.Name)
+		return nil
+
+	case "ls", "list":
+		if len(reg.Repos) == 0 {
+			fmt.Println("No repos registered. Add one with: parkranger repo add <path>")
+			return nil
+		}
+		for _, repo := range reg.Repos {
+			fmt.Printf(" %-24s %s\n", repo.Name, repo.Path)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown repo command: %s\nusage: parkranger repo add|rm|ls", args[0])
+	}
+}
+
+// dashboardRepos returns the repos shown on the dashboard: the repo containing
+// the CWD (if any) first, followed by every registered repo.
+// Registered repos that can no longer be listed are skipped with a warning.
+func dashboardRepos() ([]repoInfo, error) {
+	var repos []repoInfo
+	seen := make(map[string]bool)
+
+	if root, err := cwdRepoRoot(); err == nil {
+		r, err := loadRepo(root)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, r)
+		seen[root] = true
+	}
+
+	reg, err := registry.Load(registry.DefaultPath())
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range reg.Repos {
+		if seen[entry.Path] {
+			continue
+		}
+		r, err := loadRepo(entry.Path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping repo %s: %v\n", entry.Name, err)
+			continue
+		}
+		repos = append(repos, r)
+		seen[entry.Path] = true
+	}
+
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("not in a git repo and no repos registered (parkranger repo add <path>)")
+	}
+	return repos, nil
+}
+
+// splitQualified splits "<repo>/<rest>" when <repo> is a registered repo name.
+// Returns ok=false for unqualified names (including branch names like feat/x).
+func splitQualified(reg *registry.Registry, arg string) (*registry.Repo, string, bool) {
+	prefix, rest, found := strings.Cut(arg, "/")
+	if !found || rest == "" {
+		return nil, arg, false
+	}
+	entry := reg.Find(prefix)
+	if entry == nil {
+		return nil, arg, false
+	}
+	return entry, rest, true
+}
+
+// resolveRepoArg resolves the repo for a command that takes a new worktree name.
+// "<repo>/<name>" targets a registered repo; otherwise the CWD repo is used.
+func resolveRepoArg(arg string) (repoInfo, string, error) {
+	reg, err := registry.Load(registry.DefaultPath())
+	if err != nil {
+		return repoInfo{}, "", err
+	}
+
+	if entry, name, ok := splitQualified(reg, arg); ok {
+		r, err := loadRepo(entry.Path)
+		return r, name, err
+	}
+
+	r, err := resolveRepo()
+	return r, arg, err
+}
+
+// resolveWorktree finds an existing worktree by name. Qualified names
+// ("<repo>/<name>") target a registered repo; bare names are looked up in the
+// CWD repo, or — outside any repo — across all registered repos.
+func resolveWorktree(arg string) (repoInfo, *worktree.Worktree, error) {
+	reg, err := registry.Load(registry.DefaultPath())
+	if err != nil {
+		return repoInfo{}, nil, err
+	}
+
+	if entry, name, ok := splitQualified(reg, arg); ok {
+		r, err := loadRepo(entry.Path)
+		if err != nil {
+			return repoInfo{}, nil, err
+		}
+		if wt := worktree.FindByName(r.wts, name); wt != nil {
+			return r, wt, nil
+		}
+		return repoInfo{}, nil, fmt.Errorf("worktree %q not found in %s", name, r.name)
+	}
+
+	if root, err := cwdRepoRoot(); err == nil {
+		r, err := loadRepo(root)
+		if err != nil {
+			return repoInfo{}, nil, err
+		}
+		wt := worktree.FindByName(r.wts, arg)
+		if wt == nil {
+			return repoInfo{}, nil, fmt.Errorf("worktree %q not found", arg)
+		}
+		return r, wt, nil
+	}
+
+	var matches []repoInfo
+	for _, entry := range reg.Repos {
+		r, err := loadRepo(entry.Path)
+		if err != nil {
+			continue
+		}
+		if worktree.FindByName(r.wts, arg) != nil {
+			matches = append(matches, r)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return repoInfo{}, nil, fmt.Errorf("worktree %q not found in any registered repo", arg)
+	case 1:
+		return matches[0], worktree.FindByName(matches[0].wts, arg), nil
+	default:
+		var names []string
+		for _, r := range matches {
+			names = append(names, r.name+"/"+arg)
+		}
+		return repoInfo{}, nil, fmt.Errorf("worktree %q is ambiguous: %s", arg, strings.Join(names, ", "))
+	}
+}
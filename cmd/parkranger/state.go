@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/grins/parkranger/internal/session"
+	"github.com/grins/parkranger/internal/store"
+)
+
+var (
+	stateOnce  sync.Once
+	stateStore *store.Store
+)
+
+// loadStore returns the process-wide persistent state store, opening it on
+// first use. Returns nil if the state file can't be read; callers treat
+// persistence as best-effort.
+func loadStore() *store.Store {
+	stateOnce.Do(func() {
+		st, err := store.Open(store.DefaultPath())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: state not loaded: %v\n", err)
+			return
+		}
+		stateStore = st
+	})
+	return stateStore
+}
+
+// forgetWorktree drops persisted state for a removed worktree.
+func forgetWorktree(path string) {
+	st := loadStore()
+	if st == nil {
+		return
+	}
+	st.Forget(path)
+	if err := st.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not save state: %v\n", err)
+	}
+}
+
+// boundSession returns the Claude session last bound to the worktree's window.
+func boundSession(path string) string {
+	if st := loadStore(); st != nil {
+		if rec, ok := st.Get(path); ok {
+			return rec.SessionID
+		}
+	}
+	return ""
+}
+
+// followSession rebinds the worktree's window when its agent has moved on to
+// a new session: a fresh agent only gets its session ID once it writes, and
+// /clear starts a new session in the same window. Only sessions started
+// after the window was bound, that is after its agent was (re)launched or
+// last followed, are candidates, and only a single one is followed: with
+// more, which of them the window runs is a guess.
+func followSession(path, repo, wt string, sessions []session.Session) {
+	st := loadStore()
+	if st == nil {
+		return
+	}
+	rec, ok := st.Get(path)
+	if !ok || rec.Window == "" {
+		return
+	}
+	var candidates []string
+	for _, s := range sessions {
+		if s.ID != rec.SessionID && s.Started.After(rec.BoundAt) {
+			candidates = append(candidates, s.ID)
+		}
+	}
+	if len(candidates) != 1 {
+		return
+	}
+	st.BindSession(path, repo, wt, rec.Window, candidates[0])
+	st.Save() // best effort: this runs under the dashboard, which owns the screen
+}
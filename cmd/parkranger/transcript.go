@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/grins/parkranger/internal/session"
+)
+
+// --- Transcript viewer (Bubble Tea) ---
+
+// transcriptView shows the whole conversation of a past session in a
+// scrollable viewport, so it can be judged before resuming it. It runs
+// inside the session picker, which hands it keys until it is done.
+type transcriptView struct {
+	path     string
+	loading  bool // the file is still being parsed
+	tr       *session.Transcript
+	err      error
+	vp       viewport.Model
+	width    int
+	height   int
+	expanded bool // tool inputs, results and thinking shown in full
+
+	lines   []string // rendered conversation, without search highlights
+	prompts []int    // line of each user prompt
+
+	searching bool   // typing a query
+	input     string // the query being typed
+	query     string
+	matches   []int // lines containing query
+	match     int   // current index into matches
+
+	done   bool // back to the picker
+	resume bool // resume this session
+}
+
+var (
+	transcriptUserStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("2"))
+	transcriptToolStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("4"))
+	transcriptErrorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	transcriptDimStyle   = lipgloss.NewStyle().Faint(true)
+	transcriptMatchStyle = lipgloss.NewStyle().Reverse(true)
+	mdBoldStyle          = lipgloss.NewStyle().Bold(true)
+	mdHeadingStyle       = lipgloss.NewStyle().Bold(true).Underline(true)
+	mdCodeStyle          = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+)
+
+// transcriptLoadedMsg carries a session file parsed off the UI goroutine.
+type transcriptLoadedMsg struct {
+	path string
+	tr   *session.Transcript
+	err  error
+}
+
+// newTranscriptView returns a viewer for the session file at path, showing
+// a loading line, and the command that parses the file: long sessions run
+// to megabytes, which would freeze the picker.
+func newTranscriptView(path string, width, height int) (transcriptView, tea.Cmd) {
+	v := transcriptView{path: path, loading: true}
+	v.resize(width, height)
+	return v, func() tea.Msg {
+		tr, err := session.LoadTranscript(path)
+		return transcriptLoadedMsg{path: path, tr: tr, err: err}
+	}
+}
+
+// resize fits the viewer to the terminal and rewraps the conversation.
+func (v *transcriptView) resize(width, height int) {
+	v.width, v.height = max(width, 40), max(height, 8)
+	// Header and footer take a line each
+	offset := v.vp.YOffset
+	v.vp = viewport.New(v.width, v.height-2)
+	v.render()
+	v.vp.SetYOffset(offset)
+}
+
+// render rebuilds the conversation lines and reapplies the search.
+func (v *transcriptView) render() {
+	switch {
+	case v.loading:
+		v.lines, v.prompts = []string{transcriptDimStyle.Render("loading transcript…")}, nil
+	case v.err != nil:
+		v.lines, v.prompts = []string{transcriptErrorStyle.Render(v.err.Error())}, nil
+	case len(v.tr.Turns) == 0:
+		v.lines, v.prompts = []string{transcriptDimStyle.Render("(no conversation in this session file)")}, nil
+	default:
+		v.lines, v.prompts = renderTranscript(v.tr, v.width, v.expanded)
+	}
+	v.search(v.query)
+}
+
+// search finds query in the rendered lines, case-insensitively, and
+// highlights it.
+func (v *transcriptView) search(query string) {
+	v.query, v.matches, v.match = query, nil, 0
+	content := v.lines
+	if query != "" {
+		content = make([]string, len(v.lines))
+		q := strings.ToLower(query)
+		for i, l := range v.lines {
+			content[i] = l
+			if plain := ansi.Strip(l); strings.Contains(strings.ToLower(plain), q) {
+				v.matches = append(v.matches, i)
+				content[i] = highlight(plain, q)
+			}
+		}
+	}
+	v.vp.SetContent(strings.Join(content, "\n"))
+}
+
+// highlight marks every occurrence of the lower-cased q in a plain line.
+func highlight(line, q string) string {
+	var b strings.Builder
+	lower := strings.ToLower(line)
+	for {
+		i := strings.Index(lower, q)
+		if i < 0 || len(lower) != len(line) {
+			break // case folding changed byte offsets: leave the rest
+		}
+		b.WriteString(line[:i])
+		b.WriteString(transcriptMatchStyle.Render(line[i : i+len(q)]))
+		line, lower = line[i+len(q):], lower[i+len(q):]
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+func (v transcriptView) Update(msg tea.Msg) (transcriptView, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		v.resize(msg.Width, msg.Height)
+		return v, nil
+	case transcriptLoadedMsg:
+		if msg.path == v.path {
+			v.loading, v.tr, v.err = false, msg.tr, msg.err
+			v.render()
+		}
+		return v, nil
+	case tea.KeyMsg:
+		if v.searching {
+			return v.updateSearch(msg), nil
+		}
+		switch msg.String() {
+		case "esc", "q":
+			if v.query != "" && msg.String() == "esc" {
+				v.search("")
+				return v, nil
+			}
+			v.done = true
+			return v, nil
+		case "ctrl+c":
+			v.done = true
+			return v, nil
+		case "enter":
+			v.resume = true
+			return v, nil
+		case "]":
+			v.jump(v.prompts, 1)
+			return v, nil
+		case "[":
+			v.jump(v.prompts, -1)
+			return v, nil
+		case "/":
+			v.searching, v.input = true, ""
+			return v, nil
+		case "n":
+			v.next(1)
+			return v, nil
+		case "N":
+			v.next(-1)
+			return v, nil
+		case "t":
+			v.expanded = !v.expanded
+			v.render()
+			return v, nil
+		case "g", "home":
+			v.vp.GotoTop()
+			return v, nil
+		case "G", "end":
+			v.vp.GotoBottom()
+			return v, nil
+		}
+	}
+	var cmd tea.Cmd
+	v.vp, cmd = v.vp.Update(msg)
+	return v, cmd
+}
+
+// updateSearch edits the query being typed; enter runs it.
+func (v transcriptView) updateSearch(msg tea.KeyMsg) transcriptView {
+	switch msg.Type {
+	case tea.KeyEnter:
+		v.searching = false
+		v.search(v.input)
+		v.match = max(v.jump(v.matches, 0), 0)
+	case tea.KeyEsc, tea.KeyCtrlC:
+		v.searching = false
+	case tea.KeyBackspace:
+		if r := []rune(v.input); len(r) > 0 {
+			v.input = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		v.input += string(msg.Runes)
+	}
+	return v
+}
+
+// next moves to the next (dir 1) or previous (dir -1) search match.
+func (v *transcriptView) next(dir int) {
+	if len(v.matches) == 0 {
+		return
+	}
+	v.match = (v.match + dir + len(v.matches)) % len(v.matches)
+	v.vp.SetYOffset(v.matches[v.match])
+}
+
+// jump scrolls to the first of lines below the top of the view (dir 1), the
+// last above it (dir -1), or the first at or below it, wrapping around
+// (dir 0). Returns the index of the line it went to, or -1.
+func (v *transcriptView) jump(lines []int, dir int) int {
+	top := v.vp.YOffset
+	target := -1
+	for i, l := range lines {
+		if dir > 0 && l > top || dir == 0 && l >= top {
+			target = i
+			break
+		}
+		if dir < 0 && l < top {
+			target = i
+		}
+	}
+	if target < 0 && dir == 0 && len(lines) > 0 {
+		target = 0
+	}
+	if target >= 0 {
+		v.vp.SetYOffset(lines[target])
+	}
+	return target
+}
+
+func (v transcriptView) View() string {
+	header := "transcript"
+	if v.tr != nil {
+		header = shortID(v.tr.ID)
+		if v.tr.GitBranch != "" {
+			header += " · " + v.tr.GitBranch
+		}
+		if !v.tr.Start.IsZero() {
+			header += " · " + v.tr.Start.Local().Format("Jan 2 15:04")
+		}
+		header += fmt.Sprintf(" · %d prompts", len(v.prompts))
+	}
+
+	var footer string
+	switch {
+	case v.searching:
+		footer = "/" + v.input + "█"
+	case v.query != "" && len(v.matches) == 0:
+		footer = fmt.Sprintf("no match for %q · esc clear", v.query)
+	case v.query != "":
+		footer = fmt.Sprintf("match %d/%d · n/N next/prev · esc clear", v.match+1, len(v.matches))
+	default:
+		footer = fmt.Sprintf("[ ] prompts · / search · t tools · enter resume · q back · %3.0f%%", v.vp.ScrollPercent()*100)
+	}
+	return pickerTitleStyle.UnsetMarginBottom().Render(header) + "\n" + v.vp.View() + "\n" + pickerDimStyle.Render(footer)
+}
+
+// renderTranscript renders a conversation to lines of at most width
+// columns, returning them with the line each user prompt starts on. Tool
+// calls take a line each, plus the first line of their result, unless
+// expanded; subagent turns are marked with a bar.
+func renderTranscript(tr *session.Transcript, width int, expanded bool) (lines []string, prompts []int) {
+	results := make(map[string]session.Block)
+	for _, turn := range tr.Turns {
+		for _, b := range turn.Blocks {
+			if b.Kind == session.BlockToolResult {
+				results[b.ToolID] = b
+			}
+		}
+	}
+
+	if n := len(tr.Summaries); n > 0 {
+		lines = append(lines, wrapLines(transcriptDimStyle.Render("Summary: "+tr.Summaries[n-1]), width)...)
+		lines = append(lines, "")
+	}
+	for _, turn := range tr.Turns {
+		if turn.Meta {
+			continue
+		}
+		gutter, w := "", width
+		if turn.Sidechain {
+			gutter, w = transcriptDimStyle.Render("┃ "), width-2
+		}
+
+		var block []string
+		switch turn.Role {
+		case session.RoleUser:
+			text := turn.Text()
+			if text == "" {
+				continue // only tool results, shown with their calls
+			}
+			prompts = append(prompts, len(lines))
+			label := "▶ You"
+			if turn.Sidechain {
+				label = "▶ Subagent prompt"
+			}
+			block = append(block, transcriptUserStyle.Render(label)+"  "+transcriptDimStyle.Render(turn.Time.Local().Format("Jan 2 15:04")))
+			block = append(block, wrapLines(text, w)...)
+		case session.RoleAssistant:
+			for _, b := range turn.Blocks {
+				var part []string
+				switch b.Kind {
+				case session.BlockText:
+					part = renderMarkdown(b.Text, w-2)
+				case session.BlockThinking:
+					part = []string{transcriptDimStyle.Render("✻ Thinking…")}
+					if expanded && b.Text != "" {
+						part = append(part, wrapLines(transcriptDimStyle.Render(b.Text), w-2)...)
+					}
+				case session.BlockToolUse:
+					res, ok := results[b.ToolID]
+					part = renderToolCall(b, res, ok, w-2, expanded)
+				}
+				for _, l := range part {
+					block = append(block, "  "+l)
+				}
+			}
+			if len(block) == 0 {
+				continue
+			}
+		}
+		for _, l := range block {
+			lines = append(lines, gutter+l)
+		}
+		lines = append(lines, "")
+	}
+	return lines, prompts
+}
+
+// renderToolCall renders a tool call and its result, if it has one.
+func renderToolCall(use, result session.Block, ok bool, width int, expanded bool) []string {
+	head := transcriptToolStyle.Render("● " + use.Tool)
+	if summary := toolSummary(use.Input); summary != "" {
+		head += transcriptDimStyle.Render("(" + ansi.Truncate(summary, max(width-len(use.Tool)-4, 10), "…") + ")")
+	}
+	lines := []string{head}
+	if expanded && len(use.Input) > 0 {
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, use.Input, "", "  ") == nil {
+			lines = append(lines, wrapLines(transcriptDimStyle.Render(pretty.String()), width)...)
+		}
+	}
+	if !ok {
+		return lines
+	}
+
+	style := transcriptDimStyle
+	if result.IsError {
+		style = transcriptErrorStyle
+	}
+	out := strings.Split(strings.TrimRight(result.Text, "\n"), "\n")
+	if !expanded {
+		summary := out[0]
+		if len(out) > 1 {
+			summary += fmt.Sprintf(" … +%d lines", len(out)-1)
+		}
+		return append(lines, style.Render("  ⎿ "+ansi.Truncate(summary, max(width-4, 10), "…")))
+	}
+	for i, l := range wrapLines(strings.Join(out, "\n"), width-4) {
+		prefix := "    "
+		if i == 0 {
+			prefix = "  ⎿ "
+		}
+		lines = append(lines, style.Render(prefix+l))
+	}
+	return lines
+}
+
+// toolSummaryKeys are the tool arguments that best describe a call, in
+// order of preference.
+var toolSummaryKeys = []string{"command", "file_path", "path", "pattern", "url", "query", "description", "prompt"}
+
+// toolSummary describes a tool call's arguments in one line.
+func toolSummary(input json.RawMessage) string {
+	var args map[string]any
+	if json.Unmarshal(input, &args) != nil || len(args) == 0 {
+		return ""
+	}
+	for _, k := range toolSummaryKeys {
+		if s, ok := args[k].(string); ok && s != "" {
+			first, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+			return first
+		}
+	}
+	var compact bytes.Buffer
+	json.Compact(&compact, input)
+	return compact.String()
+}
+
+var (
+	mdBold    = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	mdCode    = regexp.MustCompile("`([^`]+)`")
+	mdHeading = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	mdItem    = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+)
+
+// renderMarkdown renders the markdown an assistant writes: headings, lists,
+// quotes, fenced code and inline bold and code. Everything else is wrapped
+// as is.
+func renderMarkdown(text string, width int) []string {
+	var lines []string
+	inCode := false
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			lines = append(lines, mdCodeStyle.Render(ansi.Truncate("  "+line, width, "…")))
+			continue
+		}
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			style := mdBoldStyle
+			if len(m[1]) == 1 {
+				style = mdHeadingStyle
+			}
+			lines = append(lines, wrapLines(style.Render(m[2]), width)...)
+			continue
+		}
+		if m := mdItem.FindStringSubmatch(line); m != nil {
+			bullet := m[2]
+			if !strings.ContainsAny(bullet, ".)") {
+				bullet = "•"
+			}
+			lines = append(lines, hangingWrap(m[1]+bullet+" ", mdInline(m[3]), width)...)
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, ">"); ok {
+			lines = append(lines, hangingWrap("│ ", transcriptDimStyle.Render(strings.TrimSpace(rest)), width)...)
+			continue
+		}
+		lines = append(lines, wrapLines(mdInline(line), width)...)
+	}
+	return lines
+}
+
+// mdInline styles inline code and bold text.
+func mdInline(s string) string {
+	s = mdCode.ReplaceAllStringFunc(s, func(m string) string { return mdCodeStyle.Render(m[1 : len(m)-1]) })
+	return mdBold.ReplaceAllStringFunc(s, func(m string) string { return mdBoldStyle.Render(m[2 : len(m)-2]) })
+}
+
+// hangingWrap wraps s after prefix, indenting the continuation lines to
+// line up with the text.
+func hangingWrap(prefix, s string, width int) []string {
+	indent := strings.Repeat(" ", ansi.StringWidth(prefix))
+	lines := wrapLines(s, max(width-len(indent), 10))
+	for i := range lines {
+		if i == 0 {
+			lines[i] = prefix + lines[i]
+		} else {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return lines
+}
+
+// wrapLines wraps styled text to width columns, breaking words that don't
+// fit.
+func wrapLines(s string, width int) []string {
+	return strings.Split(ansi.Wrap(s, max(width, 10), ""), "\n")
+}
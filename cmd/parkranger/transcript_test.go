@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"github.com/grins/parkranger/internal/session"
+)
+
+const transcriptJSONL = `{"type":"summary","summary":"Listing files","leafUuid":"a2"}
+{"type":"user","uuid":"u1","timestamp":"2025-06-01T10:00:00Z","message":{"role":"user","content":"List the Go files"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-06-01T10:00:01Z","message":{"id":"m1","role":"assistant","content":[{"type":"thinking","thinking":"Use ls."}]}}
+{"type":"assistant","uuid":"a2","timestamp":"2025-06-01T10:00:02Z","message":{"id":"m1","role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"ls *.go","description":"List"}}]}}
+{"type":"user","uuid":"u2","timestamp":"2025-06-01T10:00:03Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"main.go\nmain_test.go"}]}}
+{"type":"user","uuid":"x1","isSidechain":true,"timestamp":"2025-06-01T10:00:04Z","message":{"role":"user","content":"Find TODOs"}}
+{"type":"assistant","uuid":"a3","timestamp":"2025-06-01T10:00:05Z","message":{"id":"m2","role":"assistant","content":[{"type":"text","text":"There are **two** files:\n\n- main.go\n- main_test.go"}]}}
+{"type":"user","uuid":"u3","isMeta":true,"timestamp":"2025-06-01T10:00:06Z","message":{"role":"user","content":"<command-name>/clear</command-name>"}}
+{"type":"user","uuid":"u4","timestamp":"2025-06-01T10:00:07Z","message":{"role":"user","content":"Thanks"}}
+`
+
+func testTranscript(t *testing.T) *session.Transcript {
+	t.Helper()
+	tr := &session.Transcript{}
+	for e, err := range session.ReadTranscript(strings.NewReader(transcriptJSONL)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr.Add(e)
+	}
+	return tr
+}
+
+func plainLines(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = ansi.Strip(l)
+	}
+	return out
+}
+
+func TestRenderTranscript(t *testing.T) {
+	tr := testTranscript(t)
+	lines, prompts := renderTranscript(tr, 80, false)
+	plain := plainLines(lines)
+	text := strings.Join(plain, "\n")
+
+	if len(prompts) != 3 {
+		t.Fatalf("prompts at %v, want 3 (meta and tool results skipped)", prompts)
+	}
+	for _, p := range prompts {
+		if !strings.Contains(plain[p], "▶") {
+			t.Errorf("prompt line %d = %q", p, plain[p])
+		}
+	}
+	for _, want := range []string{
+		"Summary: Listing files",
+		"  ✻ Thinking…",
+		"  ● Bash(ls *.go)",
+		"    ⎿ main.go … +1 lines",
+		"┃ ▶ Subagent prompt",
+		"  There are two files:",
+		"  • main.go",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "Use ls.") || strings.Contains(text, "/clear") {
+		t.Errorf("collapsed view shows thinking or meta turns:\n%s", text)
+	}
+
+	lines, _ = renderTranscript(tr, 80, true)
+	text = strings.Join(plainLines(lines), "\n")
+	for _, want := range []string{"Use ls.", `"description": "List"`, "    ⎿ main.go\n      main_test.go"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expanded view misses %q in\n%s", want, text)
+		}
+	}
+	for _, l := range lines {
+		if w := ansi.StringWidth(l); w > 80 {
+			t.Errorf("line wider than 80 (%d): %q", w, ansi.Strip(l))
+		}
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	md := "# Plan\n\nRun `go test` first, it is **fast**.\n\n1. one\n2. a longer item that wraps around\n\n```go\nfunc main() {}\n```\n> quoted"
+	got := plainLines(renderMarkdown(md, 20))
+	want := []string{
+		"Plan",
+		"",
+		"Run go test first,",
+		"it is fast.",
+		"",
+		"1. one",
+		"2. a longer item",
+		"   that wraps around",
+		"",
+		"  func main() {}",
+		"│ quoted",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("renderMarkdown =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestTranscriptView(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "abc.jsonl")
+	if err := os.WriteFile(path, []byte(transcriptJSONL), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	v, load := newTranscriptView(path, 80, 10)
+	if !strings.Contains(v.View(), "loading") {
+		t.Errorf("view before the file is read:\n%s", v.View())
+	}
+	// The file is parsed in the command, not while creating the view
+	v, _ = v.Update(load())
+	if v.loading || v.tr == nil || len(v.prompts) == 0 {
+		t.Fatalf("after loading: loading %v, %d prompts", v.loading, len(v.prompts))
+	}
+	key := func(k string) {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		}
+		v, _ = v.Update(msg)
+	}
+
+	// The view stops scrolling once the last line is at the bottom
+	at := func(line int) int { return min(line, v.vp.TotalLineCount()-v.vp.Height) }
+	key("]")
+	key("]")
+	if v.vp.YOffset != at(v.prompts[1]) {
+		t.Errorf("]] went to line %d, want the second prompt at %d", v.vp.YOffset, v.prompts[1])
+	}
+	key("[")
+	if v.vp.YOffset != at(v.prompts[0]) {
+		t.Errorf("[ went to line %d, want the first prompt at %d", v.vp.YOffset, v.prompts[0])
+	}
+
+	for _, k := range []string{"/", "t", "h", "a", "n", "k", "s", "enter"} {
+		key(k)
+	}
+	if v.query != "thanks" || len(v.matches) != 1 || v.vp.YOffset != at(v.matches[0]) {
+		t.Errorf("search %q: matches %v, at line %d", v.query, v.matches, v.vp.YOffset)
+	}
+	key("esc")
+	if v.query != "" || v.done {
+		t.Errorf("esc should clear the search first: query %q, done %v", v.query, v.done)
+	}
+	key("enter")
+	if !v.resume {
+		t.Error("enter didn't ask to resume")
+	}
+}
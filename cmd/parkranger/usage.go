@@ -0,0 +1,201 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/grins/parkranger/internal/session"
+	"github.com/grins/parkranger/internal/worktree"
+)
+
+// sessionUsage totals a session's responses since the given time (zero for
+// all of them), to the nearest session.UsageSlot. Unreadable files count as
+// empty.
+func sessionUsage(s session.Session, since time.Time, price session.Pricer) session.UsageTotal {
+	var total session.UsageTotal
+	if s.Path == "" || s.ModTime.Before(since) {
+		return total
+	}
+	records, _ := session.ReadUsage(s.Path)
+	for _, r := range records {
+		if r.Time.Add(session.UsageSlot).After(since) {
+			total.Add(r, price)
+		}
+	}
+	return total
+}
+
+// worktreeUsage totals every Claude session run in a worktree, priced with
+// the config of the repo at root.
+func worktreeUsage(root string, wt worktree.Worktree) session.UsageTotal {
+	var total session.UsageTotal
+	price := repoConfig(root).PriceFor
+	sessions, _ := session.ListSessions(wt.Path)
+	for _, s := range sessions {
+		total.Merge(sessionUsage(s, time.Time{}, price))
+	}
+	return total
+}
+
+// formatCost renders an estimated cost, "$4.20", with a "+" when responses
+// from unpriced models were left out. Empty when nothing was counted.
+func formatCost(t session.UsageTotal) string {
+	if t.Responses == 0 {
+		return ""
+	}
+	s := fmt.Sprintf("$%.2f", t.Cost)
+	if t.Unpriced > 0 {
+		s += "+"
+	}
+	return s
+}
+
+// formatTokens abbreviates a token count: "950", "12.3k", "4.5M".
+func formatTokens(n int) string {
+	switch {
+	case n < 1000:
+		return strconv.Itoa(n)
+	case n < 1_000_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	default:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	}
+}
+
+// parseSince parses a look-back period: a Go duration, or whole days
+// ("7d") or weeks ("2w").
+func parseSince(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			if v, err := strconv.Atoi(n); err == nil && v >= 0 {
+				return time.Duration(v) * unit, nil
+			}
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid period %q (want e.g. 7d, 2w or 12h)", s)
+	}
+	return d, nil
+}
+
+// usageGroupings are the --by values of `parkranger usage`.
+var usageGroupings = []string{"repo", "worktree", "day"}
+
+// cmdUsage handles `parkranger usage [--since 7d] [--by repo|worktree|day]`:
+// token usage and estimated cost of the Claude sessions in the dashboard's
+// repos.
+func cmdUsage(args []string) error {
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	sinceFlag := fs.String("since", "", "only count responses from this `period`, e.g. 7d, 2w or 12h (default: all)")
+	by := fs.String("by", "repo", "group by `key`: repo, worktree or day")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: parkranger usage [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || !slices.Contains(usageGroupings, *by) {
+		fs.Usage()
+		return fmt.Errorf("--by takes one of %s", strings.Join(usageGroupings, ", "))
+	}
+	var since time.Time
+	if *sinceFlag != "" {
+		d, err := parseSince(*sinceFlag)
+		if err != nil {
+			return err
+		}
+		since = time.Now().Add(-d)
+	}
+
+	repos, err := dashboardRepos()
+	if err != nil {
+		return err
+	}
+	totals := make(map[string]*session.UsageTotal)
+	var keys []string
+	add := func(key string, r session.UsageRecord, price session.Pricer) {
+		t, ok := totals[key]
+		if !ok {
+			t = &session.UsageTotal{}
+			totals[key] = t
+			keys = append(keys, key)
+		}
+		t.Add(r, price)
+	}
+	for _, r := range repos {
+		price := repoConfig(r.root).PriceFor
+		for _, wt := range r.wts {
+			sessions, _ := session.ListSessions(wt.Path)
+			for _, s := range sessions {
+				if s.ModTime.Before(since) {
+					continue // nothing written since
+				}
+				records, _ := session.ReadUsage(s.Path)
+				for _, rec := range records {
+					if !rec.Time.Add(session.UsageSlot).After(since) {
+						continue // the slot ended before the period
+					}
+					switch *by {
+					case "repo":
+						add(r.name, rec, price)
+					case "worktree":
+						add(r.name+"/"+wt.Name, rec, price)
+					case "day":
+						add(rec.Time.Local().Format(time.DateOnly), rec, price)
+					}
+				}
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		fmt.Println("No Claude usage recorded")
+		return nil
+	}
+	if *by == "day" {
+		slices.Sort(keys)
+	} else {
+		slices.SortStableFunc(keys, func(a, b string) int {
+			return cmp.Compare(totals[b].Cost, totals[a].Cost)
+		})
+	}
+	printUsageTable(*by, keys, totals)
+	return nil
+}
+
+// printUsageTable prints one row per key and a total.
+func printUsageTable(by string, keys []string, totals map[string]*session.UsageTotal) {
+	// Numbers align right; padding the keys keeps them left-aligned
+	width := len(by)
+	for _, k := range keys {
+		width = max(width, len(k))
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%-*s\tresponses\tinput\toutput\tcache write\tcache read\tcost\t\n", width, strings.ToUpper(by))
+	row := func(key string, t session.UsageTotal) {
+		fmt.Fprintf(w, "%-*s\t%d\t%s\t%s\t%s\t%s\t%s\t\n", width, key, t.Responses,
+			formatTokens(t.InputTokens), formatTokens(t.OutputTokens),
+			formatTokens(t.CacheCreationTokens), formatTokens(t.CacheReadTokens), formatCost(t))
+	}
+	var sum session.UsageTotal
+	for _, k := range keys {
+		row(k, *totals[k])
+		sum.Merge(*totals[k])
+	}
+	if len(keys) > 1 {
+		row("total", sum)
+	}
+	w.Flush()
+	if sum.Unpriced > 0 {
+		fmt.Printf("\n+ %d responses from models without a price are not in the cost; add them under [prices]\n", sum.Unpriced)
+	}
+}
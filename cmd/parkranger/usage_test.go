@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grins/parkranger/internal/session"
+)
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"7d", 7 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"0d", 0, false},
+		{"-1d", 0, true},
+		{"-2h", 0, true},
+		{"d", 0, true},
+		{"week", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseSince(%q) = %v, %v; want %v, err %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestFormatTokens(t *testing.T) {
+	tests := map[int]string{0: "0", 950: "950", 12_345: "12.3k", 4_500_000: "4.5M"}
+	for n, want := range tests {
+		if got := formatTokens(n); got != want {
+			t.Errorf("formatTokens(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestFormatCost(t *testing.T) {
+	tests := []struct {
+		total session.UsageTotal
+		want  string
+	}{
+		{session.UsageTotal{}, ""},
+		{session.UsageTotal{Responses: 3, Cost: 4.199}, "$4.20"},
+		{session.UsageTotal{Responses: 3, Cost: 1, Unpriced: 1}, "$1.00+"},
+		{session.UsageTotal{Responses: 1, Unpriced: 1}, "$0.00+"},
+	}
+	for _, tt := range tests {
+		if got := formatCost(tt.total); got != tt.want {
+			t.Errorf("formatCost(%+v) = %q, want %q", tt.total, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+	if err := Write(path, []byte("one\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(path, []byte("two\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "two\n" {
+		t.Errorf("content = %q, want two", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("dir holds %d files, want no temp files left", len(entries))
+	}
+}
+
+// TestLock runs read-increment-write cycles from many goroutines, each on
+// its own lock file descriptor as separate processes would be: none of the
+// increments may be lost.
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	if err := Write(path, []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+	const n = 20
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := Lock(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer unlock()
+			data, _ := os.ReadFile(path)
+			count, _ := strconv.Atoi(string(data))
+			if err := Write(path, []byte(strconv.Itoa(count+1))); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if data, _ := os.ReadFile(path); string(data) != strconv.Itoa(n) {
+		t.Errorf("counter = %s, want %d", data, n)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package atomicfile
+
+import "os"
+
+// lock is only implemented on Unix; elsewhere writers rely on the rename
+// alone.
+func lock(f *os.File) error { return nil }
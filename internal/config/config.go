@@ -0,0 +1,408 @@
+// Package config loads parkranger's layered TOML configuration: built-in
+// defaults, overlaid by the global file (~/.config/parkranger/config.toml),
+// overlaid by the repo file (<repo>/.parkranger.toml). Later layers only
+// replace the keys they set.
+package config
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/grins/parkranger/internal/xdg"
+)
+
+// RepoFile is the per-repo config file name, looked up in the main repo root.
+const RepoFile = ".parkranger.toml"
+
+// Config is the merged configuration for one repo.
+type Config struct {
+	PollInterval Duration          `toml:"poll_interval"`    // agent detection cadence (global file only)
+	Hysteresis   int               `toml:"busy_hysteresis"`  // polls in a row before busy turns idle (global file only)
+	QuietAfter   Duration          `toml:"quiet_after"`      // unchanged this long reads as idle; "0s" disables (global file only)
+	Multiplexer  string            `toml:"multiplexer"`      // "tmux", "zellij" or "wezterm" (global file only)
+	WorktreeRoot string            `toml:"worktree_root"`    // where new worktrees go; see WorktreePath
+	Editor       string            `toml:"editor,omitempty"` // {editor} in layouts; empty uses $EDITOR, then nvim
+	Agent        string            `toml:"agent"`            // profile new windows launch: "claude" or an [[agents]] name
+	AgentCommand string            `toml:"agent_command"`    // command of the built-in claude profile
+	Agents       []Agent           `toml:"agents"`           // extra agent profiles
+	Layout       string            `toml:"layout"`           // name of the layout for new windows
+	Layouts      map[string]Layout `toml:"layouts"`          // available layouts by name
+	Prices       map[string]Price  `toml:"prices"`           // USD per million tokens, by model ID
+	Keys         Keys              `toml:"keys"`
+	Notify       Notify            `toml:"notify"`
+	Hooks        Hooks             `toml:"hooks"`
+
+	// Rules are the user's classification rules, from RulesPath.
+	Rules RuleFile `toml:"-"`
+
+	unknown []string // keys in the files that matched no field, as "file: key"
+	ignored []string // global-only keys set in a repo file, as "file: key"
+	sinks   bool     // a file set notify.sinks
+}
+
+// globalOnlyKeys are read from the global file only: they configure the
+// detection loop and multiplexer, which serve every repo at once.
+var globalOnlyKeys = []string{"poll_interval", "busy_hysteresis", "quiet_after", "multiplexer"}
+
+// ClaudeAgent is the name of the built-in Claude Code profile.
+const ClaudeAgent = "claude"
+
+// Agent is a data-driven agent profile: how to launch and resume it, which
+// process marks its panes, and regex rules that classify its screen.
+type Agent struct {
+	Name    string      `toml:"name"`
+	Command string      `toml:"command"`           // launch command
+	Process string      `toml:"process,omitempty"` // process name in panes; default: the command's program
+	Resume  string      `toml:"resume,omitempty"`  // resume command, {id} is the session; empty if unsupported
+	History string      `toml:"history,omitempty"` // glob of session files, {worktree} is the worktree path; file names are the IDs
+	Rules   []AgentRule `toml:"rules,omitempty"`   // first match wins
+}
+
+// AgentRule maps a regular expression on the agent's screen to a status.
+type AgentRule struct {
+	Pattern string `toml:"pattern"`          // RE2 syntax, multi-line mode (^ and $ match at lines)
+	Status  string `toml:"status"`           // "waiting", "busy", "idle" or "error"
+	Bottom  int    `toml:"bottom,omitempty"` // only search the bottom N lines, counted from the last non-blank one; 0 = whole capture
+}
+
+// ProcessName returns the process name marking the agent's panes.
+func (a Agent) ProcessName() string {
+	if a.Process != "" {
+		return a.Process
+	}
+	return CommandProgram(a.Command)
+}
+
+// Keys are the dashboard key bindings. Each action takes one or more keys
+// in Bubble Tea notation ("k", "up", "ctrl+n"); the first is shown in hints.
+type Keys struct {
+	Up      []string `toml:"up"`
+	Down    []string `toml:"down"`
+	Open    []string `toml:"open"`
+	New     []string `toml:"new"`
+	Merge   []string `toml:"merge"`
+	Delete  []string `toml:"delete"`
+	Refresh []string `toml:"refresh"`
+	Preview []string `toml:"preview"`
+	Restart []string `toml:"restart"` // restart an exited agent, resuming its session
+	Quit    []string `toml:"quit"`
+}
+
+// WorktreePath returns where a new worktree called name goes for the repo at
+// root. {repo} in WorktreeRoot is the repo's directory name, a leading ~ is
+// the home directory, and relative roots are relative to the repo.
+func (c Config) WorktreePath(root, name string) string {
+	dir := strings.ReplaceAll(c.WorktreeRoot, "{repo}", filepath.Base(root))
+	if rest, ok := strings.CutPrefix(dir, "~"); ok && (rest == "" || rest[0] == '/') {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = home + rest
+		}
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return filepath.Join(dir, name)
+}
+
+// CommandProgram returns the program name of a shell command: "claude" for
+// "/usr/local/bin/claude --model opus".
+func CommandProgram(cmd string) string {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return ""
+	}
+	return filepath.Base(fields[0])
+}
+
+// EditorCommand returns the command {editor} expands to.
+func (c Config) EditorCommand() string {
+	if c.Editor != "" {
+		return c.Editor
+	}
+	if e := os.Getenv("EDITOR"); e != "" {
+		return e
+	}
+	return "nvim"
+}
+
+// Layout describes the panes of a worktree's window, plus optional extra
+// windows (dev server, test watcher) created alongside it.
+type Layout struct {
+	Pane
+	Windows []Window `toml:"windows,omitempty"`
+}
+
+// Window is an extra window, named "<worktree>/<name>".
+type Window struct {
+	Name string `toml:"name"`
+	Pane
+}
+
+// Pane is a node in a pane tree. A pane with children is split along Split
+// and runs no command itself; a leaf runs Command in the worktree directory.
+//
+// Commands may use {editor} ($EDITOR, default nvim) and {agent} (the agent
+// command, with --resume when resuming). An agent leaf with no command runs
+// {agent}. Agent panes are the ones status detection follows.
+type Pane struct {
+	Command string `toml:"command,omitempty"`
+	Agent   bool   `toml:"agent,omitempty"`
+	Size    string `toml:"size,omitempty"`  // share of the parent, e.g. "30%"; unset panes split the rest evenly
+	Split   string `toml:"split,omitempty"` // "horizontal" (side by side) or "vertical" (stacked)
+	Panes   []Pane `toml:"panes,omitempty"`
+}
+
+// SelectedLayout returns the layout named by Layout, falling back to the
+// built-in default when it isn't defined.
+func (c Config) SelectedLayout() Layout {
+	if l, ok := c.Layouts[c.Layout]; ok {
+		return l
+	}
+	return DefaultLayout()
+}
+
+// DefaultLayout is the classic editor-left, agent-right split.
+func DefaultLayout() Layout {
+	return Layout{Pane: Pane{
+		Split: "horizontal",
+		Panes: []Pane{
+			{Command: "{editor} ."},
+			{Command: "{agent}", Agent: true},
+		},
+	}}
+}
+
+// Notify configures agent status notifications.
+type Notify struct {
+	Enabled  bool     `toml:"enabled"`
+	On       []string `toml:"on"`                // statuses that trigger after busy: "waiting", "idle", "error", "exited"
+	Sinks    []string `toml:"sinks"`             // "bell", "osc9", "osc777", "command", "tmux"; DefaultSinks if unset
+	Command  string   `toml:"command,omitempty"` // shell command for the "command" sink
+	Cooldown Duration `toml:"cooldown"`          // minimum gap between notifications per worktree
+}
+
+// DefaultSinks returns the notify sinks used when no config file sets them:
+// the tmux status line under tmux, where it is always visible, and a
+// terminal notification elsewhere, since tmux isn't there to display one.
+func DefaultSinks(multiplexer string) []string {
+	switch multiplexer {
+	case "tmux":
+		return []string{"tmux"}
+	case "wezterm":
+		return []string{"osc9"} // shown as a desktop toast
+	default:
+		return []string{"bell"}
+	}
+}
+
+// Hooks maps lifecycle events to shell commands. Each command runs with
+// `sh -c`, receives a JSON payload on stdin and PARKRANGER_* env vars.
+type Hooks struct {
+	Timeout              Duration `toml:"timeout"`                // per command
+	SessionOpenedTimeout Duration `toml:"session_opened_timeout"` // per session_opened command, which delays attaching
+	WorktreeCreated      []string `toml:"worktree_created"`
+	SessionOpened        []string `toml:"session_opened"`
+	AgentWaiting         []string `toml:"agent_waiting"`
+	MergeCompleted       []string `toml:"merge_completed"`
+	WorktreeDeleted      []string `toml:"worktree_deleted"`
+}
+
+// Price is what a model costs, in US dollars per million tokens.
+type Price struct {
+	Input      float64 `toml:"input"`
+	Output     float64 `toml:"output"`
+	CacheWrite float64 `toml:"cache_write"`
+	CacheRead  float64 `toml:"cache_read"`
+}
+
+// Cost returns the price of the given token counts.
+func (p Price) Cost(input, output, cacheWrite, cacheRead int) float64 {
+	return (float64(input)*p.Input + float64(output)*p.Output +
+		float64(cacheWrite)*p.CacheWrite + float64(cacheRead)*p.CacheRead) / 1e6
+}
+
+// PriceFor returns the price of a model: the entry of Prices keyed by its
+// ID, with or without the snapshot date ("claude-sonnet-4-5" prices
+// "claude-sonnet-4-5-20250929"). Keys don't match as family prefixes, since
+// newer models in a family are often priced differently ("claude-opus-4"
+// doesn't price "claude-opus-4-5").
+func (c Config) PriceFor(model string) (Price, bool) {
+	if p, ok := c.Prices[model]; ok {
+		return p, true
+	}
+	if i := strings.LastIndexByte(model, '-'); i > 0 && isDate(model[i+1:]) {
+		p, ok := c.Prices[model[:i]]
+		return p, ok
+	}
+	return Price{}, false
+}
+
+// isDate reports whether s is a model snapshot date (YYYYMMDD).
+func isDate(s string) bool {
+	if len(s) != 8 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Duration is a time.Duration written as a string ("30s", "2m") in TOML.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses a Go duration string.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalText formats the duration as a Go duration string.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Default returns the built-in configuration.
+func Default() Config {
+	return Config{
+		PollInterval: Duration{500 * time.Millisecond},
+		Hysteresis:   2,
+		QuietAfter:   Duration{10 * time.Second},
+		Multiplexer:  "tmux",
+		WorktreeRoot: "../.worktrees/{repo}",
+		Agent:        ClaudeAgent,
+		AgentCommand: "claude",
+		Layout:       "default",
+		Layouts:      map[string]Layout{"default": DefaultLayout()},
+		Keys: Keys{
+			Up:      []string{"up", "k"},
+			Down:    []string{"down", "j"},
+			Open:    []string{"enter"},
+			New:     []string{"n"},
+			Merge:   []string{"m"},
+			Delete:  []string{"d"},
+			Refresh: []string{"r"},
+			Preview: []string{"p"},
+			Restart: []string{"R"},
+			Quit:    []string{"q", "esc", "ctrl+c"},
+		},
+		Notify: Notify{
+			Enabled:  true,
+			On:       []string{"waiting", "idle"},
+			Sinks:    DefaultSinks("tmux"),
+			Command:  `notify-send "$PARKRANGER_TITLE" "$PARKRANGER_BODY"`,
+			Cooldown: Duration{30 * time.Second},
+		},
+		Hooks: Hooks{
+			Timeout:              Duration{2 * time.Minute},
+			SessionOpenedTimeout: Duration{10 * time.Second},
+		},
+		// Keyed by model ID and alias; dated snapshots match their ID
+		Prices: map[string]Price{
+			"claude-opus-4-6":   {Input: 5, Output: 25, CacheWrite: 6.25, CacheRead: 0.5},
+			"claude-opus-4-5":   {Input: 5, Output: 25, CacheWrite: 6.25, CacheRead: 0.5},
+			"claude-opus-4-1":   {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.5},
+			"claude-opus-4":     {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.5},
+			"claude-opus-4-0":   {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.5},
+			"claude-sonnet-4-6": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+			"claude-sonnet-4-5": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+			"claude-sonnet-4":   {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+			"claude-sonnet-4-0": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+			"claude-haiku-4-5":  {Input: 1, Output: 5, CacheWrite: 1.25, CacheRead: 0.1},
+			"claude-3-7-sonnet": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+			"claude-3-5-sonnet": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+			"claude-3-5-haiku":  {Input: 0.8, Output: 4, CacheWrite: 1, CacheRead: 0.08},
+		},
+	}
+}
+
+// GlobalPath returns the global config file: <config dir>/config.toml.
+func GlobalPath() string {
+	return filepath.Join(xdg.ConfigDir(), "config.toml")
+}
+
+// RepoPath returns the per-repo config file for the repo at root.
+func RepoPath(root string) string {
+	return filepath.Join(root, RepoFile)
+}
+
+// Load returns the configuration for the repo at root (global only if root
+// is empty). Missing files are skipped; malformed ones are an error.
+func Load(root string) (Config, error) {
+	cfg := Default()
+	for i, path := range Paths(root) {
+		if err := overlay(&cfg, path, i > 0); err != nil {
+			return cfg, err
+		}
+	}
+	if !cfg.sinks {
+		cfg.Notify.Sinks = DefaultSinks(cfg.Multiplexer)
+	}
+	rules, err := loadRules(RulesPath())
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Rules = rules
+	return cfg, nil
+}
+
+// Paths returns the config files Load reads for the repo at root, lowest
+// precedence first.
+func Paths(root string) []string {
+	paths := []string{GlobalPath()}
+	if root != "" {
+		paths = append(paths, RepoPath(root))
+	}
+	return paths
+}
+
+// Encode writes the config as TOML, in the same shape Load reads.
+func (c Config) Encode(w io.Writer) error {
+	enc := toml.NewEncoder(w)
+	enc.Indent = ""
+	return enc.Encode(c)
+}
+
+// overlay decodes the file at path on top of cfg. A repo file's global-only
+// keys are noted and left out.
+func overlay(cfg *Config, path string, repo bool) error {
+	global := *cfg
+	md, err := toml.DecodeFile(path, cfg)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, key := range md.Undecoded() {
+		cfg.unknown = append(cfg.unknown, path+": "+key.String())
+	}
+	if md.IsDefined("notify", "sinks") {
+		cfg.sinks = true
+	}
+	if repo {
+		for _, key := range globalOnlyKeys {
+			if md.IsDefined(key) {
+				cfg.ignored = append(cfg.ignored, path+": "+key)
+			}
+		}
+		cfg.PollInterval, cfg.Hysteresis = global.PollInterval, global.Hysteresis
+		cfg.QuietAfter, cfg.Multiplexer = global.QuietAfter, global.Multiplexer
+	}
+	return nil
+}
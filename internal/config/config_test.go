@@ -0,0 +1,252 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Notify.Enabled || cfg.Notify.Cooldown.Duration != 30*time.Second {
+		t.Errorf("defaults = %+v", cfg.Notify)
+	}
+}
+
+func TestLoad_Layered(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	repo := t.TempDir()
+
+	writeFile(t, filepath.Join(configHome, "parkranger", "config.toml"), `
+[notify]
+sinks = ["bell", "command"]
+cooldown = "1m"
+`)
+	writeFile(t, filepath.Join(repo, RepoFile), `
+[notify]
+enabled = false
+`)
+
+	global, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !global.Notify.Enabled || len(global.Notify.Sinks) != 2 || global.Notify.Cooldown.Duration != time.Minute {
+		t.Errorf("global = %+v", global.Notify)
+	}
+
+	cfg, err := Load(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Notify.Enabled {
+		t.Error("repo file should disable notifications")
+	}
+	// Keys the repo file doesn't set come from the global file
+	if len(cfg.Notify.Sinks) != 2 || cfg.Notify.Cooldown.Duration != time.Minute {
+		t.Errorf("repo = %+v", cfg.Notify)
+	}
+	// ...and keys neither file sets keep their defaults
+	if len(cfg.Notify.On) != 2 {
+		t.Errorf("On = %v", cfg.Notify.On)
+	}
+}
+
+func TestLoad_DefaultSinks(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	global := filepath.Join(configHome, "parkranger", "config.toml")
+
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{``, "tmux"},
+		{`multiplexer = "zellij"`, "bell"},
+		{`multiplexer = "wezterm"`, "osc9"},
+		{"multiplexer = \"zellij\"\n[notify]\nsinks = [\"tmux\"]", "tmux"}, // set explicitly
+	}
+	for _, tt := range tests {
+		writeFile(t, global, tt.config)
+		cfg, err := Load("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfg.Notify.Sinks) != 1 || cfg.Notify.Sinks[0] != tt.want {
+			t.Errorf("%q: sinks = %v, want %s", tt.config, cfg.Notify.Sinks, tt.want)
+		}
+	}
+}
+
+func TestLoad_Malformed(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, RepoFile), `[notify`)
+
+	if _, err := Load(repo); err == nil {
+		t.Error("expected error for malformed config")
+	}
+}
+
+func TestDuration_Invalid(t *testing.T) {
+	var d Duration
+	if err := d.UnmarshalText([]byte("soon")); err == nil {
+		t.Error("expected error for invalid duration")
+	}
+}
+
+func TestLoad_Layouts(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, RepoFile), `
+layout = "triple"
+
+[layouts.triple]
+split = "horizontal"
+
+[[layouts.triple.panes]]
+command = "{editor} ."
+size = "60%"
+
+[[layouts.triple.panes]]
+split = "vertical"
+
+[[layouts.triple.panes.panes]]
+agent = true
+
+[[layouts.triple.panes.panes]]
+command = "git status"
+
+[[layouts.triple.windows]]
+name = "server"
+command = "npm run dev"
+`)
+
+	cfg, err := Load(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := cfg.SelectedLayout()
+	if l.Split != "horizontal" || len(l.Panes) != 2 || l.Panes[0].Size != "60%" {
+		t.Fatalf("layout = %+v", l)
+	}
+	right := l.Panes[1]
+	if right.Split != "vertical" || len(right.Panes) != 2 || !right.Panes[0].Agent {
+		t.Errorf("right = %+v", right)
+	}
+	if len(l.Windows) != 1 || l.Windows[0].Name != "server" || l.Windows[0].Command != "npm run dev" {
+		t.Errorf("windows = %+v", l.Windows)
+	}
+	if _, ok := cfg.Layouts["default"]; !ok {
+		t.Error("built-in default layout should survive user layouts")
+	}
+}
+
+func TestSelectedLayout_Fallback(t *testing.T) {
+	cfg := Default()
+	cfg.Layout = "missing"
+	l := cfg.SelectedLayout()
+	if len(l.Panes) != 2 || !l.Panes[1].Agent {
+		t.Errorf("fallback = %+v", l)
+	}
+}
+
+func TestAgent_ProcessName(t *testing.T) {
+	tests := []struct {
+		agent Agent
+		want  string
+	}{
+		{Agent{Command: "aider"}, "aider"},
+		{Agent{Command: "/opt/bin/aider --no-pretty"}, "aider"},
+		{Agent{Command: "npx @google/gemini-cli", Process: "gemini"}, "gemini"},
+		{Agent{}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.agent.ProcessName(); got != tt.want {
+			t.Errorf("ProcessName(%+v) = %q, want %q", tt.agent, got, tt.want)
+		}
+	}
+}
+
+func TestLoad_Rules(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	writeFile(t, filepath.Join(configHome, "parkranger", "rules.toml"), `
+builtin = false
+
+[[rules]]
+name = "compacting"
+status = "busy"
+  [[rules.when]]
+  pattern = "Compacting"
+  bottom = 3
+`)
+
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Rules.KeepBuiltin() || len(cfg.Rules.Rules) != 1 {
+		t.Fatalf("rules = %+v", cfg.Rules)
+	}
+	r := cfg.Rules.Rules[0]
+	if r.Name != "compacting" || !r.AgentVisible() || r.When[0].Bottom != 3 {
+		t.Errorf("rule = %+v", r)
+	}
+}
+
+func TestPriceFor(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, RepoFile), `
+[prices.claude-sonnet-4-5]
+input = 4
+output = 20
+`)
+	cfg, err := Load(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		model string
+		want  float64 // cost of a million input and a million output tokens
+		ok    bool
+	}{
+		{"claude-sonnet-4-20250514", 18, true},
+		{"claude-sonnet-4-5-20250929", 24, true}, // overridden in the repo
+		{"claude-sonnet-4-5", 24, true},
+		{"claude-opus-4-20250514", 90, true},
+		{"claude-opus-4-1-20250805", 90, true},
+		{"claude-opus-4-5-20251101", 30, true}, // not priced as claude-opus-4
+		{"claude-opus-4-6", 30, true},
+		{"claude-haiku-4-5-20251001", 6, true},
+		{"claude-3-5-haiku-20241022", 4.8, true},
+		{"claude-opus-4-9", 0, false}, // no family fallback
+		{"claude-sonnet-4-5-preview", 0, false},
+		{"gpt-5", 0, false},
+	}
+	for _, tt := range tests {
+		p, ok := cfg.PriceFor(tt.model)
+		if got := p.Cost(1e6, 1e6, 0, 0); ok != tt.ok || got != tt.want {
+			t.Errorf("PriceFor(%q) costs %v, %v; want %v, %v", tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+}
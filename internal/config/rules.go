@@ -0,0 +1,126 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/grins/parkranger/internal/xdg"
+)
+
+// RuleFile is an ordered set of screen classification rules. The built-in
+// Claude rules ship as one; a user file (RulesPath) runs before them.
+type RuleFile struct {
+	Builtin *bool  `toml:"builtin,omitempty"` // keep the built-in rules after these; default true
+	Rules   []Rule `toml:"rules"`
+}
+
+// KeepBuiltin reports whether the built-in rules still apply.
+func (f RuleFile) KeepBuiltin() bool {
+	return f.Builtin == nil || *f.Builtin
+}
+
+// Rule classifies a screen when all of its conditions match. Rules are
+// tried in order and the first match wins.
+type Rule struct {
+	Name     string      `toml:"name"`
+	Status   string      `toml:"status"`              // "waiting", "busy", "idle", "error" or "unknown"
+	HasAgent *bool       `toml:"has_agent,omitempty"` // agent UI on screen; default true
+	When     []Condition `toml:"when"`
+}
+
+// AgentVisible reports the rule's has_agent flag.
+func (r Rule) AgentVisible() bool {
+	return r.HasAgent == nil || *r.HasAgent
+}
+
+// Condition is one pattern a rule needs to find.
+type Condition struct {
+	Pattern     string `toml:"pattern"`               // RE2 syntax, multi-line mode (^ and $ match at lines)
+	Bottom      int    `toml:"bottom,omitempty"`      // only the bottom N lines, counted from the last non-blank one; 0 = whole capture
+	IgnoreCase  bool   `toml:"ignore_case,omitempty"` // case-insensitive match
+	Highlighted bool   `toml:"highlighted,omitempty"` // a matching line must be drawn in colour or reversed
+}
+
+// ruleStatuses are the statuses a rule may produce.
+var ruleStatuses = []string{"waiting", "busy", "idle", "error", "unknown"}
+
+// RulesPath returns the user rule file: <config dir>/rules.toml.
+func RulesPath() string {
+	return filepath.Join(xdg.ConfigDir(), "rules.toml")
+}
+
+// ParseRules decodes a rule file.
+func ParseRules(data string) (RuleFile, error) {
+	var f RuleFile
+	md, err := toml.Decode(data, &f)
+	if err != nil {
+		return f, err
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, k := range undecoded {
+			keys[i] = k.String()
+		}
+		return f, fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+	}
+	return f, nil
+}
+
+// loadRules reads the rule file at path; a missing file is an empty one.
+func loadRules(path string) (RuleFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return RuleFile{}, nil
+	}
+	if err != nil {
+		return RuleFile{}, err
+	}
+	f, err := ParseRules(string(data))
+	if err != nil {
+		return f, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Validate reports every problem in the rules, one error per problem.
+func (f RuleFile) Validate() error {
+	return errors.Join(f.problems()...)
+}
+
+func (f RuleFile) problems() []error {
+	var errs []error
+	for i, r := range f.Rules {
+		errs = append(errs, r.validate(fmt.Sprintf("rules[%d]", i))...)
+	}
+	return errs
+}
+
+func (r Rule) validate(path string) []error {
+	var errs []error
+	if r.Name != "" {
+		path += " (" + r.Name + ")"
+	}
+	if !slices.Contains(ruleStatuses, r.Status) {
+		errs = append(errs, fmt.Errorf("%s: unknown status %q (want one of %s)", path, r.Status, strings.Join(ruleStatuses, ", ")))
+	}
+	if len(r.When) == 0 {
+		errs = append(errs, fmt.Errorf("%s: needs at least one [[when]] condition", path))
+	}
+	for j, c := range r.When {
+		if _, err := regexp.Compile(c.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("%s.when[%d]: pattern: %v", path, j, err))
+		}
+		if c.Bottom < 0 {
+			errs = append(errs, fmt.Errorf("%s.when[%d]: bottom must not be negative", path, j))
+		}
+	}
+	return errs
+}
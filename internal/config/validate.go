@@ -0,0 +1,258 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SinkNames are the notification sinks notify.NewSink understands.
+var SinkNames = []string{"bell", "osc9", "osc777", "command", "tmux"}
+
+// notifyStatuses are the statuses a busy agent can settle into that
+// notifications may be sent for.
+var notifyStatuses = []string{"waiting", "idle", "error", "exited"}
+
+// Multiplexers are the backends mux.New understands.
+var Multiplexers = []string{"tmux", "zellij", "wezterm"}
+
+// minPollInterval keeps a typo like "5ms" from pinning a CPU on tmux calls.
+const minPollInterval = 100 * time.Millisecond
+
+// Warnings lists settings that are accepted but have no effect, such as
+// global-only keys in a repo file. Unlike Validate's problems they don't
+// make the config unusable.
+func (c Config) Warnings() []string {
+	var warnings []string
+	for _, key := range c.ignored {
+		warnings = append(warnings, key+" is only read from the global config; ignored")
+	}
+	return warnings
+}
+
+// Validate reports every problem in the config at once, one error per
+// problem, joined with errors.Join. Returns nil if the config is usable.
+func (c Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	for _, key := range c.unknown {
+		add("unknown key %s", key)
+	}
+
+	if c.PollInterval.Duration < minPollInterval {
+		add("poll_interval %s is below the minimum of %s", c.PollInterval, minPollInterval)
+	}
+	if c.Hysteresis < 1 {
+		add("busy_hysteresis must be at least 1")
+	}
+	if c.QuietAfter.Duration < 0 {
+		add("quiet_after must not be negative")
+	}
+	for model, p := range c.Prices {
+		if model == "" {
+			add("prices: empty model name")
+		}
+		if p.Input < 0 || p.Output < 0 || p.CacheWrite < 0 || p.CacheRead < 0 {
+			add("prices.%s: prices must not be negative", model)
+		}
+	}
+	if !slices.Contains(Multiplexers, c.Multiplexer) {
+		add("multiplexer: unknown %q (want one of %s)", c.Multiplexer, strings.Join(Multiplexers, ", "))
+	}
+	if strings.TrimSpace(c.AgentCommand) == "" {
+		add("agent_command is empty")
+	}
+	errs = append(errs, c.validateAgents()...)
+	for _, err := range c.Rules.problems() {
+		errs = append(errs, fmt.Errorf("rules.toml: %w", err))
+	}
+
+	if _, ok := c.Layouts[c.Layout]; !ok {
+		add("layout %q is not defined under [layouts]", c.Layout)
+	}
+	for _, name := range sortedKeys(c.Layouts) {
+		l := c.Layouts[name]
+		errs = append(errs, validatePane("layouts."+name, l.Pane)...)
+		seen := make(map[string]bool)
+		for i, w := range l.Windows {
+			path := fmt.Sprintf("layouts.%s.windows[%d]", name, i)
+			switch {
+			case w.Name == "":
+				add("%s: name is required", path)
+			case seen[w.Name]:
+				add("%s: duplicate window name %q", path, w.Name)
+			}
+			seen[w.Name] = true
+			errs = append(errs, validatePane(path, w.Pane)...)
+		}
+	}
+
+	errs = append(errs, c.Keys.validate()...)
+
+	for _, s := range c.Notify.Sinks {
+		if !slices.Contains(SinkNames, s) {
+			add("notify.sinks: unknown sink %q (want one of %s)", s, strings.Join(SinkNames, ", "))
+		}
+	}
+	for _, s := range c.Notify.On {
+		if !slices.Contains(notifyStatuses, s) {
+			add("notify.on: unknown status %q (want one of %s)", s, strings.Join(notifyStatuses, ", "))
+		}
+	}
+	if c.Notify.Cooldown.Duration < 0 {
+		add("notify.cooldown must not be negative")
+	}
+	if c.Hooks.Timeout.Duration <= 0 {
+		add("hooks.timeout must be positive")
+	}
+	if c.Hooks.SessionOpenedTimeout.Duration <= 0 {
+		add("hooks.session_opened_timeout must be positive")
+	}
+
+	return errors.Join(errs...)
+}
+
+// agentRuleStatuses are the statuses an agent rule may produce.
+var agentRuleStatuses = []string{"waiting", "busy", "idle", "error"}
+
+// validateAgents checks the [[agents]] profiles and that agent names one.
+func (c Config) validateAgents() []error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	names := map[string]bool{ClaudeAgent: true}
+	for i, a := range c.Agents {
+		path := fmt.Sprintf("agents[%d]", i)
+		switch {
+		case a.Name == "":
+			add("%s: name is required", path)
+		case a.Name == ClaudeAgent:
+			add("%s: %q is built in; set agent_command to change how it runs", path, a.Name)
+		case names[a.Name]:
+			add("%s: duplicate agent name %q", path, a.Name)
+		}
+		names[a.Name] = true
+
+		if strings.TrimSpace(a.Command) == "" {
+			add("%s: command is required", path)
+		}
+		if a.Resume != "" && !strings.Contains(a.Resume, "{id}") {
+			add("%s: resume must contain {id}", path)
+		}
+		for j, r := range a.Rules {
+			rpath := fmt.Sprintf("%s.rules[%d]", path, j)
+			if _, err := regexp.Compile(r.Pattern); err != nil {
+				add("%s: pattern: %v", rpath, err)
+			}
+			if !slices.Contains(agentRuleStatuses, r.Status) {
+				add("%s: unknown status %q (want one of %s)", rpath, r.Status, strings.Join(agentRuleStatuses, ", "))
+			}
+			if r.Bottom < 0 {
+				add("%s: bottom must not be negative", rpath)
+			}
+		}
+	}
+
+	if !names[c.Agent] {
+		add("agent %q is neither %q nor defined under [[agents]]", c.Agent, ClaudeAgent)
+	}
+	return errs
+}
+
+// validatePane checks a pane tree: split directions, sizes that fit within
+// their parent, and commands only on leaves.
+func validatePane(path string, p Pane) []error {
+	var errs []error
+	if p.Split != "" && p.Split != "horizontal" && p.Split != "vertical" {
+		errs = append(errs, fmt.Errorf("%s: split %q must be \"horizontal\" or \"vertical\"", path, p.Split))
+	}
+	if len(p.Panes) == 0 {
+		return errs
+	}
+	if p.Command != "" || p.Agent {
+		errs = append(errs, fmt.Errorf("%s: a pane with panes is only a container; move command/agent into a child", path))
+	}
+
+	var total float64
+	var unsized int
+	for i, child := range p.Panes {
+		childPath := fmt.Sprintf("%s.panes[%d]", path, i)
+		if child.Size == "" {
+			unsized++
+		} else if pct, err := ParseSize(child.Size); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", childPath, err))
+		} else {
+			total += pct
+		}
+		errs = append(errs, validatePane(childPath, child)...)
+	}
+	if total > 100 || (unsized > 0 && total >= 100) {
+		errs = append(errs, fmt.Errorf("%s: pane sizes add up to %g%%, leaving no room", path, total))
+	}
+	return errs
+}
+
+// ParseSize parses a percentage pane size such as "30%".
+func ParseSize(s string) (float64, error) {
+	num, ok := strings.CutSuffix(strings.TrimSpace(s), "%")
+	if !ok {
+		return 0, fmt.Errorf("size %q must be a percentage like \"30%%\"", s)
+	}
+	pct, err := strconv.ParseFloat(num, 64)
+	if err != nil || pct <= 0 || pct >= 100 {
+		return 0, fmt.Errorf("size %q must be between 0%% and 100%%", s)
+	}
+	return pct, nil
+}
+
+// validate checks every action has a key and no key does two things.
+func (k Keys) validate() []error {
+	var errs []error
+	owner := make(map[string]string)
+	for _, a := range k.actions() {
+		if len(a.keys) == 0 {
+			errs = append(errs, fmt.Errorf("keys.%s: no key bound", a.name))
+		}
+		for _, key := range a.keys {
+			if prev, ok := owner[key]; ok && prev != a.name {
+				errs = append(errs, fmt.Errorf("keys.%s: %q is already bound to %s", a.name, key, prev))
+				continue
+			}
+			owner[key] = a.name
+		}
+	}
+	return errs
+}
+
+// action is a named key binding, for validation.
+type action struct {
+	name string
+	keys []string
+}
+
+func (k Keys) actions() []action {
+	return []action{
+		{"up", k.Up}, {"down", k.Down}, {"open", k.Open},
+		{"new", k.New}, {"merge", k.Merge}, {"delete", k.Delete},
+		{"refresh", k.Refresh}, {"preview", k.Preview}, {"restart", k.Restart},
+		{"quit", k.Quit},
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
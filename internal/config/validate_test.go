@@ -0,0 +1,254 @@
+package config
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   []string // substrings, one per expected error
+	}{
+		{"defaults", func(*Config) {}, nil},
+		{
+			"poll too fast",
+			func(c *Config) { c.PollInterval = Duration{10 * time.Millisecond} },
+			[]string{"poll_interval"},
+		},
+		{
+			"multiplexer",
+			func(c *Config) { c.Multiplexer = "screen" },
+			[]string{`multiplexer: unknown "screen"`},
+		},
+		{
+			"prices",
+			func(c *Config) { c.Prices["claude-x"] = Price{Input: -1} },
+			[]string{"prices.claude-x: prices must not be negative"},
+		},
+		{
+			"missing layout",
+			func(c *Config) { c.Layout = "nope" },
+			[]string{`layout "nope" is not defined`},
+		},
+		{
+			"bad pane tree",
+			func(c *Config) {
+				c.Layouts["x"] = Layout{Pane: Pane{
+					Split:   "diagonal",
+					Command: "htop",
+					Panes:   []Pane{{Size: "70%"}, {Size: "40%"}, {Size: "half"}},
+				}}
+			},
+			[]string{`split "diagonal"`, "only a container", `size "half"`, "add up to 110%"},
+		},
+		{
+			"windows",
+			func(c *Config) {
+				c.Layouts["x"] = Layout{Windows: []Window{{Name: "a"}, {Name: "a"}, {}}}
+			},
+			[]string{`duplicate window name "a"`, "windows[2]: name is required"},
+		},
+		{
+			"keys",
+			func(c *Config) {
+				c.Keys.New = []string{"m"}
+				c.Keys.Refresh = nil
+			},
+			[]string{`keys.merge: "m" is already bound to new`, "keys.refresh: no key bound"},
+		},
+		{
+			"notify",
+			func(c *Config) {
+				c.Notify.Sinks = []string{"pager"}
+				c.Notify.On = []string{"busy"}
+			},
+			[]string{`unknown sink "pager"`, `unknown status "busy"`},
+		},
+		{
+			"agents",
+			func(c *Config) {
+				c.Agent = "codex"
+				c.Agents = []Agent{
+					{Name: "claude", Command: "claude"},
+					{Name: "aider", Resume: "aider --restore", Rules: []AgentRule{
+						{Pattern: "(", Status: "idle"},
+						{Pattern: "> $", Status: "sleeping", Bottom: -1},
+					}},
+					{Name: "aider", Command: "aider"},
+				}
+			},
+			[]string{
+				`agents[0]: "claude" is built in`,
+				"agents[1]: command is required",
+				"agents[1]: resume must contain {id}",
+				"agents[1].rules[0]: pattern",
+				`agents[1].rules[1]: unknown status "sleeping"`,
+				"agents[1].rules[1]: bottom must not be negative",
+				`agents[2]: duplicate agent name "aider"`,
+				`agent "codex" is neither`,
+			},
+		},
+		{
+			"detection timing",
+			func(c *Config) {
+				c.Hysteresis = 0
+				c.QuietAfter = Duration{-time.Second}
+			},
+			[]string{"busy_hysteresis must be at least 1", "quiet_after must not be negative"},
+		},
+		{
+			"rules",
+			func(c *Config) {
+				c.Rules.Rules = []Rule{
+					{Name: "x", Status: "asleep", When: []Condition{{Pattern: "a", Bottom: -1}}},
+					{Status: "idle", When: []Condition{{Pattern: "("}}},
+					{Status: "busy"},
+				}
+			},
+			[]string{
+				`rules[0] (x): unknown status "asleep"`,
+				"rules[0] (x).when[0]: bottom must not be negative",
+				"rules[1].when[0]: pattern:",
+				"rules[2]: needs at least one [[when]] condition",
+			},
+		},
+		{
+			"unknown key",
+			func(c *Config) { c.unknown = []string{"config.toml: notfy.sinks"} },
+			[]string{"unknown key config.toml: notfy.sinks"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+
+			var got []string
+			if err != nil {
+				got = strings.Split(err.Error(), "\n")
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Validate() = %q, want %d errors", got, len(tt.want))
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(got[i], w) {
+					t.Errorf("error %d = %q, want it to contain %q", i, got[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_UnknownKeys(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, RepoFile), `
+agent_comand = "aider"
+
+[notify]
+sinkz = ["bell"]
+`)
+
+	cfg, err := Load(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("expected unknown keys to fail validation")
+	}
+	for _, key := range []string{"agent_comand", "notify.sinkz"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q doesn't mention %s", err, key)
+		}
+	}
+}
+
+func TestLoad_GlobalOnlyKeys(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(configHome, "parkranger", "config.toml"), `poll_interval = "2s"`)
+	writeFile(t, filepath.Join(repo, RepoFile), `
+poll_interval = "100ms"
+multiplexer = "zellij"
+`)
+
+	cfg, err := Load(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("global-only keys should only warn: %v", err)
+	}
+	if cfg.PollInterval.Duration != 2*time.Second || cfg.Multiplexer != "tmux" {
+		t.Errorf("repo file overrode global-only keys: %v, %q", cfg.PollInterval, cfg.Multiplexer)
+	}
+	warnings := cfg.Warnings()
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "poll_interval") || !strings.Contains(warnings[1], "multiplexer") {
+		t.Errorf("warnings = %q", warnings)
+	}
+
+	// The same keys in the global file are fine
+	if global, _ := Load(""); len(global.Warnings()) != 0 {
+		t.Errorf("global warnings = %q", global.Warnings())
+	}
+}
+
+func TestWorktreePath(t *testing.T) {
+	t.Setenv("HOME", "/home/u")
+	tests := []struct {
+		root string
+		want string
+	}{
+		{"../.worktrees/{repo}", "/src/.worktrees/app/feat"},
+		{".trees", "/src/app/.trees/feat"},
+		{"~/wt/{repo}", "/home/u/wt/app/feat"},
+		{"/tmp/wt", "/tmp/wt/feat"},
+	}
+
+	for _, tt := range tests {
+		cfg := Config{WorktreeRoot: tt.root}
+		if got := cfg.WorktreePath("/src/app", "feat"); got != tt.want {
+			t.Errorf("WorktreePath with root %q = %q, want %q", tt.root, got, tt.want)
+		}
+	}
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+
+	want := Default()
+	want.AgentCommand = "aider"
+	want.Layouts["triple"] = Layout{
+		Pane:    Pane{Split: "vertical", Panes: []Pane{{Command: "{editor} ."}, {Agent: true, Size: "30%"}}},
+		Windows: []Window{{Name: "logs", Pane: Pane{Command: "tail -f log"}}},
+	}
+
+	var buf bytes.Buffer
+	if err := want.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(configHome, "parkranger", "config.toml"), buf.String())
+
+	got, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.sinks = true // the encoded file sets every key
+	if err := got.Validate(); err != nil {
+		t.Errorf("encoded config doesn't validate: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, want)
+	}
+}
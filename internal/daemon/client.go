@@ -0,0 +1,267 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grins/parkranger/internal/engine"
+	"github.com/grins/parkranger/internal/hooks"
+)
+
+// Client talks to a daemon over its Unix socket.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient returns a client for the daemon listening on socketPath.
+func NewClient(socketPath string) *Client {
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socketPath)
+	}
+	return &Client{
+		http: &http.Client{Transport: &http.Transport{DialContext: dial}},
+	}
+}
+
+// url builds a request URL; the host is ignored by the Unix dialer.
+func url(path string) string {
+	return "http://parkranger" + path
+}
+
+// do issues a request and returns the response if it has the expected status.
+func (c *Client) do(ctx context.Context, method, path string, body any, want int) (*http.Response, error) {
+	var rd io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url(path), rd)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != want {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("daemon %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// Ping reports whether a daemon is answering on the socket.
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	resp, err := c.do(ctx, http.MethodGet, "/v1/state", nil, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// State returns every watched worktree's state. Pane content is included
+// only if withContent is set.
+func (c *Client) State(ctx context.Context, withContent bool) ([]engine.State, error) {
+	path := "/v1/state"
+	if withContent {
+		path += "?content=1"
+	}
+	resp, err := c.do(ctx, http.MethodGet, path, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var states []engine.State
+	if err := json.NewDecoder(resp.Body).Decode(&states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// Events streams daemon events until ctx is cancelled or the daemon exits,
+// then closes the channel.
+func (c *Client) Events(ctx context.Context) (<-chan engine.Event, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/v1/events", nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan engine.Event, 64)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var ev engine.Event
+			if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+				continue
+			}
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Refresh asks the daemon to poll immediately.
+func (c *Client) Refresh(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodPost, "/v1/refresh", nil, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Watch adds targets to the daemon's watch set (e.g. the CWD repo when it
+// isn't registered).
+func (c *Client) Watch(ctx context.Context, targets []engine.Target) error {
+	resp, err := c.do(ctx, http.MethodPost, "/v1/watch", targets, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Hooks returns the recent results of hooks the daemon ran, oldest first.
+func (c *Client) Hooks(ctx context.Context) ([]hooks.Result, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/v1/hooks", nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var results []hooks.Result
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Mirror keeps a local copy of the daemon's state, so a consumer written
+// against the in-process engine (State + event channel) can run remotely.
+type Mirror struct {
+	client *Client
+	ctx    context.Context
+
+	mu     sync.Mutex
+	states map[string]engine.State
+	hooks  []hooks.Result
+}
+
+// NewMirror loads the daemon's state and follows its event stream. The
+// returned channel receives every event after the mirror has applied it and
+// is closed when ctx is cancelled or the daemon goes away.
+func NewMirror(ctx context.Context, c *Client) (*Mirror, <-chan engine.Event, error) {
+	m := &Mirror{client: c, ctx: ctx, states: make(map[string]engine.State)}
+	if err := m.reload(); err != nil {
+		return nil, nil, err
+	}
+
+	in, err := c.Events(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := make(chan engine.Event, 64)
+	go func() {
+		defer close(out)
+		for ev := range in {
+			if ev.Kind == engine.EventPolled {
+				// Pane content isn't streamed — re-read it once per poll.
+				_ = m.reload()
+			} else {
+				m.apply(ev)
+			}
+			select {
+			case out <- ev:
+			default:
+			}
+		}
+	}()
+	return m, out, nil
+}
+
+// reload replaces the mirrored state and hook results with a fresh daemon
+// snapshot.
+func (m *Mirror) reload() error {
+	states, err := m.client.State(m.ctx, true)
+	if err != nil {
+		return err
+	}
+	next := make(map[string]engine.State, len(states))
+	for _, st := range states {
+		next[st.Key()] = st
+	}
+	results, err := m.client.Hooks(m.ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.states = next
+	m.hooks = results
+	m.mu.Unlock()
+	return nil
+}
+
+// apply folds a change event into the mirrored state, keeping the last
+// known pane content.
+func (m *Mirror) apply(ev engine.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := ev.State
+	if prev, ok := m.states[ev.Key]; ok {
+		st.Live.PaneContent = prev.Live.PaneContent
+		st.Live.PaneStyled = prev.Live.PaneStyled
+	}
+	m.states[ev.Key] = st
+}
+
+// State returns the mirrored state for key.
+func (m *Mirror) State(key string) (engine.State, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, ok := m.states[key]
+	return st, ok
+}
+
+// HookResults returns the results of hooks the daemon ran, as of the last
+// poll, oldest first.
+func (m *Mirror) HookResults() []hooks.Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]hooks.Result(nil), m.hooks...)
+}
+
+// Refresh asks the daemon to poll immediately. Errors are ignored; the
+// mirror keeps following the stream either way.
+func (m *Mirror) Refresh() {
+	_ = m.client.Refresh(m.ctx)
+}
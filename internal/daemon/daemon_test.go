@@ -0,0 +1,195 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grins/parkranger/internal/config"
+	"github.com/grins/parkranger/internal/engine"
+	"github.com/grins/parkranger/internal/hooks"
+)
+
+// startServer runs a daemon on a temp socket. Targets point at a tmux session
+// that never exists, so polling is cheap and deterministic.
+// Setup funcs run before the server starts.
+func startServer(t *testing.T, setup ...func(*Server)) (string, *Server) {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "d.sock")
+	eng := engine.New(10 * time.Millisecond)
+	srv := NewServer(eng, func() ([]engine.Target, error) {
+		return []engine.Target{{Repo: "test-nonexistent-xyz", Worktree: "a"}}, nil
+	})
+	for _, f := range setup {
+		f(srv)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, socket) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	client := NewClient(socket)
+	deadline := time.Now().Add(2 * time.Second)
+	for client.Ping(context.Background()) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("daemon did not come up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return socket, srv
+}
+
+func TestState(t *testing.T) {
+	socket, _ := startServer(t)
+	client := NewClient(socket)
+
+	states, err := client.State(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].Key() != "test-nonexistent-xyz/a" {
+		t.Errorf("states = %+v", states)
+	}
+}
+
+func TestWatchAddsTargets(t *testing.T) {
+	socket, _ := startServer(t)
+	client := NewClient(socket)
+	ctx := context.Background()
+
+	path := t.TempDir()
+	extra := engine.Target{Repo: "test-nonexistent-xyz", Worktree: "b", Path: path}
+	if err := client.Watch(ctx, []engine.Target{extra}); err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := client.State(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states[1].Key() != extra.Key() || states[1].Path != path {
+		t.Errorf("states = %+v", states)
+	}
+}
+
+func TestReloadDropsRemovedWorktrees(t *testing.T) {
+	socket, srv := startServer(t)
+	client := NewClient(socket)
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "b")
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Watch(ctx, []engine.Target{{Repo: "test-nonexistent-xyz", Worktree: "b", Path: path}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	states, err := client.State(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 {
+		t.Errorf("states = %+v, want the removed worktree gone", states)
+	}
+	if len(srv.watched) != 0 {
+		t.Errorf("watched = %v", srv.watched)
+	}
+}
+
+func TestEventsStream(t *testing.T) {
+	socket, _ := startServer(t)
+	client := NewClient(socket)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := client.Events(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Kind != engine.EventPolled {
+			t.Errorf("first event = %v", ev.Kind)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+func TestMirror(t *testing.T) {
+	socket, _ := startServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m, events, err := NewMirror(ctx, NewClient(socket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.State("test-nonexistent-xyz/a"); !ok {
+		t.Error("mirror missing initial state")
+	}
+
+	m.Refresh()
+	select {
+	case <-events:
+	case <-time.After(2 * time.Second):
+		t.Fatal("mirror forwarded no events")
+	}
+}
+
+func TestServeRefusesSecondDaemon(t *testing.T) {
+	socket, srv := startServer(t)
+
+	err := srv.Serve(context.Background(), socket)
+	if !errors.Is(err, ErrRunning) {
+		t.Errorf("second Serve = %v, want ErrRunning", err)
+	}
+}
+
+func TestPingNoDaemon(t *testing.T) {
+	client := NewClient(filepath.Join(t.TempDir(), "missing.sock"))
+	if err := client.Ping(context.Background()); err == nil {
+		t.Error("expected ping to fail without a daemon")
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var runner hooks.Runner
+	runner.Run(config.Hooks{
+		Timeout:      config.Duration{Duration: 5 * time.Second},
+		AgentWaiting: []string{"echo ok", "exit 2"},
+	}, hooks.Payload{Event: hooks.AgentWaiting, Repo: "app", Worktree: "feat", Path: t.TempDir()})
+	socket, _ := startServer(t, func(s *Server) { s.SetHooks(&runner) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m, _, err := NewMirror(ctx, NewClient(socket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.HookResults()
+	if len(got) != 2 || got[0].Output != "ok" || got[0].Err != nil || got[1].Err == nil || got[1].Worktree != "feat" {
+		t.Errorf("hook results = %+v", got)
+	}
+}
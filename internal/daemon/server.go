@@ -0,0 +1,276 @@
+// Package daemon serves engine state over a Unix domain socket so the CLI,
+// dashboard, tmux status bars and editor plugins all query one detection
+// loop instead of each shelling out to tmux.
+//
+// The API is plain HTTP with JSON bodies:
+//
+//	GET  /v1/state[?content=1]  every watched worktree's engine.State
+//	GET  /v1/events             newline-delimited engine.Event stream
+//	POST /v1/refresh            poll immediately
+//	POST /v1/watch              add targets (JSON []engine.Target)
+//	POST /v1/reload             re-resolve the registered repos
+//	GET  /v1/hooks              recent results of hooks the daemon ran
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/grins/parkranger/internal/engine"
+	"github.com/grins/parkranger/internal/hooks"
+	"github.com/grins/parkranger/internal/xdg"
+)
+
+// reloadInterval is how often the daemon re-resolves its targets, picking up
+// worktrees created or removed outside parkranger.
+const reloadInterval = 30 * time.Second
+
+// ErrRunning is returned by Serve when another daemon owns the socket.
+var ErrRunning = errors.New("daemon already running")
+
+// SocketPath returns the daemon socket location: <runtime dir>/daemon.sock.
+func SocketPath() string {
+	return filepath.Join(xdg.RuntimeDir(), "daemon.sock")
+}
+
+// Server exposes an engine over HTTP.
+type Server struct {
+	eng     *engine.Engine
+	targets func() ([]engine.Target, error)
+	hooks   *hooks.Runner // agent_waiting hooks run here; nil if none
+
+	mu      sync.Mutex
+	watched map[string]engine.Target // targets added by clients via /v1/watch
+}
+
+// NewServer creates a server for eng. targets resolves the base set of
+// worktrees to watch (typically every registered repo); it is re-run
+// periodically and on /v1/reload.
+func NewServer(eng *engine.Engine, targets func() ([]engine.Target, error)) *Server {
+	return &Server{
+		eng:     eng,
+		targets: targets,
+		watched: make(map[string]engine.Target),
+	}
+}
+
+// SetHooks makes the results of hooks run by r available on /v1/hooks.
+func (s *Server) SetHooks(r *hooks.Runner) {
+	s.hooks = r
+}
+
+// Reload re-resolves the base targets, merges client-watched ones, and
+// hands the result to the engine. Client-watched targets whose worktree is
+// gone are dropped.
+func (s *Server) Reload() error {
+	base, err := s.targets()
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(base))
+	for _, t := range base {
+		seen[t.Key()] = true
+	}
+
+	s.mu.Lock()
+	for key, t := range s.watched {
+		if t.Path != "" {
+			if _, err := os.Stat(t.Path); os.IsNotExist(err) {
+				delete(s.watched, key)
+				continue
+			}
+		}
+		if !seen[key] {
+			base = append(base, t)
+		}
+	}
+	s.mu.Unlock()
+
+	s.eng.SetTargets(base)
+	return nil
+}
+
+// Handler returns the HTTP API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/state", s.handleState)
+	mux.HandleFunc("GET /v1/events", s.handleEvents)
+	mux.HandleFunc("POST /v1/refresh", s.handleRefresh)
+	mux.HandleFunc("POST /v1/watch", s.handleWatch)
+	mux.HandleFunc("POST /v1/reload", s.handleReload)
+	mux.HandleFunc("GET /v1/hooks", s.handleHooks)
+	return mux
+}
+
+// Serve listens on socketPath, runs the engine, and serves until ctx is
+// cancelled. A stale socket left by a crashed daemon is replaced; a live one
+// yields ErrRunning.
+func (s *Server) Serve(ctx context.Context, socketPath string) error {
+	if err := NewClient(socketPath).Ping(ctx); err == nil {
+		return ErrRunning
+	}
+	_ = os.Remove(socketPath)
+
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0o700); err != nil {
+		return err
+	}
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", socketPath, err)
+	}
+	defer os.Remove(socketPath)
+	if err := os.Chmod(socketPath, 0o600); err != nil {
+		ln.Close()
+		return err
+	}
+
+	if err := s.Reload(); err != nil {
+		ln.Close()
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go s.eng.Run(ctx)
+	go s.reloadLoop(ctx)
+
+	srv := &http.Server{
+		Handler:     s.Handler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, done := context.WithTimeout(context.Background(), 2*time.Second)
+		defer done()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// reloadLoop periodically re-resolves targets until ctx is cancelled.
+func (s *Server) reloadLoop(ctx context.Context) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = s.Reload() // keep the previous targets on error
+		}
+	}
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	states := s.eng.Snapshot()
+	if r.URL.Query().Get("content") == "" {
+		for i := range states {
+			states[i].Live.PaneContent = ""
+			states[i].Live.PaneStyled = ""
+		}
+	}
+	writeJSON(w, states)
+}
+
+// handleEvents streams events as newline-delimited JSON until the client
+// disconnects. Pane content is omitted to keep the stream small; clients
+// that render previews re-read /v1/state?content=1 on polled events.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, cancel := s.eng.Subscribe(256)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			ev.Prev.Live.PaneContent, ev.Prev.Live.PaneStyled = "", ""
+			ev.State.Live.PaneContent, ev.State.Live.PaneStyled = "", ""
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	s.eng.Refresh()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request) {
+	var targets []engine.Target
+	if err := json.NewDecoder(r.Body).Decode(&targets); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	for _, t := range targets {
+		s.watched[t.Key()] = t
+	}
+	s.mu.Unlock()
+
+	if err := s.Reload(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.eng.Refresh()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if err := s.Reload(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// maxHookResults bounds the results served on /v1/hooks.
+const maxHookResults = 20
+
+func (s *Server) handleHooks(w http.ResponseWriter, r *http.Request) {
+	results := []hooks.Result{}
+	if s.hooks != nil {
+		results = append(results, s.hooks.Recent(maxHookResults, time.Time{})...)
+	}
+	writeJSON(w, results)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
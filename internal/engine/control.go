@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/grins/parkranger/internal/mux"
+	"github.com/grins/parkranger/internal/session"
+	"github.com/grins/parkranger/internal/tmux"
+)
+
+// redialInterval spaces attempts to (re)open the control connection, e.g.
+// while the session it attaches to doesn't exist yet.
+const redialInterval = 5 * time.Second
+
+// fallbackInterval is how often a control-mode engine polls when tmux has
+// reported nothing, so quiet-screen timers still fire.
+const fallbackInterval = 5 * time.Second
+
+// SetControl makes Run read tmux over one control-mode connection instead
+// of forking tmux for every snapshot and capture, and poll when tmux reports
+// agent output or windows coming and going rather than on every tick. Only
+// the engine's polls use the connection. Must be called before Run.
+//
+// tmux only reports output for the session the connection is attached to
+// (the first target's), so while any target lives in another session the
+// engine keeps polling every interval; reads still skip the fork. The
+// connection is only kept while a user is attached to that session too, so
+// it never shows as attached on its own, and tmux older than 3.2 is left
+// alone.
+func (e *Engine) SetControl(on bool) {
+	e.control = on
+}
+
+// dialControl connects to the first target's session, if a user is
+// attached to it, and makes polls read over the connection. Returns nil if
+// it doesn't connect.
+func (e *Engine) dialControl() (*tmux.Client, error) {
+	e.mu.Lock()
+	var sess string
+	if len(e.order) > 0 {
+		sess = tmux.SessionName(e.watches[e.order[0]].state.Repo)
+	}
+	e.mu.Unlock()
+	if sess == "" || tmux.AttachedClients(sess) == 0 {
+		return nil, nil
+	}
+
+	c, err := tmux.Dial(sess)
+	if err != nil {
+		return nil, err
+	}
+	e.pollMu.Lock()
+	e.poller = mux.Tmux{Control: c}
+	e.pollMu.Unlock()
+	return c, nil
+}
+
+// closeControl makes polls fork tmux again and closes c.
+func (e *Engine) closeControl(c *tmux.Client) {
+	if c == nil {
+		return
+	}
+	e.pollMu.Lock()
+	e.poller = nil
+	e.pollMu.Unlock()
+	c.Close()
+}
+
+// relevant reports whether a notification may change a target's state:
+// windows coming or going, or output from a pane an agent was last seen in.
+// Output from editors, servers and the dashboard itself is ignored.
+func (e *Engine) relevant(ev tmux.Event) bool {
+	if ev.Kind != tmux.EventOutput {
+		return true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, w := range e.watches {
+		for _, p := range w.state.Live.Panes {
+			if p.ID == ev.Pane {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// skipTick reports whether a control-mode engine can skip a tick: every
+// target is in the session the notifications come from, none has arrived
+// for long enough that debouncing has settled, and the fallback poll isn't
+// due yet.
+func (e *Engine) skipTick(sess string, now, lastEvent, lastPoll time.Time) bool {
+	if now.Sub(lastPoll) >= fallbackInterval || now.Sub(lastEvent) < e.settle() {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, w := range e.watches {
+		if tmux.SessionName(w.state.Repo) != sess {
+			return false
+		}
+	}
+	return true
+}
+
+// settle is how long to keep polling after the last notification: long
+// enough for hysteresis to let go of Busy once output stops.
+func (e *Engine) settle() time.Duration {
+	d := e.debounce
+	if d == (session.Debounce{}) {
+		d = session.DefaultDebounce
+	}
+	return e.interval * time.Duration(d.Hysteresis+2)
+}
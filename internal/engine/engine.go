@@ -0,0 +1,487 @@
+// Package engine runs agent detection headlessly. It owns one session.Detector
+// per worktree window, polls on its own goroutine, and publishes typed events
+// that the TUI, CLI, and other consumers subscribe to.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grins/parkranger/internal/git"
+	"github.com/grins/parkranger/internal/mux"
+	"github.com/grins/parkranger/internal/session"
+	"github.com/grins/parkranger/internal/store"
+	"github.com/grins/parkranger/internal/tmux"
+)
+
+// DefaultInterval is the poll interval used when New is given zero.
+const DefaultInterval = 500 * time.Millisecond
+
+// dirtyInterval throttles `git status` checks, which are far more expensive
+// than a pane capture and change much less often.
+const dirtyInterval = 5 * time.Second
+
+// saveInterval throttles writes of the persistent store.
+const saveInterval = 5 * time.Second
+
+// Target is a worktree whose tmux window the engine watches.
+type Target struct {
+	Repo     string `json:"repo"`            // repo name (tmux session pr-<repo>)
+	Worktree string `json:"worktree"`        // worktree name (tmux window)
+	Path     string `json:"path"`            // worktree path, for dirty checks
+	Dirty    bool   `json:"dirty,omitempty"` // initial dirty state, if already known
+}
+
+// Key identifies a target across repos: "<repo>/<worktree>".
+func (t Target) Key() string {
+	return t.Repo + "/" + t.Worktree
+}
+
+// State is the latest known state of a target.
+type State struct {
+	Target
+	Live  session.LiveInfo `json:"live"`
+	Dirty bool             `json:"dirty"`
+}
+
+// EventKind identifies what changed.
+type EventKind int
+
+const (
+	// EventPolled fires once per completed poll cycle, with no target.
+	// Consumers that render pane content (previews) refresh on it.
+	EventPolled EventKind = iota
+	EventStatusChanged
+	EventWindowAppeared
+	EventWindowDisappeared
+	EventDirtyChanged
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventPolled:
+		return "polled"
+	case EventStatusChanged:
+		return "status-changed"
+	case EventWindowAppeared:
+		return "window-appeared"
+	case EventWindowDisappeared:
+		return "window-disappeared"
+	case EventDirtyChanged:
+		return "dirty-changed"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the kind by name for the daemon's event stream.
+func (k EventKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes a kind name written by MarshalText.
+func (k *EventKind) UnmarshalText(text []byte) error {
+	for kind := EventPolled; kind <= EventDirtyChanged; kind++ {
+		if kind.String() == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event kind %q", text)
+}
+
+// Event describes a change to one target. Prev and State hold the target's
+// state before and after the poll that produced the event.
+type Event struct {
+	Kind  EventKind `json:"kind"`
+	Time  time.Time `json:"time"`
+	Key   string    `json:"key,omitempty"`
+	Prev  State     `json:"prev"`
+	State State     `json:"state"`
+}
+
+// watch is the engine's per-target bookkeeping.
+type watch struct {
+	state     State
+	detector  *session.Detector
+	polled    bool // state.Live reflects at least one detection
+	lastDirty time.Time
+}
+
+// Engine polls tmux for agent status and publishes events on change.
+// All methods are safe for concurrent use.
+type Engine struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	watches map[string]*watch
+	order   []string
+	subs    map[chan Event]struct{}
+
+	pollMu  sync.Mutex // serializes poll cycles (Run loop vs. Poll callers)
+	refresh chan struct{}
+
+	store    *store.Store // optional; receives observed statuses
+	lastSave time.Time
+
+	profiles func(repo string) []session.AgentProfile // optional; agents per repo
+	debounce session.Debounce                         // zero: session.DefaultDebounce
+	control  bool                                     // see SetControl
+	poller   mux.Multiplexer                          // what polls read through; mux.Default() if nil; guarded by pollMu
+
+	// Swappable for tests.
+	snapshot func(m mux.Multiplexer) (mux.Snapshot, error)
+	detect   func(t Target, d *session.Detector, snap mux.Snapshot) session.LiveInfo
+	isDirty  func(path string) (bool, error)
+	now      func() time.Time
+}
+
+// New creates an engine that polls every interval (DefaultInterval if zero).
+func New(interval time.Duration) *Engine {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Engine{
+		interval: interval,
+		watches:  make(map[string]*watch),
+		subs:     make(map[chan Event]struct{}),
+		refresh:  make(chan struct{}, 1),
+		snapshot: func(m mux.Multiplexer) (mux.Snapshot, error) { return m.Snapshot() },
+		detect:   detectTarget,
+		isDirty:  git.IsDirty,
+		now:      time.Now,
+	}
+}
+
+// detectTarget runs the detector against the target's window.
+func detectTarget(t Target, d *session.Detector, snap mux.Snapshot) session.LiveInfo {
+	m := mux.Default()
+	return d.DetectIn(snap, m.SessionName(t.Repo), m.WindowName(t.Worktree))
+}
+
+// SetStore makes the engine record every observed agent status in st.
+// Must be called before Run.
+func (e *Engine) SetStore(st *store.Store) {
+	e.store = st
+}
+
+// SetProfiles sets how the engine finds the agent profiles to detect in a
+// repo's windows. Without it, detectors use session.DefaultProfiles.
+// Must be called before Run.
+func (e *Engine) SetProfiles(profiles func(repo string) []session.AgentProfile) {
+	e.profiles = profiles
+}
+
+// SetDebounce sets how detectors smooth status changes. Must be called
+// before SetTargets.
+func (e *Engine) SetDebounce(d session.Debounce) {
+	e.debounce = d
+}
+
+// SetTargets replaces the watched targets. Targets that were already watched
+// keep their detector and state, so status history survives a refresh.
+func (e *Engine) SetTargets(targets []Target) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	next := make(map[string]*watch, len(targets))
+	order := make([]string, 0, len(targets))
+	for _, t := range targets {
+		key := t.Key()
+		w, ok := e.watches[key]
+		if !ok {
+			// The target's dirty state comes from the worktree listing, so
+			// the first git status check waits for dirtyInterval.
+			w = &watch{
+				state:     State{Target: t, Dirty: t.Dirty},
+				detector:  &session.Detector{Debounce: e.debounce},
+				lastDirty: e.now(),
+			}
+		}
+		w.state.Target = t
+		next[key] = w
+		order = append(order, key)
+	}
+
+	e.watches = next
+	e.order = order
+}
+
+// Subscribe returns a channel receiving every event published after the call,
+// and a cancel func that unsubscribes and closes the channel.
+// Publishing never blocks: if the buffer is full, the event is dropped for
+// that subscriber. Consumers that must not miss state should re-read Snapshot.
+func (e *Engine) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+
+	e.mu.Lock()
+	e.subs[ch] = struct{}{}
+	e.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			e.mu.Lock()
+			if _, ok := e.subs[ch]; ok {
+				delete(e.subs, ch)
+				close(ch)
+			}
+			e.mu.Unlock()
+		})
+	}
+	return ch, cancel
+}
+
+// Snapshot returns the current state of every target, in SetTargets order.
+func (e *Engine) Snapshot() []State {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	states := make([]State, 0, len(e.order))
+	for _, key := range e.order {
+		states = append(states, e.watches[key].state)
+	}
+	return states
+}
+
+// State returns the current state of the target with the given key.
+func (e *Engine) State(key string) (State, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	w, ok := e.watches[key]
+	if !ok {
+		return State{}, false
+	}
+	return w.state, true
+}
+
+// Refresh asks a running engine to poll immediately instead of waiting for
+// the next tick. It never blocks.
+func (e *Engine) Refresh() {
+	select {
+	case e.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Run polls until ctx is cancelled, then closes all subscriber channels.
+func (e *Engine) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	defer e.closeSubscribers()
+	defer e.saveStore(true)
+
+	// Control mode only (see SetControl)
+	var (
+		client              *tmux.Client
+		lastDial            time.Time
+		lastEvent, lastPoll time.Time
+	)
+	control := e.control
+	defer func() { e.closeControl(client) }()
+
+	for {
+		if control && client == nil && e.now().Sub(lastDial) >= redialInterval {
+			lastDial = e.now()
+			var err error
+			client, err = e.dialControl()
+			if errors.Is(err, tmux.ErrUnsupported) {
+				control = false
+			}
+		}
+		var notes <-chan tmux.Event
+		if client != nil {
+			notes = client.Events()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if client != nil && e.skipTick(client.Session(), e.now(), lastEvent, lastPoll) {
+				continue
+			}
+		case <-e.refresh:
+		case ev, ok := <-notes:
+			if !ok {
+				// Connection lost: tick every interval until a redial works
+				e.closeControl(client)
+				client = nil
+				continue
+			}
+			if ev.Kind == tmux.EventClientDetached && tmux.AttachedClients(client.Session()) <= 1 {
+				// The user left: don't keep the session attached on our own
+				e.closeControl(client)
+				client, lastDial = nil, e.now()
+				continue
+			}
+			if !e.relevant(ev) {
+				continue
+			}
+			lastEvent = e.now()
+			if lastEvent.Sub(lastPoll) < e.interval {
+				continue // the next tick polls
+			}
+		}
+		e.Poll()
+		lastPoll = e.now()
+	}
+}
+
+// Poll runs one detection cycle synchronously and publishes the resulting
+// events. Detection runs without holding the state lock, so Snapshot stays
+// responsive while tmux is being queried. If the multiplexer can't be read,
+// nothing changes and nothing is published.
+func (e *Engine) Poll() {
+	e.pollMu.Lock()
+	defer e.pollMu.Unlock()
+
+	e.mu.Lock()
+	keys := append([]string(nil), e.order...)
+	watches := make([]*watch, len(keys))
+	for i, key := range keys {
+		watches[i] = e.watches[key]
+	}
+	e.mu.Unlock()
+
+	now := e.now()
+	m := e.poller
+	if m == nil {
+		m = mux.Default()
+	}
+	// One listing of every pane serves all targets. A listing that fails
+	// says nothing about the windows, so the cycle is skipped and every
+	// target keeps its state.
+	var snap mux.Snapshot
+	if len(watches) > 0 {
+		var err error
+		if snap, err = e.snapshot(m); err != nil {
+			return
+		}
+	}
+	var events []Event
+	for i, w := range watches {
+		// w.detector is only touched here, under pollMu.
+		e.mu.Lock()
+		target := w.state.Target
+		checkDirty := now.Sub(w.lastDirty) >= dirtyInterval
+		e.mu.Unlock()
+
+		if e.profiles != nil {
+			w.detector.Profiles = e.profiles(target.Repo)
+		}
+		w.detector.Mux = m
+		live := e.detect(target, w.detector, snap)
+		if e.store != nil && target.Path != "" && live.HasClaude {
+			e.store.RecordStatus(target.Path, target.Repo, target.Worktree, live.Status, now)
+			// The detector only knows this process's polls; the store
+			// remembers when the status was entered across restarts.
+			if since, ok := e.store.StatusSince(target.Path, live.Status); ok && since.Before(live.Since) {
+				live.Since = since
+				for i := range live.Panes {
+					if live.Panes[i].ID == live.PaneID {
+						live.Panes[i].Since = since
+					}
+				}
+			}
+		}
+
+		dirty, dirtyKnown := false, false
+		if checkDirty && target.Path != "" {
+			if d, err := e.isDirty(target.Path); err == nil {
+				dirty, dirtyKnown = d, true
+			}
+		}
+
+		e.mu.Lock()
+		prev := w.state
+		next := prev
+		next.Live = live
+		if dirtyKnown {
+			next.Dirty = dirty
+		}
+		if checkDirty {
+			w.lastDirty = now
+		}
+		w.state = next
+		wasPolled := w.polled
+		w.polled = true
+		e.mu.Unlock()
+
+		events = append(events, diff(keys[i], now, prev, next, wasPolled)...)
+	}
+	e.saveStore(false)
+
+	events = append(events, Event{Kind: EventPolled, Time: now})
+	for _, ev := range events {
+		e.publish(ev)
+	}
+}
+
+// diff derives events from a target's state transition. Window and status
+// events are suppressed on the very first poll, which only establishes a baseline.
+func diff(key string, now time.Time, prev, next State, wasPolled bool) []Event {
+	var events []Event
+	mk := func(kind EventKind) Event {
+		return Event{Kind: kind, Time: now, Key: key, Prev: prev, State: next}
+	}
+
+	if wasPolled {
+		switch {
+		case !prev.Live.Exists && next.Live.Exists:
+			events = append(events, mk(EventWindowAppeared))
+		case prev.Live.Exists && !next.Live.Exists:
+			events = append(events, mk(EventWindowDisappeared))
+		}
+		if prev.Live.Status != next.Live.Status || prev.Live.HasClaude != next.Live.HasClaude {
+			events = append(events, mk(EventStatusChanged))
+		}
+	}
+	if prev.Dirty != next.Dirty {
+		events = append(events, mk(EventDirtyChanged))
+	}
+	return events
+}
+
+// saveStore flushes the store at most once per saveInterval unless forced.
+// Save failures are non-fatal: the next save retries with the same data.
+func (e *Engine) saveStore(force bool) {
+	if e.store == nil {
+		return
+	}
+	now := e.now()
+	if !force && now.Sub(e.lastSave) < saveInterval {
+		return
+	}
+	if err := e.store.Save(); err == nil {
+		e.lastSave = now
+	}
+}
+
+// publish delivers ev to every subscriber without blocking.
+func (e *Engine) publish(ev Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for ch := range e.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// closeSubscribers closes and removes every subscriber channel.
+func (e *Engine) closeSubscribers() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for ch := range e.subs {
+		close(ch)
+		delete(e.subs, ch)
+	}
+}
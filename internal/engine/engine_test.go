@@ -0,0 +1,332 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grins/parkranger/internal/mux"
+	"github.com/grins/parkranger/internal/session"
+	"github.com/grins/parkranger/internal/store"
+	"github.com/grins/parkranger/internal/tmux"
+)
+
+// scripted returns an engine whose detection and dirty checks are driven by
+// the given maps instead of tmux and git.
+func scripted(live map[string]session.LiveInfo, dirty map[string]bool) *Engine {
+	e := New(time.Millisecond)
+	e.snapshot = func(mux.Multiplexer) (mux.Snapshot, error) { return mux.Snapshot{}, nil }
+	e.detect = func(t Target, _ *session.Detector, _ mux.Snapshot) session.LiveInfo {
+		return live[t.Key()]
+	}
+	e.isDirty = func(path string) (bool, error) {
+		return dirty[path], nil
+	}
+	return e
+}
+
+func drain(ch <-chan Event) []Event {
+	var events []Event
+	for {
+		select {
+		case ev := <-ch:
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
+
+func kinds(events []Event) []EventKind {
+	var ks []EventKind
+	for _, ev := range events {
+		if ev.Kind != EventPolled {
+			ks = append(ks, ev.Kind)
+		}
+	}
+	return ks
+}
+
+func TestPoll_BaselineEmitsNoStatusEvents(t *testing.T) {
+	live := map[string]session.LiveInfo{
+		"repo/a": {Exists: true, HasClaude: true, Status: session.StatusBusy},
+	}
+	e := scripted(live, nil)
+	e.SetTargets([]Target{{Repo: "repo", Worktree: "a"}})
+
+	ch, cancel := e.Subscribe(16)
+	defer cancel()
+
+	e.Poll()
+	events := drain(ch)
+	if got := kinds(events); len(got) != 0 {
+		t.Errorf("baseline poll emitted %v", got)
+	}
+	if len(events) != 1 || events[0].Kind != EventPolled {
+		t.Errorf("expected a single polled event, got %v", events)
+	}
+
+	st, ok := e.State("repo/a")
+	if !ok || st.Live.Status != session.StatusBusy {
+		t.Errorf("State = %+v, %v", st, ok)
+	}
+}
+
+func TestPoll_Transitions(t *testing.T) {
+	live := map[string]session.LiveInfo{}
+	dirty := map[string]bool{}
+	e := scripted(live, dirty)
+	e.SetTargets([]Target{{Repo: "repo", Worktree: "a", Path: "/wt/a"}})
+
+	ch, cancel := e.Subscribe(16)
+	defer cancel()
+
+	e.Poll() // baseline: no window
+	drain(ch)
+
+	live["repo/a"] = session.LiveInfo{Exists: true, HasClaude: true, Status: session.StatusBusy}
+	e.Poll()
+	got := kinds(drain(ch))
+	if len(got) != 2 || got[0] != EventWindowAppeared || got[1] != EventStatusChanged {
+		t.Fatalf("appear: got %v", got)
+	}
+
+	live["repo/a"] = session.LiveInfo{Exists: true, HasClaude: true, Status: session.StatusWaiting}
+	e.Poll()
+	events := drain(ch)
+	if got := kinds(events); len(got) != 1 || got[0] != EventStatusChanged {
+		t.Fatalf("status: got %v", got)
+	}
+	if events[0].Prev.Live.Status != session.StatusBusy || events[0].State.Live.Status != session.StatusWaiting {
+		t.Errorf("status event = %+v", events[0])
+	}
+
+	delete(live, "repo/a")
+	e.Poll()
+	got = kinds(drain(ch))
+	if len(got) != 2 || got[0] != EventWindowDisappeared || got[1] != EventStatusChanged {
+		t.Fatalf("disappear: got %v", got)
+	}
+}
+
+func TestPoll_SnapshotErrorKeepsState(t *testing.T) {
+	live := map[string]session.LiveInfo{
+		"repo/a": {Exists: true, HasClaude: true, Status: session.StatusBusy},
+	}
+	e := scripted(live, nil)
+	e.SetTargets([]Target{{Repo: "repo", Worktree: "a"}})
+	e.Poll()
+
+	ch, cancel := e.Subscribe(16)
+	defer cancel()
+	e.snapshot = func(mux.Multiplexer) (mux.Snapshot, error) { return mux.Snapshot{}, errors.New("tmux: exit status 1") }
+	live["repo/a"] = session.LiveInfo{}
+	e.Poll()
+
+	if events := drain(ch); len(events) != 0 {
+		t.Errorf("events = %v, want the cycle skipped", kinds(events))
+	}
+	if got := e.Snapshot()[0].Live; !got.Exists || got.Status != session.StatusBusy {
+		t.Errorf("state = %+v, want the previous one", got)
+	}
+}
+
+func TestPoll_DirtyThrottled(t *testing.T) {
+	dirty := map[string]bool{}
+	e := scripted(nil, dirty)
+	now := time.Unix(1000, 0)
+	e.now = func() time.Time { return now }
+	e.SetTargets([]Target{{Repo: "repo", Worktree: "a", Path: "/wt/a"}})
+
+	ch, cancel := e.Subscribe(16)
+	defer cancel()
+
+	e.Poll()
+	drain(ch)
+
+	dirty["/wt/a"] = true
+	now = now.Add(time.Second)
+	e.Poll()
+	if got := kinds(drain(ch)); len(got) != 0 {
+		t.Errorf("dirty checked before interval: %v", got)
+	}
+
+	now = now.Add(dirtyInterval)
+	e.Poll()
+	if got := kinds(drain(ch)); len(got) != 1 || got[0] != EventDirtyChanged {
+		t.Errorf("dirty: got %v", got)
+	}
+}
+
+func TestPoll_TrustsListedDirty(t *testing.T) {
+	e := scripted(nil, nil)
+	calls := 0
+	e.isDirty = func(string) (bool, error) {
+		calls++
+		return false, nil
+	}
+	e.SetTargets([]Target{{Repo: "repo", Worktree: "a", Path: "/wt/a", Dirty: true}})
+	e.Poll()
+	if calls != 0 {
+		t.Errorf("first poll ran git status %d times; the listing already had it", calls)
+	}
+	if st := e.Snapshot(); !st[0].Dirty {
+		t.Error("listed dirty state lost")
+	}
+}
+
+func TestSetTargets_KeepsState(t *testing.T) {
+	live := map[string]session.LiveInfo{
+		"repo/a": {Exists: true},
+	}
+	e := scripted(live, nil)
+	e.SetTargets([]Target{{Repo: "repo", Worktree: "a"}})
+	e.Poll()
+
+	e.SetTargets([]Target{{Repo: "repo", Worktree: "a"}, {Repo: "repo", Worktree: "b"}})
+	snap := e.Snapshot()
+	if len(snap) != 2 {
+		t.Fatalf("snapshot len = %d", len(snap))
+	}
+	if !snap[0].Live.Exists {
+		t.Error("existing target lost its state")
+	}
+	if snap[1].Key() != "repo/b" {
+		t.Errorf("second target = %q", snap[1].Key())
+	}
+}
+
+func TestRun_ClosesSubscribersOnCancel(t *testing.T) {
+	e := scripted(nil, nil)
+	ch, cancel := e.Subscribe(1)
+	defer cancel()
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+
+	stop()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+
+	for range ch {
+	}
+}
+
+func TestSubscribe_CancelStopsDelivery(t *testing.T) {
+	e := scripted(nil, nil)
+	ch, cancel := e.Subscribe(4)
+	cancel()
+	cancel() // idempotent
+
+	e.Poll()
+	if _, ok := <-ch; ok {
+		t.Error("expected closed channel after cancel")
+	}
+}
+
+func TestPoll_RecordsToStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	st, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live := map[string]session.LiveInfo{
+		"repo/a": {Exists: true, HasClaude: true, Status: session.StatusWaiting},
+		"repo/b": {Exists: true},
+	}
+	e := scripted(live, nil)
+	e.SetStore(st)
+	e.SetTargets([]Target{
+		{Repo: "repo", Worktree: "a", Path: "/wt/a"},
+		{Repo: "repo", Worktree: "b", Path: "/wt/b"},
+	})
+	e.Poll()
+
+	reloaded, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := reloaded.Get("/wt/a")
+	if !ok || r.Status != session.StatusWaiting {
+		t.Errorf("record a = %+v, %v", r, ok)
+	}
+	if _, ok := reloaded.Get("/wt/b"); ok {
+		t.Error("window without Claude should not be recorded")
+	}
+}
+
+func TestPoll_SinceFromStore(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Unix(1000, 0)
+	st.RecordStatus("/wt/a", "repo", "a", session.StatusWaiting, t0)
+
+	// A new process's detector has only just seen the status
+	now := t0.Add(time.Hour)
+	live := map[string]session.LiveInfo{
+		"repo/a": {Exists: true, HasClaude: true, Status: session.StatusWaiting, Since: now, PaneID: "%1",
+			Panes: []session.PaneStatus{{ID: "%1", HasClaude: true, Status: session.StatusWaiting, Since: now}}},
+	}
+	e := scripted(live, nil)
+	e.now = func() time.Time { return now }
+	e.SetStore(st)
+	e.SetTargets([]Target{{Repo: "repo", Worktree: "a", Path: "/wt/a"}})
+	e.Poll()
+
+	got := e.Snapshot()[0].Live
+	if !got.Since.Equal(t0) || !got.Panes[0].Since.Equal(t0) {
+		t.Errorf("since = %v (pane %v), want the stored %v", got.Since, got.Panes[0].Since, t0)
+	}
+}
+
+func TestControl_SkipTick(t *testing.T) {
+	live := map[string]session.LiveInfo{
+		"repo/a": {Exists: true, HasClaude: true, Panes: []session.PaneStatus{{ID: "%3"}}},
+	}
+	e := scripted(live, nil)
+	e.interval = 500 * time.Millisecond
+	e.SetDebounce(session.Debounce{Hysteresis: 2})
+	e.SetTargets([]Target{{Repo: "repo", Worktree: "a"}})
+	e.Poll()
+
+	now := time.Unix(1000, 0)
+	sess := "pr-repo"
+	tests := []struct {
+		name                string
+		sess                string
+		lastEvent, lastPoll time.Duration // before now
+		skip                bool
+	}{
+		{"quiet", sess, time.Minute, time.Second, true},
+		{"settling after output", sess, time.Second, time.Second, false},
+		{"fallback due", sess, time.Minute, fallbackInterval, false},
+		{"target in another session", "pr-other", time.Minute, time.Second, false},
+	}
+	for _, tt := range tests {
+		if got := e.skipTick(tt.sess, now, now.Add(-tt.lastEvent), now.Add(-tt.lastPoll)); got != tt.skip {
+			t.Errorf("%s: skipTick = %v, want %v", tt.name, got, tt.skip)
+		}
+	}
+
+	if !e.relevant(tmux.Event{Kind: tmux.EventOutput, Pane: "%3"}) {
+		t.Error("output from an agent pane is not relevant")
+	}
+	if e.relevant(tmux.Event{Kind: tmux.EventOutput, Pane: "%9"}) {
+		t.Error("output from another pane is relevant")
+	}
+	if !e.relevant(tmux.Event{Kind: tmux.EventWindowClose, Window: "@2"}) {
+		t.Error("window close is not relevant")
+	}
+}
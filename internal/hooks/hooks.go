@@ -0,0 +1,233 @@
+// Package hooks runs user-configured shell commands on lifecycle events
+// (worktree created, session opened, agent waiting, merge completed,
+// worktree deleted). Each command gets the event as JSON on stdin and as
+// PARKRANGER_* environment variables; output is captured for display.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grins/parkranger/internal/config"
+)
+
+// maxResults bounds the history of results kept for display.
+const maxResults = 20
+
+// Event names a lifecycle point. The values double as config keys.
+type Event string
+
+const (
+	WorktreeCreated Event = "worktree_created"
+	SessionOpened   Event = "session_opened"
+	AgentWaiting    Event = "agent_waiting"
+	MergeCompleted  Event = "merge_completed"
+	WorktreeDeleted Event = "worktree_deleted"
+)
+
+// Commands returns the commands configured for ev.
+func Commands(cfg config.Hooks, ev Event) []string {
+	switch ev {
+	case WorktreeCreated:
+		return cfg.WorktreeCreated
+	case SessionOpened:
+		return cfg.SessionOpened
+	case AgentWaiting:
+		return cfg.AgentWaiting
+	case MergeCompleted:
+		return cfg.MergeCompleted
+	case WorktreeDeleted:
+		return cfg.WorktreeDeleted
+	default:
+		return nil
+	}
+}
+
+// Payload is the JSON document written to each hook's stdin.
+type Payload struct {
+	Event     Event  `json:"event"`
+	Repo      string `json:"repo"`
+	RepoPath  string `json:"repo_path"`
+	Worktree  string `json:"worktree"`
+	Path      string `json:"path"`
+	Branch    string `json:"branch,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Status    string `json:"status,omitempty"`
+}
+
+// env returns the payload as PARKRANGER_* environment variables.
+func (p Payload) env() []string {
+	return []string{
+		"PARKRANGER_EVENT=" + string(p.Event),
+		"PARKRANGER_REPO=" + p.Repo,
+		"PARKRANGER_REPO_PATH=" + p.RepoPath,
+		"PARKRANGER_WORKTREE=" + p.Worktree,
+		"PARKRANGER_PATH=" + p.Path,
+		"PARKRANGER_BRANCH=" + p.Branch,
+		"PARKRANGER_SESSION_ID=" + p.SessionID,
+		"PARKRANGER_STATUS=" + p.Status,
+	}
+}
+
+// Result is the outcome of one hook command.
+type Result struct {
+	Event    Event
+	Repo     string
+	Worktree string
+	Command  string
+	Output   string // combined stdout+stderr, trimmed
+	Err      error  // nil on exit 0
+	Started  time.Time
+	Duration time.Duration
+}
+
+// resultJSON is the wire form of a Result, with the error as text.
+type resultJSON struct {
+	Event    Event         `json:"event"`
+	Repo     string        `json:"repo"`
+	Worktree string        `json:"worktree"`
+	Command  string        `json:"command"`
+	Output   string        `json:"output,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+}
+
+func (r Result) MarshalJSON() ([]byte, error) {
+	j := resultJSON{
+		Event: r.Event, Repo: r.Repo, Worktree: r.Worktree, Command: r.Command,
+		Output: r.Output, Started: r.Started, Duration: r.Duration,
+	}
+	if r.Err != nil {
+		j.Error = r.Err.Error()
+	}
+	return json.Marshal(j)
+}
+
+func (r *Result) UnmarshalJSON(data []byte) error {
+	var j resultJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*r = Result{
+		Event: j.Event, Repo: j.Repo, Worktree: j.Worktree, Command: j.Command,
+		Output: j.Output, Started: j.Started, Duration: j.Duration,
+	}
+	if j.Error != "" {
+		r.Err = errors.New(j.Error)
+	}
+	return nil
+}
+
+// Timeout returns the per-command limit for ev. session_opened hooks run
+// before the window is attached, so they get their own, shorter limit.
+func Timeout(cfg config.Hooks, ev Event) time.Duration {
+	if ev == SessionOpened {
+		return cfg.SessionOpenedTimeout.Duration
+	}
+	return cfg.Timeout.Duration
+}
+
+// Runner executes hooks and remembers recent results. Safe for concurrent use.
+type Runner struct {
+	mu      sync.Mutex
+	results []Result
+}
+
+// Run executes every command configured for p.Event in order and returns
+// their results. Commands run in p.Path, or p.RepoPath when the worktree is
+// gone (e.g. after deletion). A failing command doesn't stop the rest.
+func (r *Runner) Run(cfg config.Hooks, p Payload) []Result {
+	cmds := Commands(cfg, p.Event)
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	stdin, err := json.Marshal(p)
+	if err != nil {
+		return nil
+	}
+
+	dir := p.Path
+	if _, err := os.Stat(dir); dir == "" || err != nil {
+		dir = p.RepoPath
+	}
+
+	var results []Result
+	for _, command := range cmds {
+		res := runOne(command, dir, stdin, p, Timeout(cfg, p.Event))
+		results = append(results, res)
+	}
+
+	r.mu.Lock()
+	r.results = append(r.results, results...)
+	if n := len(r.results); n > maxResults {
+		r.results = append([]Result(nil), r.results[n-maxResults:]...)
+	}
+	r.mu.Unlock()
+
+	return results
+}
+
+// runOne executes a single hook command.
+func runOne(command, dir string, stdin []byte, p Payload, timeout time.Duration) Result {
+	res := Result{
+		Event:    p.Event,
+		Repo:     p.Repo,
+		Worktree: p.Worktree,
+		Command:  command,
+		Started:  time.Now(),
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), p.env()...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	// Don't let a backgrounded grandchild holding the pipe outlive the timeout.
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	res.Duration = time.Since(res.Started)
+	res.Output = strings.TrimSpace(out.String())
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	res.Err = err
+	return res
+}
+
+// Recent returns up to n of the most recent results, oldest first,
+// skipping results that started before since.
+func (r *Runner) Recent(n int, since time.Time) []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []Result
+	for _, res := range r.results {
+		if !res.Started.Before(since) {
+			out = append(out, res)
+		}
+	}
+	if len(out) > n {
+		out = out[len(out)-n:]
+	}
+	return out
+}
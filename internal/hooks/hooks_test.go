@@ -0,0 +1,132 @@
+package hooks
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grins/parkranger/internal/config"
+)
+
+func TestRun_PayloadAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Hooks{
+		Timeout: config.Duration{Duration: 5 * time.Second},
+		WorktreeCreated: []string{
+			`cat`,
+			`printf '%s %s %s' "$PARKRANGER_EVENT" "$PARKRANGER_WORKTREE" "$(basename "$PWD")"`,
+		},
+	}
+	p := Payload{
+		Event:    WorktreeCreated,
+		Repo:     "repo",
+		RepoPath: "/nonexistent",
+		Worktree: "feat-x",
+		Path:     dir,
+		Branch:   "feat-x",
+	}
+
+	var r Runner
+	results := r.Run(cfg, p)
+	if len(results) != 2 {
+		t.Fatalf("got %d results", len(results))
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			t.Fatalf("%s: %v\n%s", res.Command, res.Err, res.Output)
+		}
+	}
+
+	var got Payload
+	if err := json.Unmarshal([]byte(results[0].Output), &got); err != nil {
+		t.Fatalf("stdin payload: %v (%q)", err, results[0].Output)
+	}
+	if got != p {
+		t.Errorf("payload = %+v, want %+v", got, p)
+	}
+
+	want := "worktree_created feat-x " + dir[strings.LastIndex(dir, "/")+1:]
+	if results[1].Output != want {
+		t.Errorf("env output = %q, want %q", results[1].Output, want)
+	}
+}
+
+func TestRun_FailureAndTimeout(t *testing.T) {
+	cfg := config.Hooks{
+		Timeout:         config.Duration{Duration: 200 * time.Millisecond},
+		WorktreeDeleted: []string{"echo oops; exit 3", "sleep 5", "echo after"},
+	}
+	p := Payload{Event: WorktreeDeleted, RepoPath: t.TempDir(), Path: "/gone"}
+
+	var r Runner
+	results := r.Run(cfg, p)
+	if len(results) != 3 {
+		t.Fatalf("got %d results", len(results))
+	}
+	if results[0].Err == nil || results[0].Output != "oops" {
+		t.Errorf("failing hook = %+v", results[0])
+	}
+	if results[1].Err == nil || !strings.Contains(results[1].Err.Error(), "timed out") {
+		t.Errorf("slow hook err = %v", results[1].Err)
+	}
+	if results[2].Err != nil || results[2].Output != "after" {
+		t.Errorf("hooks after a failure should still run: %+v", results[2])
+	}
+}
+
+func TestRun_NoCommands(t *testing.T) {
+	var r Runner
+	if got := r.Run(config.Hooks{}, Payload{Event: SessionOpened}); got != nil {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestRecent(t *testing.T) {
+	var r Runner
+	cfg := config.Hooks{SessionOpened: []string{"true", "true", "true"}}
+	r.Run(cfg, Payload{Event: SessionOpened, Path: t.TempDir()})
+
+	if got := r.Recent(2, time.Time{}); len(got) != 2 {
+		t.Errorf("Recent(2) = %d results", len(got))
+	}
+	if got := r.Recent(10, time.Now().Add(time.Hour)); len(got) != 0 {
+		t.Errorf("Recent(since future) = %d results", len(got))
+	}
+}
+
+func TestRun_SessionOpenedTimeout(t *testing.T) {
+	cfg := config.Hooks{
+		Timeout:              config.Duration{Duration: time.Minute},
+		SessionOpenedTimeout: config.Duration{Duration: 100 * time.Millisecond},
+		SessionOpened:        []string{"sleep 5"},
+	}
+	var r Runner
+	results := r.Run(cfg, Payload{Event: SessionOpened, Path: t.TempDir()})
+	if len(results) != 1 || results[0].Err == nil || results[0].Duration > 3*time.Second {
+		t.Errorf("session_opened hook = %+v, want it cut off by its own timeout", results)
+	}
+}
+
+func TestResult_JSON(t *testing.T) {
+	in := []Result{
+		{Event: AgentWaiting, Repo: "app", Worktree: "feat", Command: "notify", Err: errors.New("exit status 1"), Started: time.Unix(100, 0).UTC(), Duration: time.Second},
+		{Event: AgentWaiting, Command: "true", Started: time.Unix(200, 0).UTC()},
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []Result
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].Err == nil || out[0].Err.Error() != "exit status 1" || out[1].Err != nil {
+		t.Fatalf("round trip = %+v", out)
+	}
+	out[0].Err, in[0].Err = nil, nil
+	if out[0] != in[0] {
+		t.Errorf("round trip = %+v, want %+v", out[0], in[0])
+	}
+}
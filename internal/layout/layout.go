@@ -0,0 +1,186 @@
+// Package layout builds multiplexer windows from the declarative pane trees
+// in config: splits, sizes, per-pane commands, agent tags and extra windows.
+package layout
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/grins/parkranger/internal/config"
+	"github.com/grins/parkranger/internal/mux"
+)
+
+// Vars are substituted into pane commands.
+type Vars struct {
+	Editor string // {editor}
+	Agent  string // {agent}
+}
+
+// expand substitutes vars into a pane command. Agent leaves without a
+// command run the agent.
+func (v Vars) expand(p config.Pane) string {
+	cmd := p.Command
+	if cmd == "" && p.Agent {
+		cmd = "{agent}"
+	}
+	return strings.NewReplacer("{editor}", v.Editor, "{agent}", v.Agent).Replace(cmd)
+}
+
+// ExtraWindowName returns the window name of a layout's extra window.
+func ExtraWindowName(m mux.Multiplexer, window, name string) string {
+	return m.WindowName(window + "/" + name)
+}
+
+// Open creates the worktree window and the layout's extra windows in
+// session, all rooted at dir. Returns the agent pane IDs of the main window;
+// the first of them is selected.
+func Open(m mux.Multiplexer, session, window, dir string, l config.Layout, vars Vars) ([]string, error) {
+	root, err := m.NewWindow(session, window, dir)
+	if err != nil {
+		return nil, err
+	}
+	agents, err := Build(m, root, dir, l.Pane, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range l.Windows {
+		extraRoot, err := m.NewWindow(session, ExtraWindowName(m, window, w.Name), dir)
+		if err != nil {
+			return agents, err
+		}
+		if _, err := Build(m, extraRoot, dir, w.Pane, vars); err != nil {
+			return agents, err
+		}
+	}
+
+	// new-window made the last extra window current — go back to the main one
+	if len(l.Windows) > 0 {
+		if err := m.SelectWindow(session, window); err != nil {
+			return agents, err
+		}
+	}
+	if len(agents) > 0 {
+		if err := m.SelectPane(agents[0]); err != nil {
+			return agents, err
+		}
+	}
+	return agents, nil
+}
+
+// Build lays out the tree p inside the fresh pane rootID, then starts each
+// leaf's command. Returns the agent pane IDs in tree order.
+func Build(m mux.Multiplexer, rootID, dir string, p config.Pane, vars Vars) ([]string, error) {
+	var leaves []leaf
+	if err := split(m, rootID, dir, p, &leaves); err != nil {
+		return nil, err
+	}
+
+	// Commands start only once every split is done, so programs that size
+	// themselves at startup (editors, TUIs) see their final pane size.
+	var agents []string
+	for _, l := range leaves {
+		if cmd := vars.expand(l.pane); cmd != "" {
+			if err := m.SendKeys(l.id, cmd); err != nil {
+				return agents, err
+			}
+		}
+		if l.pane.Agent {
+			if err := m.TagAgentPane(l.id); err != nil {
+				return agents, err
+			}
+			agents = append(agents, l.id)
+		}
+	}
+	return agents, nil
+}
+
+// leaf is a built pane that runs a command.
+type leaf struct {
+	id   string
+	pane config.Pane
+}
+
+// split recursively divides pane id according to p, collecting leaves.
+func split(m mux.Multiplexer, id, dir string, p config.Pane, leaves *[]leaf) error {
+	if len(p.Panes) == 0 {
+		*leaves = append(*leaves, leaf{id: id, pane: p})
+		return nil
+	}
+
+	shares, err := Shares(p.Panes)
+	if err != nil {
+		return err
+	}
+	horizontal := p.Split != "vertical"
+
+	// Carve children 1..n-1 off the end one at a time: each split gives the
+	// new pane the remainder's share of what's left of the previous pane.
+	ids := []string{id}
+	cur := id
+	for i, size := range SplitSizes(shares) {
+		next, err := m.SplitPane(cur, dir, horizontal, size)
+		if err != nil {
+			return fmt.Errorf("split for pane %d: %w", i+1, err)
+		}
+		ids = append(ids, next)
+		cur = next
+	}
+
+	for i, child := range p.Panes {
+		if err := split(m, ids[i], dir, child, leaves); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shares returns each pane's percentage of its parent. Panes without a size
+// split whatever the sized ones leave, evenly.
+func Shares(panes []config.Pane) ([]float64, error) {
+	shares := make([]float64, len(panes))
+	var used float64
+	var unsized int
+	for i, p := range panes {
+		if p.Size == "" {
+			unsized++
+			continue
+		}
+		pct, err := config.ParseSize(p.Size)
+		if err != nil {
+			return nil, err
+		}
+		shares[i] = pct
+		used += pct
+	}
+
+	rest := 100 - used
+	if used > 100 || (unsized > 0 && rest <= 0) {
+		return nil, fmt.Errorf("pane sizes add up to %g%%, leaving no room", used)
+	}
+	for i, p := range panes {
+		if p.Size == "" {
+			shares[i] = rest / float64(unsized)
+		}
+	}
+	return shares, nil
+}
+
+// SplitSizes converts shares into the -l arguments for the n-1 sequential
+// splits that produce them.
+func SplitSizes(shares []float64) []string {
+	var sizes []string
+	for i := 1; i < len(shares); i++ {
+		var rest, prev float64
+		for _, s := range shares[i:] {
+			rest += s
+		}
+		prev = rest + shares[i-1]
+		pct := int(math.Round(rest / prev * 100))
+		pct = max(1, min(99, pct))
+		sizes = append(sizes, strconv.Itoa(pct)+"%")
+	}
+	return sizes
+}
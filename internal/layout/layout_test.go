@@ -0,0 +1,181 @@
+package layout
+
+import (
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/grins/parkranger/internal/config"
+	"github.com/grins/parkranger/internal/mux"
+	"github.com/grins/parkranger/internal/tmux"
+)
+
+func TestShares(t *testing.T) {
+	tests := []struct {
+		name    string
+		sizes   []string
+		want    []float64
+		wantErr bool
+	}{
+		{"even", []string{"", ""}, []float64{50, 50}, false},
+		{"one sized", []string{"", "30%"}, []float64{70, 30}, false},
+		{"rest split", []string{"20%", "", ""}, []float64{20, 40, 40}, false},
+		{"all sized", []string{"25%", "75%"}, []float64{25, 75}, false},
+		{"over 100", []string{"60%", "60%"}, nil, true},
+		{"no room", []string{"50%", "50%", ""}, nil, true},
+		{"not percent", []string{"30", ""}, nil, true},
+		{"zero", []string{"0%", ""}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var panes []config.Pane
+			for _, s := range tt.sizes {
+				panes = append(panes, config.Pane{Size: s})
+			}
+			got, err := Shares(panes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Shares err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Shares = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitSizes(t *testing.T) {
+	tests := []struct {
+		shares []float64
+		want   []string
+	}{
+		{[]float64{100}, nil},
+		{[]float64{50, 50}, []string{"50%"}},
+		{[]float64{70, 30}, []string{"30%"}},
+		// thirds: first split keeps 1/3, the second halves the rest
+		{[]float64{100.0 / 3, 100.0 / 3, 100.0 / 3}, []string{"67%", "50%"}},
+		{[]float64{20, 40, 40}, []string{"80%", "50%"}},
+	}
+
+	for _, tt := range tests {
+		got := SplitSizes(tt.shares)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitSizes(%v) = %v, want %v", tt.shares, got, tt.want)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	vars := Vars{Editor: "nvim", Agent: "claude --resume abc"}
+	tests := []struct {
+		pane config.Pane
+		want string
+	}{
+		{config.Pane{Command: "{editor} ."}, "nvim ."},
+		{config.Pane{Agent: true}, "claude --resume abc"},
+		{config.Pane{Agent: true, Command: "{agent} --verbose"}, "claude --resume abc --verbose"},
+		{config.Pane{}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := vars.expand(tt.pane); got != tt.want {
+			t.Errorf("expand(%+v) = %q, want %q", tt.pane, got, tt.want)
+		}
+	}
+}
+
+// TestOpen builds a layout on a private tmux server.
+func TestOpen(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	t.Setenv("TMUX", "")
+
+	dir := t.TempDir()
+	if err := tmux.CreateSession("pr-test", dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { exec.Command("tmux", "kill-server").Run() })
+
+	l := config.Layout{
+		Pane: config.Pane{
+			Split: "horizontal",
+			Panes: []config.Pane{
+				{Command: "true"},
+				{Split: "vertical", Panes: []config.Pane{
+					{Agent: true, Command: "true"},
+					{Size: "25%", Command: "true"},
+				}},
+			},
+		},
+		Windows: []config.Window{{Name: "logs", Pane: config.Pane{Command: "true"}}},
+	}
+
+	agents, err := Open(mux.Tmux{}, "pr-test", "feat-x", dir, l, Vars{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(agents) != 1 {
+		t.Fatalf("agent panes = %v, want 1", agents)
+	}
+	if got := tmux.AgentPanes("pr-test", "feat-x"); !reflect.DeepEqual(got, agents) {
+		t.Errorf("AgentPanes = %v, want %v", got, agents)
+	}
+
+	out, err := exec.Command("tmux", "list-panes", "-t", "pr-test:feat-x", "-F", "#{pane_id}").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(strings.Fields(string(out))); n != 3 {
+		t.Errorf("main window has %d panes, want 3", n)
+	}
+	if !tmux.WindowExists("pr-test", ExtraWindowName(mux.Tmux{}, "feat-x", "logs")) {
+		t.Error("extra window not created")
+	}
+
+	active, _ := exec.Command("tmux", "display-message", "-p", "-t", "pr-test", "#{window_name} #{pane_id}").Output()
+	if got, want := strings.TrimSpace(string(active)), "feat-x "+agents[0]; got != want {
+		t.Errorf("active = %q, want %q", got, want)
+	}
+}
+
+func TestOpen_Fake(t *testing.T) {
+	m := mux.NewFake()
+	if _, err := m.EnsureSession("pr-test", "/repo"); err != nil {
+		t.Fatal(err)
+	}
+	l := config.Layout{
+		Pane: config.Pane{
+			Split: "horizontal",
+			Panes: []config.Pane{
+				{Command: "{editor} ."},
+				{Agent: true},
+				{Command: "make watch"},
+			},
+		},
+		Windows: []config.Window{{Name: "logs", Pane: config.Pane{Command: "tail -f log"}}},
+	}
+
+	agents, err := Open(m, "pr-test", "feat-x", "/wt", l, Vars{Editor: "vim", Agent: "claude"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	panes := m.ListPanes("pr-test", "feat-x")
+	if len(panes) != 3 {
+		t.Fatalf("panes = %+v, want 3", panes)
+	}
+	want := [][]string{{"vim ."}, {"claude"}, {"make watch"}}
+	for i, p := range panes {
+		if got := m.Sent(p.ID); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("pane %d typed %q, want %q", i, got, want[i])
+		}
+	}
+	if !reflect.DeepEqual(agents, []string{panes[1].ID}) || !panes[1].Agent {
+		t.Errorf("agents = %v, panes = %+v", agents, panes)
+	}
+	if !m.WindowExists("pr-test", ExtraWindowName(m, "feat-x", "logs")) {
+		t.Error("extra window not created")
+	}
+}
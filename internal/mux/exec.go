@@ -0,0 +1,58 @@
+package mux
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runner runs a command and returns its stdout. Backends take one so tests
+// can script the CLI.
+type runner func(name string, args ...string) (string, error)
+
+// execRun runs the command, folding stderr into the error.
+func execRun(name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s %s: %w\n%s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// New returns the backend with the given name: "tmux", "zellij" or
+// "wezterm".
+func New(name string) (Multiplexer, error) {
+	switch name {
+	case "", "tmux":
+		return Tmux{}, nil
+	case "zellij":
+		return NewZellij(), nil
+	case "wezterm":
+		return NewWezTerm(), nil
+	}
+	return nil, fmt.Errorf("unknown multiplexer %q (want tmux, zellij or wezterm)", name)
+}
+
+// bottomRows returns the last n rows of screen, ignoring a trailing newline.
+func bottomRows(screen string, n int) string {
+	rows := strings.Split(strings.TrimSuffix(screen, "\n"), "\n")
+	if len(rows) > n {
+		rows = rows[len(rows)-n:]
+	}
+	return strings.Join(rows, "\n")
+}
+
+// lines splits command output into its non-empty lines.
+func lines(out string) []string {
+	var ls []string
+	for _, l := range strings.Split(out, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
@@ -0,0 +1,8 @@
+package mux
+
+// NewZellijRun returns a zellij backend whose CLI calls go to run.
+func NewZellijRun(run func(name string, args ...string) (string, error)) *Zellij {
+	z := NewZellij()
+	z.run = run
+	return z
+}
@@ -0,0 +1,289 @@
+package mux
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/grins/parkranger/internal/tmux"
+)
+
+// Fake is an in-memory Multiplexer for tests. Windows and panes are created
+// as a real multiplexer would, while what runs in them is scripted: set a
+// pane's screen with SetScreen, its command or state with Update, and react
+// to typed input with OnSend. It records what was typed and attached.
+type Fake struct {
+	// OnSend, if set, runs after every SendKeys, without the lock held, e.g.
+	// to start a fake agent when its command is typed.
+	OnSend func(f *Fake, pane, keys string)
+	// SnapshotErr, if set, fails Snapshot, as an unreadable server would.
+	SnapshotErr error
+
+	mu       sync.Mutex
+	snap     Snapshot
+	screens  map[string]string   // pane ID → captured screen
+	sent     map[string][]string // pane ID → typed lines
+	attached string              // "session:window"
+	nextID   int
+}
+
+var _ Multiplexer = (*Fake)(nil)
+
+// NewFake returns an empty fake multiplexer.
+func NewFake() *Fake {
+	return &Fake{screens: make(map[string]string), sent: make(map[string][]string)}
+}
+
+func (f *Fake) SessionName(repo string) string    { return tmux.SessionName(repo) }
+func (f *Fake) WindowName(worktree string) string { return tmux.WindowName(worktree) }
+
+func (f *Fake) SessionExists(session string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.session(session) != nil
+}
+
+func (f *Fake) EnsureSession(session, dir string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.session(session) != nil {
+		return false, nil
+	}
+	f.snap.Sessions = append(f.snap.Sessions, Session{ID: f.id("$"), Name: session})
+	f.newWindow(f.session(session), DashboardWindow)
+	return true, nil
+}
+
+func (f *Fake) WindowExists(session, window string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.window(session, window) != nil
+}
+
+func (f *Fake) NewWindow(session, window, dir string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.session(session)
+	if s == nil {
+		return "", fmt.Errorf("fake: no session %q", session)
+	}
+	return f.newWindow(s, window), nil
+}
+
+func (f *Fake) SelectWindow(session, window string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.window(session, window) == nil {
+		return fmt.Errorf("fake: no window %s:%s", session, window)
+	}
+	return nil
+}
+
+func (f *Fake) KillWindow(session, window string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.session(session)
+	if s == nil || f.window(session, window) == nil {
+		return fmt.Errorf("fake: no window %s:%s", session, window)
+	}
+	for i, w := range s.Windows {
+		if w.Name == window {
+			s.Windows = append(s.Windows[:i:i], s.Windows[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (f *Fake) AttachWindow(session, window string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.window(session, window) == nil {
+		return fmt.Errorf("fake: no window %s:%s", session, window)
+	}
+	f.attached = session + ":" + window
+	return nil
+}
+
+func (f *Fake) SplitPane(pane, dir string, horizontal bool, size string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w, i := f.pane(pane)
+	if w == nil {
+		return "", fmt.Errorf("fake: no pane %s", pane)
+	}
+	p := Pane{ID: f.id("%"), Index: len(w.Panes), Command: "sh", Width: 80, Height: 24}
+	w.Panes = append(w.Panes[:i+1:i+1], append([]Pane{p}, w.Panes[i+1:]...)...)
+	for j := range w.Panes {
+		w.Panes[j].Index = j
+	}
+	return p.ID, nil
+}
+
+func (f *Fake) SelectPane(pane string) error {
+	return f.Update(pane, func(*Pane) {})
+}
+
+func (f *Fake) TagAgentPane(pane string) error {
+	return f.Update(pane, func(p *Pane) { p.Agent = true })
+}
+
+func (f *Fake) RespawnPane(pane, dir, command string) error {
+	f.mu.Lock()
+	w, i := f.pane(pane)
+	if w == nil {
+		f.mu.Unlock()
+		return fmt.Errorf("fake: no pane %s", pane)
+	}
+	w.Panes[i].Dead = false
+	f.sent[pane] = append(f.sent[pane], command)
+	f.mu.Unlock()
+	if f.OnSend != nil {
+		f.OnSend(f, pane, command)
+	}
+	return nil
+}
+
+func (f *Fake) SendKeys(pane, keys string) error {
+	f.mu.Lock()
+	if w, _ := f.pane(pane); w == nil {
+		f.mu.Unlock()
+		return fmt.Errorf("fake: no pane %s", pane)
+	}
+	f.sent[pane] = append(f.sent[pane], keys)
+	f.mu.Unlock()
+	if f.OnSend != nil {
+		f.OnSend(f, pane, keys)
+	}
+	return nil
+}
+
+func (f *Fake) Capture(p Pane, lines int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rows := strings.Split(f.screens[p.ID], "\n")
+	if len(rows) > lines {
+		rows = rows[len(rows)-lines:]
+	}
+	return strings.Join(rows, "\n"), nil
+}
+
+func (f *Fake) Snapshot() (Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.SnapshotErr != nil {
+		return Snapshot{}, f.SnapshotErr
+	}
+	return f.copySnapshot(), nil
+}
+
+func (f *Fake) ListPanes(session, window string) []Pane {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if w := f.window(session, window); w != nil {
+		return append([]Pane(nil), w.Panes...)
+	}
+	return nil
+}
+
+// SetScreen sets what capturing the pane returns.
+func (f *Fake) SetScreen(pane, screen string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.screens[pane] = screen
+}
+
+// Update changes a pane in place, e.g. its Command or Dead flag.
+func (f *Fake) Update(pane string, change func(*Pane)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w, i := f.pane(pane)
+	if w == nil {
+		return fmt.Errorf("fake: no pane %s", pane)
+	}
+	change(&w.Panes[i])
+	return nil
+}
+
+// Sent returns the lines typed into a pane with SendKeys, and the commands
+// it was respawned with, in order.
+func (f *Fake) Sent(pane string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.sent[pane]...)
+}
+
+// Attached returns the last window attached, as "session:window".
+func (f *Fake) Attached() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attached
+}
+
+// id returns a fresh ID with prefix: "$" sessions, "@" windows, "%" panes.
+func (f *Fake) id(prefix string) string {
+	id := fmt.Sprintf("%s%d", prefix, f.nextID)
+	f.nextID++
+	return id
+}
+
+// newWindow appends a window with one shell pane and returns the pane's ID.
+func (f *Fake) newWindow(s *Session, name string) string {
+	p := Pane{ID: f.id("%"), Command: "sh", Width: 80, Height: 24}
+	s.Windows = append(s.Windows, Window{ID: f.id("@"), Index: len(s.Windows), Name: name, Panes: []Pane{p}})
+	return p.ID
+}
+
+func (f *Fake) session(name string) *Session {
+	for i := range f.snap.Sessions {
+		if f.snap.Sessions[i].Name == name {
+			return &f.snap.Sessions[i]
+		}
+	}
+	return nil
+}
+
+func (f *Fake) window(session, name string) *Window {
+	s := f.session(session)
+	if s == nil {
+		return nil
+	}
+	for i := range s.Windows {
+		if s.Windows[i].Name == name {
+			return &s.Windows[i]
+		}
+	}
+	return nil
+}
+
+// pane returns the window holding pane id and the pane's index in it.
+func (f *Fake) pane(id string) (*Window, int) {
+	for i := range f.snap.Sessions {
+		s := &f.snap.Sessions[i]
+		for j := range s.Windows {
+			w := &s.Windows[j]
+			for k := range w.Panes {
+				if w.Panes[k].ID == id {
+					return w, k
+				}
+			}
+		}
+	}
+	return nil, -1
+}
+
+// copySnapshot deep-copies the state, so callers can't alias it.
+func (f *Fake) copySnapshot() Snapshot {
+	var snap Snapshot
+	for _, s := range f.snap.Sessions {
+		cs := s
+		cs.Windows = nil
+		for _, w := range s.Windows {
+			cw := w
+			cw.Panes = append([]Pane(nil), w.Panes...)
+			cs.Windows = append(cs.Windows, cw)
+		}
+		snap.Sessions = append(snap.Sessions, cs)
+	}
+	return snap
+}
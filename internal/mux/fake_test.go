@@ -0,0 +1,42 @@
+package mux
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFake_Panes(t *testing.T) {
+	f := NewFake()
+	if created, _ := f.EnsureSession("pr-app", "/repo"); !created {
+		t.Fatal("EnsureSession did not create the session")
+	}
+	if created, _ := f.EnsureSession("pr-app", "/repo"); created {
+		t.Error("EnsureSession created the session twice")
+	}
+	if _, err := f.NewWindow("pr-none", "feat", "/wt"); err == nil {
+		t.Error("NewWindow in a missing session: no error")
+	}
+
+	left, _ := f.NewWindow("pr-app", "feat", "/wt")
+	right, _ := f.SplitPane(left, "/wt", true, "")
+	middle, _ := f.SplitPane(left, "/wt", true, "")
+	var ids []string
+	for _, p := range f.ListPanes("pr-app", "feat") {
+		ids = append(ids, p.ID)
+	}
+	if want := []string{left, middle, right}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("pane order = %v, want %v (new panes follow the split one)", ids, want)
+	}
+
+	// Snapshots are copies
+	snap, _ := f.Snapshot()
+	snap.Sessions[0].Windows[1].Panes[0].Command = "changed"
+	if p := f.ListPanes("pr-app", "feat")[0]; p.Command == "changed" {
+		t.Error("Snapshot aliases the fake's state")
+	}
+
+	f.SetScreen(left, "one\ntwo\nthree")
+	if got, _ := f.Capture(Pane{ID: left}, 2); got != "two\nthree" {
+		t.Errorf("Capture = %q, want the bottom two rows", got)
+	}
+}
@@ -0,0 +1,97 @@
+// Package mux abstracts the terminal multiplexer parkranger drives: where
+// worktree windows live, how their panes are split, typed into and read.
+// Tmux is the full backend, Zellij and WezTerm drive those through their
+// CLIs with fewer features, and Fake keeps everything in memory for tests.
+package mux
+
+import (
+	"errors"
+
+	"github.com/grins/parkranger/internal/tmux"
+)
+
+// The tmux package's listing types are the common model. Other backends
+// fill them in with their own IDs.
+type (
+	Snapshot = tmux.Snapshot
+	Session  = tmux.Session
+	Window   = tmux.Window
+	Pane     = tmux.Pane
+)
+
+// ErrNotFocused is returned by Capture for a pane the backend can only
+// read while it has the user's focus (Zellij).
+var ErrNotFocused = errors.New("only the focused pane can be captured")
+
+// DashboardWindow is the window EnsureSession creates for the dashboard.
+const DashboardWindow = "dashboard"
+
+// Multiplexer is a terminal multiplexer holding one session per repo and one
+// window per worktree. Panes are addressed by their stable IDs.
+type Multiplexer interface {
+	// SessionName and WindowName turn a repo and a worktree name into names
+	// the multiplexer accepts.
+	SessionName(repo string) string
+	WindowName(worktree string) string
+
+	SessionExists(session string) bool
+	// EnsureSession creates session, rooted at dir, with a DashboardWindow,
+	// unless it exists. Reports whether it created it.
+	EnsureSession(session, dir string) (bool, error)
+	WindowExists(session, window string) bool
+	// NewWindow creates a window and returns the ID of its only pane.
+	NewWindow(session, window, dir string) (string, error)
+	SelectWindow(session, window string) error
+	KillWindow(session, window string) error
+	// AttachWindow shows the window to the user: it switches to it when
+	// running inside the multiplexer, and otherwise attaches, which may
+	// replace this process.
+	AttachWindow(session, window string) error
+
+	// SplitPane splits pane and returns the new pane's ID. horizontal places
+	// the new pane to the right, otherwise below; size is its share, such as
+	// "30%", or empty for half.
+	SplitPane(pane, dir string, horizontal bool, size string) (string, error)
+	SelectPane(pane string) error
+	// TagAgentPane marks a pane as running an agent (Pane.Agent).
+	TagAgentPane(pane string) error
+	// RespawnPane restarts a dead pane with command.
+	RespawnPane(pane, dir, command string) error
+	// SendKeys types keys into the pane, followed by Enter.
+	SendKeys(pane, keys string) error
+	// Capture returns the bottom lines rows of a pane with SGR escapes kept,
+	// or "" if the pane is gone. p.Height is used when known. Backends that
+	// can't read the pane without moving the focus return ErrNotFocused.
+	Capture(p Pane, lines int) (string, error)
+
+	// Snapshot lists every session, window and pane.
+	Snapshot() (Snapshot, error)
+	// ListPanes returns the panes of a window, or nil if it doesn't exist.
+	ListPanes(session, window string) []Pane
+}
+
+// current is the multiplexer the program runs on.
+var current Multiplexer = Tmux{}
+
+// Default returns the multiplexer set with SetDefault, tmux unless changed.
+func Default() Multiplexer {
+	return current
+}
+
+// SetDefault replaces the default multiplexer. Call it at startup, or at the
+// start of a test, before anything uses Default.
+func SetDefault(m Multiplexer) {
+	current = m
+}
+
+// AgentPanes returns the IDs of the tagged agent panes in a window, in pane
+// order.
+func AgentPanes(m Multiplexer, session, window string) []string {
+	var ids []string
+	for _, p := range m.ListPanes(session, window) {
+		if p.Agent {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids
+}
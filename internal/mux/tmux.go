@@ -0,0 +1,64 @@
+package mux
+
+import "github.com/grins/parkranger/internal/tmux"
+
+// Tmux is the tmux backend, wrapping the tmux package.
+type Tmux struct {
+	// Control, if set, carries snapshots and captures, the reads a poller
+	// repeats; every other command forks tmux.
+	Control *tmux.Client
+}
+
+var _ Multiplexer = Tmux{}
+
+func (Tmux) SessionName(repo string) string           { return tmux.SessionName(repo) }
+func (Tmux) WindowName(worktree string) string        { return tmux.WindowName(worktree) }
+func (Tmux) SessionExists(session string) bool        { return tmux.SessionExists(session) }
+func (Tmux) WindowExists(session, window string) bool { return tmux.WindowExists(session, window) }
+
+func (Tmux) EnsureSession(session, dir string) (bool, error) {
+	return tmux.EnsureSession(session, dir)
+}
+
+func (Tmux) NewWindow(session, window, dir string) (string, error) {
+	return tmux.NewWindow(session, window, dir)
+}
+
+func (Tmux) SelectWindow(session, window string) error {
+	return tmux.SelectWindow(session, window)
+}
+
+func (Tmux) KillWindow(session, window string) error {
+	return tmux.KillWindow(session, window)
+}
+
+func (Tmux) AttachWindow(session, window string) error {
+	return tmux.AttachWindow(session, window)
+}
+
+func (Tmux) SplitPane(pane, dir string, horizontal bool, size string) (string, error) {
+	return tmux.SplitPane(pane, dir, horizontal, size)
+}
+
+func (Tmux) SelectPane(pane string) error                { return tmux.SelectPane(pane) }
+func (Tmux) TagAgentPane(pane string) error              { return tmux.TagAgentPane(pane) }
+func (Tmux) RespawnPane(pane, dir, command string) error { return tmux.RespawnPane(pane, dir, command) }
+func (Tmux) SendKeys(pane, keys string) error            { return tmux.SendKeys(pane, keys) }
+
+func (t Tmux) Capture(p Pane, lines int) (string, error) {
+	if t.Control != nil {
+		return t.Control.CaptureBottomStyled(p.ID, p.Height, lines)
+	}
+	return tmux.CaptureBottomStyled(p.ID, p.Height, lines)
+}
+
+func (t Tmux) Snapshot() (Snapshot, error) {
+	if t.Control != nil {
+		return t.Control.TakeSnapshot()
+	}
+	return tmux.TakeSnapshot()
+}
+
+func (Tmux) ListPanes(session, window string) []Pane {
+	return tmux.ListPanes(session, window)
+}
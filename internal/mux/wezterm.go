@@ -0,0 +1,272 @@
+package mux
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/grins/parkranger/internal/tmux"
+	"github.com/grins/parkranger/internal/xdg"
+)
+
+// WezTerm drives a running WezTerm through `wezterm cli`: sessions are
+// workspaces, windows are tabs named by their tab title, and panes keep
+// WezTerm's pane IDs.
+//
+// The CLI can't tag panes, so agent tags are kept in a file in the state
+// directory. Limitations: the foreground command isn't known, so exited
+// agents aren't told apart from running ones, AttachWindow can only switch
+// to a tab of the active workspace's GUI, and RespawnPane isn't supported.
+type WezTerm struct {
+	run  runner
+	tags string // file listing the tagged agent pane IDs
+
+	mu sync.Mutex
+}
+
+var _ Multiplexer = (*WezTerm)(nil)
+
+// NewWezTerm returns the WezTerm backend.
+func NewWezTerm() *WezTerm {
+	return &WezTerm{run: execRun, tags: filepath.Join(xdg.StateDir(), "wezterm-agents")}
+}
+
+func (w *WezTerm) SessionName(repo string) string    { return tmux.SessionName(repo) }
+func (w *WezTerm) WindowName(worktree string) string { return tmux.WindowName(worktree) }
+
+// cli runs `wezterm cli args...` and returns its trimmed output.
+func (w *WezTerm) cli(args ...string) (string, error) {
+	out, err := w.run("wezterm", append([]string{"cli"}, args...)...)
+	return strings.TrimSpace(out), err
+}
+
+// weztermPane is one entry of `wezterm cli list --format json`.
+type weztermPane struct {
+	WindowID  int    `json:"window_id"`
+	TabID     int    `json:"tab_id"`
+	PaneID    int    `json:"pane_id"`
+	Workspace string `json:"workspace"`
+	Title     string `json:"title"`
+	TabTitle  string `json:"tab_title"`
+	Size      struct {
+		Rows int `json:"rows"`
+		Cols int `json:"cols"`
+	} `json:"size"`
+}
+
+func (w *WezTerm) list() ([]weztermPane, error) {
+	out, err := w.cli("list", "--format", "json")
+	if err != nil {
+		return nil, err
+	}
+	var panes []weztermPane
+	if err := json.Unmarshal([]byte(out), &panes); err != nil {
+		return nil, fmt.Errorf("wezterm cli list: %w", err)
+	}
+	return panes, nil
+}
+
+// tab returns the panes of the named tab in a workspace.
+func (w *WezTerm) tab(session, window string) []weztermPane {
+	panes, err := w.list()
+	if err != nil {
+		return nil
+	}
+	return slices.DeleteFunc(panes, func(p weztermPane) bool {
+		return p.Workspace != session || p.TabTitle != window
+	})
+}
+
+func (w *WezTerm) SessionExists(session string) bool {
+	panes, _ := w.list()
+	return slices.ContainsFunc(panes, func(p weztermPane) bool { return p.Workspace == session })
+}
+
+func (w *WezTerm) EnsureSession(session, dir string) (bool, error) {
+	if w.SessionExists(session) {
+		return false, nil
+	}
+	pane, err := w.cli("spawn", "--new-window", "--workspace", session, "--cwd", dir)
+	if err != nil {
+		return false, err
+	}
+	_, err = w.cli("set-tab-title", "--pane-id", pane, DashboardWindow)
+	return true, err
+}
+
+func (w *WezTerm) WindowExists(session, window string) bool {
+	return len(w.tab(session, window)) > 0
+}
+
+func (w *WezTerm) NewWindow(session, window, dir string) (string, error) {
+	panes, err := w.list()
+	if err != nil {
+		return "", err
+	}
+	args := []string{"spawn", "--new-window", "--workspace", session, "--cwd", dir}
+	if i := slices.IndexFunc(panes, func(p weztermPane) bool { return p.Workspace == session }); i >= 0 {
+		args = []string{"spawn", "--window-id", strconv.Itoa(panes[i].WindowID), "--cwd", dir}
+	}
+	pane, err := w.cli(args...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.cli("set-tab-title", "--pane-id", pane, window); err != nil {
+		return "", err
+	}
+	return pane, nil
+}
+
+func (w *WezTerm) SelectWindow(session, window string) error {
+	panes := w.tab(session, window)
+	if len(panes) == 0 {
+		return fmt.Errorf("wezterm: no tab %s in workspace %s", window, session)
+	}
+	_, err := w.cli("activate-tab", "--tab-id", strconv.Itoa(panes[0].TabID))
+	return err
+}
+
+func (w *WezTerm) KillWindow(session, window string) error {
+	panes := w.tab(session, window)
+	if len(panes) == 0 {
+		return fmt.Errorf("wezterm: no tab %s in workspace %s", window, session)
+	}
+	for _, p := range panes {
+		if _, err := w.cli("kill-pane", "--pane-id", strconv.Itoa(p.PaneID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *WezTerm) AttachWindow(session, window string) error {
+	return w.SelectWindow(session, window)
+}
+
+func (w *WezTerm) SplitPane(pane, dir string, horizontal bool, size string) (string, error) {
+	args := []string{"split-pane", "--pane-id", pane, "--bottom", "--cwd", dir}
+	if horizontal {
+		args[3] = "--right"
+	}
+	if pct, ok := strings.CutSuffix(size, "%"); ok {
+		args = append(args, "--percent", pct)
+	} else if size != "" {
+		args = append(args, "--cells", size)
+	}
+	return w.cli(args...)
+}
+
+func (w *WezTerm) SelectPane(pane string) error {
+	_, err := w.cli("activate-pane", "--pane-id", pane)
+	return err
+}
+
+func (w *WezTerm) TagAgentPane(pane string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	tags := w.readTags()
+	if slices.Contains(tags, pane) {
+		return nil
+	}
+	// Drop the tags of panes that are gone while at it
+	if panes, err := w.list(); err == nil {
+		tags = slices.DeleteFunc(tags, func(id string) bool {
+			return !slices.ContainsFunc(panes, func(p weztermPane) bool { return strconv.Itoa(p.PaneID) == id })
+		})
+	}
+	tags = append(tags, pane)
+	if err := os.MkdirAll(filepath.Dir(w.tags), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(w.tags, []byte(strings.Join(tags, "\n")+"\n"), 0o644)
+}
+
+func (w *WezTerm) readTags() []string {
+	data, err := os.ReadFile(w.tags)
+	if err != nil {
+		return nil
+	}
+	return lines(string(data))
+}
+
+func (w *WezTerm) RespawnPane(pane, dir, command string) error {
+	return fmt.Errorf("wezterm: restarting a pane isn't supported")
+}
+
+func (w *WezTerm) SendKeys(pane, keys string) error {
+	_, err := w.cli("send-text", "--pane-id", pane, "--no-paste", keys+"\r")
+	return err
+}
+
+func (w *WezTerm) Capture(p Pane, lines int) (string, error) {
+	out, err := w.run("wezterm", "cli", "get-text", "--pane-id", p.ID, "--escapes")
+	if err != nil {
+		return "", nil // the pane is gone
+	}
+	return bottomRows(out, lines), nil
+}
+
+func (w *WezTerm) Snapshot() (Snapshot, error) {
+	panes, err := w.list()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	w.mu.Lock()
+	tags := w.readTags()
+	w.mu.Unlock()
+	return weztermSnapshot(panes, tags), nil
+}
+
+func (w *WezTerm) ListPanes(session, window string) []Pane {
+	snap, err := w.Snapshot()
+	if err != nil {
+		return nil
+	}
+	for _, s := range snap.Sessions {
+		if s.Name != session {
+			continue
+		}
+		for _, win := range s.Windows {
+			if win.Name == window {
+				return win.Panes
+			}
+		}
+	}
+	return nil
+}
+
+// weztermSnapshot groups listed panes into workspaces and tabs, in listing
+// order.
+func weztermSnapshot(panes []weztermPane, tags []string) Snapshot {
+	var snap Snapshot
+	for _, p := range panes {
+		si := slices.IndexFunc(snap.Sessions, func(s Session) bool { return s.Name == p.Workspace })
+		if si < 0 {
+			snap.Sessions = append(snap.Sessions, Session{ID: p.Workspace, Name: p.Workspace})
+			si = len(snap.Sessions) - 1
+		}
+		s := &snap.Sessions[si]
+		tabID := strconv.Itoa(p.TabID)
+		wi := slices.IndexFunc(s.Windows, func(w Window) bool { return w.ID == tabID })
+		if wi < 0 {
+			s.Windows = append(s.Windows, Window{ID: tabID, Index: len(s.Windows), Name: p.TabTitle})
+			wi = len(s.Windows) - 1
+		}
+		win := &s.Windows[wi]
+		id := strconv.Itoa(p.PaneID)
+		win.Panes = append(win.Panes, Pane{
+			ID:     id,
+			Index:  len(win.Panes),
+			Agent:  slices.Contains(tags, id),
+			Width:  p.Size.Cols,
+			Height: p.Size.Rows,
+			Title:  p.Title,
+		})
+	}
+	return snap
+}
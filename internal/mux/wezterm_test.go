@@ -0,0 +1,75 @@
+package mux
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const weztermList = `[
+  {"window_id": 0, "tab_id": 0, "pane_id": 0, "workspace": "pr-app", "size": {"rows": 40, "cols": 120}, "title": "parkranger", "tab_title": "dashboard"},
+  {"window_id": 0, "tab_id": 3, "pane_id": 5, "workspace": "pr-app", "size": {"rows": 40, "cols": 60}, "title": "vim", "tab_title": "feat"},
+  {"window_id": 0, "tab_id": 3, "pane_id": 6, "workspace": "pr-app", "size": {"rows": 40, "cols": 60}, "title": "claude", "tab_title": "feat"},
+  {"window_id": 1, "tab_id": 4, "pane_id": 7, "workspace": "default", "size": {"rows": 24, "cols": 80}, "title": "zsh", "tab_title": ""}
+]`
+
+func newScriptedWezTerm(t *testing.T) (*WezTerm, *script) {
+	s := &script{answers: map[string]string{
+		"wezterm cli list --format json":                                    weztermList,
+		"wezterm cli spawn --window-id 0 --cwd /wt":                         "9\n",
+		"wezterm cli split-pane --pane-id 9 --right --cwd /wt --percent 30": "10\n",
+	}}
+	w := NewWezTerm()
+	w.run = s.run
+	w.tags = filepath.Join(t.TempDir(), "tags")
+	return w, s
+}
+
+func TestWezTerm_Snapshot(t *testing.T) {
+	w, _ := newScriptedWezTerm(t)
+	if err := w.TagAgentPane("6"); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := w.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snap.Sessions) != 2 || len(snap.Sessions[0].Windows) != 2 {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+	want := []Pane{
+		{ID: "5", Width: 60, Height: 40, Title: "vim"},
+		{ID: "6", Index: 1, Agent: true, Width: 60, Height: 40, Title: "claude"},
+	}
+	if got := w.ListPanes("pr-app", "feat"); !reflect.DeepEqual(got, want) {
+		t.Errorf("panes = %+v, want %+v", got, want)
+	}
+	if !w.WindowExists("pr-app", "dashboard") || w.WindowExists("pr-app", "gone") || w.SessionExists("other") {
+		t.Error("exists checks disagree with the listing")
+	}
+}
+
+func TestWezTerm_Commands(t *testing.T) {
+	w, s := newScriptedWezTerm(t)
+	pane, err := w.NewWindow("pr-app", "fix", "/wt")
+	if err != nil || pane != "9" {
+		t.Fatalf("NewWindow = %q, %v", pane, err)
+	}
+	split, err := w.SplitPane(pane, "/wt", true, "30%")
+	if err != nil || split != "10" {
+		t.Fatalf("SplitPane = %q, %v", split, err)
+	}
+	if err := w.SendKeys(split, "claude"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"wezterm cli list --format json",
+		"wezterm cli spawn --window-id 0 --cwd /wt",
+		"wezterm cli set-tab-title --pane-id 9 fix",
+		"wezterm cli split-pane --pane-id 9 --right --cwd /wt --percent 30",
+		"wezterm cli send-text --pane-id 10 --no-paste claude\r",
+	}
+	if !reflect.DeepEqual(s.calls, want) {
+		t.Errorf("calls = %q, want %q", s.calls, want)
+	}
+}
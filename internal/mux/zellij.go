@@ -0,0 +1,595 @@
+package mux
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/grins/parkranger/internal/tmux"
+)
+
+// Zellij drives zellij through its CLI: sessions are zellij sessions,
+// windows are tabs. The CLI acts on the focused pane and has no pane IDs,
+// so panes are addressed by their position in the tab (the layout's tree
+// order) and focused before typing into or tagging one, restoring focus
+// after. Polling never moves the focus.
+//
+// Pane IDs are handed out per process ("z1", "z2", …) and kept pointing at
+// the same pane across the splits made through this backend. Panes the user
+// adds or closes meanwhile can shift them until the next listing.
+//
+// Limitations: captures are plain text (dump-screen keeps no styles) and
+// only read the pane the user has focused, so agent detection is not
+// supported beyond it; pane sizes aren't set on split, the foreground
+// command is only known for panes started with one, and RespawnPane isn't
+// supported.
+type Zellij struct {
+	run runner
+
+	mu     sync.Mutex
+	ids    map[string]zellijPos
+	nextID int
+}
+
+var _ Multiplexer = (*Zellij)(nil)
+
+// zellijPos is where a pane sits: its tab and its index among the tab's
+// terminal panes.
+type zellijPos struct {
+	session, tab string
+	index        int
+}
+
+// NewZellij returns the zellij backend.
+func NewZellij() *Zellij {
+	return &Zellij{run: execRun, ids: make(map[string]zellijPos)}
+}
+
+// agentPaneName is the pane name TagAgentPane gives agent panes.
+const agentPaneName = "agent"
+
+func (z *Zellij) SessionName(repo string) string    { return tmux.SessionName(repo) }
+func (z *Zellij) WindowName(worktree string) string { return tmux.WindowName(worktree) }
+
+// action runs `zellij --session session action args...`.
+func (z *Zellij) action(session string, args ...string) (string, error) {
+	return z.run("zellij", append([]string{"--session", session, "action"}, args...)...)
+}
+
+func (z *Zellij) sessions() []string {
+	out, err := z.run("zellij", "list-sessions", "--short", "--no-formatting")
+	if err != nil {
+		return nil
+	}
+	return lines(out)
+}
+
+func (z *Zellij) SessionExists(session string) bool {
+	return slices.Contains(z.sessions(), session)
+}
+
+func (z *Zellij) EnsureSession(session, dir string) (bool, error) {
+	if z.SessionExists(session) {
+		return false, nil
+	}
+	// A background session starts in the working directory of the command
+	// creating it
+	if _, err := z.run("sh", "-c", `cd "$1" && exec zellij attach --create-background "$2"`, "sh", dir, session); err != nil {
+		return false, err
+	}
+	if _, err := z.action(session, "rename-tab", DashboardWindow); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+func (z *Zellij) WindowExists(session, window string) bool {
+	out, err := z.action(session, "query-tab-names")
+	return err == nil && slices.Contains(lines(out), window)
+}
+
+func (z *Zellij) NewWindow(session, window, dir string) (string, error) {
+	if _, err := z.action(session, "new-tab", "--name", window, "--cwd", dir); err != nil {
+		return "", err
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	// A reused tab name starts over; IDs into the old tab are stale
+	for id, pos := range z.ids {
+		if pos.session == session && pos.tab == window {
+			delete(z.ids, id)
+		}
+	}
+	return z.idLocked(zellijPos{session, window, 0}), nil
+}
+
+func (z *Zellij) SelectWindow(session, window string) error {
+	_, err := z.action(session, "go-to-tab-name", window)
+	return err
+}
+
+func (z *Zellij) KillWindow(session, window string) error {
+	if err := z.SelectWindow(session, window); err != nil {
+		return err
+	}
+	_, err := z.action(session, "close-tab")
+	return err
+}
+
+func (z *Zellij) AttachWindow(session, window string) error {
+	if err := z.SelectWindow(session, window); err != nil {
+		return err
+	}
+	if cur := os.Getenv("ZELLIJ_SESSION_NAME"); cur == session {
+		return nil
+	} else if cur != "" {
+		return fmt.Errorf("zellij can't switch sessions from the CLI: detach and run zellij attach %s", session)
+	}
+	path, err := exec.LookPath("zellij")
+	if err != nil {
+		return fmt.Errorf("zellij not found: %w", err)
+	}
+	return syscall.Exec(path, []string{"zellij", "attach", session}, os.Environ())
+}
+
+func (z *Zellij) SplitPane(pane, dir string, horizontal bool, size string) (string, error) {
+	pos, _, err := z.focus(pane)
+	if err != nil {
+		return "", err
+	}
+	direction := "down"
+	if horizontal {
+		direction = "right"
+	}
+	if _, err := z.action(pos.session, "new-pane", "--direction", direction, "--cwd", dir); err != nil {
+		return "", err
+	}
+
+	// The new pane follows the split one in tree order
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	for id, p := range z.ids {
+		if p.session == pos.session && p.tab == pos.tab && p.index > pos.index {
+			p.index++
+			z.ids[id] = p
+		}
+	}
+	return z.idLocked(zellijPos{pos.session, pos.tab, pos.index + 1}), nil
+}
+
+func (z *Zellij) SelectPane(pane string) error {
+	_, _, err := z.focus(pane)
+	return err
+}
+
+func (z *Zellij) TagAgentPane(pane string) error {
+	return z.onPane(pane, func(pos zellijPos) error {
+		_, err := z.action(pos.session, "rename-pane", agentPaneName)
+		return err
+	})
+}
+
+func (z *Zellij) RespawnPane(pane, dir, command string) error {
+	return fmt.Errorf("zellij: restarting a pane isn't supported")
+}
+
+func (z *Zellij) SendKeys(pane, keys string) error {
+	return z.onPane(pane, func(pos zellijPos) error {
+		if _, err := z.action(pos.session, "write-chars", keys); err != nil {
+			return err
+		}
+		_, err := z.action(pos.session, "write", "13")
+		return err
+	})
+}
+
+// Capture reads the pane only if it has the focus in the session's focused
+// tab. dump-screen dumps the focused pane, and moving the focus on every
+// poll would send the user's keystrokes to the wrong pane.
+func (z *Zellij) Capture(p Pane, lines int) (string, error) {
+	z.mu.Lock()
+	pos, ok := z.ids[p.ID]
+	z.mu.Unlock()
+	if !ok {
+		return "", nil // not listed: the pane is gone
+	}
+	tabs, err := z.layout(pos.session)
+	if err != nil {
+		return "", nil
+	}
+	if !focused(tabs, pos) {
+		return "", ErrNotFocused
+	}
+
+	f, err := os.CreateTemp("", "parkranger-dump-*")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if _, err := z.action(pos.session, "dump-screen", f.Name()); err != nil {
+		return "", nil // the pane is gone
+	}
+	screen, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return bottomRows(string(screen), lines), nil
+}
+
+func (z *Zellij) Snapshot() (Snapshot, error) {
+	var snap Snapshot
+	for _, name := range z.sessions() {
+		tabs, err := z.layout(name)
+		if err != nil {
+			continue // exited sessions can't be queried
+		}
+		snap.Sessions = append(snap.Sessions, z.session(name, tabs))
+	}
+	return snap, nil
+}
+
+func (z *Zellij) ListPanes(session, window string) []Pane {
+	tabs, err := z.layout(session)
+	if err != nil {
+		return nil
+	}
+	for _, w := range z.session(session, tabs).Windows {
+		if w.Name == window {
+			return w.Panes
+		}
+	}
+	return nil
+}
+
+// session converts a parsed layout into the common model.
+func (z *Zellij) session(name string, tabs []zellijTab) Session {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	s := Session{ID: name, Name: name}
+	for i, t := range tabs {
+		w := Window{ID: name + "/" + t.name, Index: i, Name: t.name}
+		for j, p := range t.panes {
+			w.Panes = append(w.Panes, Pane{
+				ID:      z.idLocked(zellijPos{name, t.name, j}),
+				Index:   j,
+				Command: p.command,
+				Agent:   p.name == agentPaneName,
+				Title:   p.name,
+			})
+		}
+		s.Windows = append(s.Windows, w)
+	}
+	return s
+}
+
+// idLocked returns the ID of the pane at pos, handing out a new one if
+// there is none.
+func (z *Zellij) idLocked(pos zellijPos) string {
+	for id, p := range z.ids {
+		if p == pos {
+			return id
+		}
+	}
+	z.nextID++
+	id := fmt.Sprintf("z%d", z.nextID)
+	z.ids[id] = pos
+	return id
+}
+
+// layout reads a session's tabs and panes.
+func (z *Zellij) layout(session string) ([]zellijTab, error) {
+	out, err := z.action(session, "dump-layout")
+	if err != nil {
+		return nil, err
+	}
+	return parseZellijLayout(out)
+}
+
+// focus focuses pane and returns where it is and how to move the focus
+// back.
+func (z *Zellij) focus(pane string) (zellijPos, func() error, error) {
+	z.mu.Lock()
+	pos, ok := z.ids[pane]
+	z.mu.Unlock()
+	if !ok {
+		return pos, nil, fmt.Errorf("zellij: no pane %s", pane)
+	}
+	tabs, err := z.layout(pos.session)
+	if err != nil {
+		return pos, nil, err
+	}
+	prev, tab := "", (*zellijTab)(nil)
+	for i := range tabs {
+		if tabs[i].focused {
+			prev = tabs[i].name
+		}
+		if tabs[i].name == pos.tab {
+			tab = &tabs[i]
+		}
+	}
+	if tab == nil || pos.index >= len(tab.panes) {
+		return pos, nil, fmt.Errorf("zellij: no pane %s in %s:%s", pane, pos.session, pos.tab)
+	}
+
+	if prev != pos.tab {
+		if err := z.SelectWindow(pos.session, pos.tab); err != nil {
+			return pos, nil, err
+		}
+	}
+	n, steps := tab.cycle(pos.index)
+	if err := z.focusNext(pos.session, steps); err != nil {
+		return pos, nil, err
+	}
+	restore := func() error {
+		if err := z.focusNext(pos.session, (n-steps)%n); err != nil {
+			return err
+		}
+		if prev != "" && prev != pos.tab {
+			return z.SelectWindow(pos.session, prev)
+		}
+		return nil
+	}
+	return pos, restore, nil
+}
+
+// focused reports whether the pane at pos is the one with the focus.
+func focused(tabs []zellijTab, pos zellijPos) bool {
+	for _, t := range tabs {
+		if t.name == pos.tab {
+			return t.focused && pos.index < len(t.panes) && t.panes[pos.index].focused
+		}
+	}
+	return false
+}
+
+// onPane runs fn with pane focused, then restores the focus.
+func (z *Zellij) onPane(pane string, fn func(zellijPos) error) error {
+	pos, restore, err := z.focus(pane)
+	if err != nil {
+		return err
+	}
+	err = fn(pos)
+	if rerr := restore(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func (z *Zellij) focusNext(session string, n int) error {
+	for range n {
+		if _, err := z.action(session, "focus-next-pane"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zellijTab is a tab of a dumped layout. Its panes are the terminal panes
+// in tree order; plugin and floating panes are left out.
+type zellijTab struct {
+	name    string
+	focused bool
+	panes   []zellijPane
+}
+
+type zellijPane struct {
+	name, command string
+	focused       bool
+	x, y          float64 // top-left corner, as a share of the tab
+}
+
+// cycle returns how many panes focus-next-pane cycles through and how many
+// steps take the focus to panes[index]. Zellij cycles in reading order:
+// top to bottom, then left to right.
+func (t *zellijTab) cycle(index int) (n, steps int) {
+	order := make([]int, len(t.panes))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		pa, pb := t.panes[a], t.panes[b]
+		if pa.y != pb.y {
+			return cmpFloat(pa.y, pb.y)
+		}
+		return cmpFloat(pa.x, pb.x)
+	})
+	from, to := 0, slices.Index(order, index)
+	for i, p := range order {
+		if t.panes[p].focused {
+			from = i
+		}
+	}
+	n = len(order)
+	return n, (to - from + n) % n
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// parseZellijLayout reads the tabs of a `zellij action dump-layout`.
+func parseZellijLayout(out string) ([]zellijTab, error) {
+	root, err := parseKDL(out)
+	if err != nil {
+		return nil, err
+	}
+	var tabs []zellijTab
+	for _, l := range root.children {
+		if l.name != "layout" {
+			continue
+		}
+		for _, n := range l.children {
+			if n.name != "tab" {
+				continue
+			}
+			t := zellijTab{name: n.attrs["name"], focused: n.attrs["focus"] == "true"}
+			t.panes = zellijPanes(n, 0, 0, 1, 1, n.attrs["split_direction"] == "vertical", nil)
+			tabs = append(tabs, t)
+		}
+	}
+	return tabs, nil
+}
+
+// zellijPanes appends the terminal panes under node, which covers the
+// rectangle at x, y of size w, h and lays its children out side by side
+// when vertical, else stacked.
+func zellijPanes(node *kdlNode, x, y, w, h float64, vertical bool, panes []zellijPane) []zellijPane {
+	var kids []*kdlNode
+	for _, c := range node.children {
+		if c.name == "pane" {
+			kids = append(kids, c)
+		}
+	}
+
+	// Percentages take their share, fixed sizes (bars) next to nothing and
+	// the rest split what is left
+	span, left, free := h, 1.0, 0
+	if vertical {
+		span = w
+	}
+	shares := make([]float64, len(kids))
+	for i, k := range kids {
+		switch size := k.attrs["size"]; {
+		case strings.HasSuffix(size, "%"):
+			pct, _ := strconv.ParseFloat(strings.TrimSuffix(size, "%"), 64)
+			shares[i] = pct / 100
+			left -= shares[i]
+		case size != "":
+			shares[i] = 0
+		default:
+			shares[i] = -1
+			free++
+		}
+	}
+	off := 0.0
+	for i, k := range kids {
+		share := shares[i]
+		if share < 0 {
+			share = math.Max(left, 0) / float64(free)
+		}
+		kx, ky, kw, kh := x, y+off*span, w, share*span
+		if vertical {
+			kx, ky, kw, kh = x+off*span, y, share*span, h
+		}
+		off += share
+
+		switch {
+		case k.has("plugin") || k.attrs["plugin"] != "":
+		case k.has("pane"):
+			panes = zellijPanes(k, kx, ky, kw, kh, k.attrs["split_direction"] == "vertical", panes)
+		default:
+			p := zellijPane{name: k.attrs["name"], focused: k.attrs["focus"] == "true", x: round(kx), y: round(ky)}
+			if cmd := k.attrs["command"]; cmd != "" {
+				p.command = filepath.Base(cmd)
+			}
+			panes = append(panes, p)
+		}
+	}
+	return panes
+}
+
+// round drops float noise, so panes on the same row compare equal.
+func round(f float64) float64 {
+	return math.Round(f*1e6) / 1e6
+}
+
+// kdlNode is a node of the KDL subset zellij dumps layouts in: one node per
+// line, with arguments, key=value properties and a "{" opening children.
+type kdlNode struct {
+	name     string
+	args     []string
+	attrs    map[string]string
+	children []*kdlNode
+}
+
+func (n *kdlNode) has(child string) bool {
+	return slices.ContainsFunc(n.children, func(c *kdlNode) bool { return c.name == child })
+}
+
+func parseKDL(doc string) (*kdlNode, error) {
+	root := &kdlNode{}
+	stack := []*kdlNode{root}
+	for i, line := range strings.Split(doc, "\n") {
+		toks, err := kdlTokens(line)
+		if err != nil {
+			return nil, fmt.Errorf("layout line %d: %w", i+1, err)
+		}
+		for len(toks) > 0 && toks[0] == "}" {
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("layout line %d: unbalanced }", i+1)
+			}
+			stack = stack[:len(stack)-1]
+			toks = toks[1:]
+		}
+		if len(toks) == 0 {
+			continue
+		}
+		n := &kdlNode{name: toks[0], attrs: make(map[string]string)}
+		opens := false
+		for _, t := range toks[1:] {
+			if t == "{" {
+				opens = true
+			} else if k, v, ok := strings.Cut(t, "="); ok && !strings.HasPrefix(t, `"`) {
+				n.attrs[k] = kdlValue(v)
+			} else {
+				n.args = append(n.args, kdlValue(t))
+			}
+		}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, n)
+		if opens {
+			stack = append(stack, n)
+		}
+	}
+	return root, nil
+}
+
+// kdlTokens splits a line on spaces outside quoted strings and drops
+// comments.
+func kdlTokens(line string) ([]string, error) {
+	var toks []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		if strings.HasPrefix(line, "//") {
+			break
+		}
+		end, quoted := 0, false
+		for end < len(line) && (quoted || line[end] != ' ' && line[end] != '\t') {
+			switch {
+			case line[end] == '\\' && quoted:
+				end++
+			case line[end] == '"':
+				quoted = !quoted
+			}
+			end++
+		}
+		if quoted {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		end = min(end, len(line))
+		toks = append(toks, line[:end])
+		line = line[end:]
+	}
+	return toks, nil
+}
+
+// kdlValue unquotes a string value.
+func kdlValue(v string) string {
+	if s, err := strconv.Unquote(v); err == nil {
+		return s
+	}
+	return v
+}
@@ -0,0 +1,68 @@
+package mux_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/grins/parkranger/internal/mux"
+	"github.com/grins/parkranger/internal/session"
+)
+
+// detectLayout is a tab with an editor pane and an agent pane; focus marks
+// the focused one.
+func detectLayout(agentFocused bool) string {
+	editor, agent := " focus=true", ""
+	if agentFocused {
+		editor, agent = "", " focus=true"
+	}
+	return `layout {
+    tab name="feat" focus=true {
+        pane split_direction="vertical" {
+            pane cwd="/wt"` + editor + `
+            pane command="/usr/bin/claude" cwd="/wt" name="agent"` + agent + `
+        }
+    }
+}
+`
+}
+
+// TestZellij_Detector polls a zellij session the way the dashboard does and
+// checks the user's focus is never moved.
+func TestZellij_Detector(t *testing.T) {
+	layout := detectLayout(false)
+	var calls []string
+	z := mux.NewZellijRun(func(name string, args ...string) (string, error) {
+		line := strings.Join(append([]string{name}, args...), " ")
+		calls = append(calls, line)
+		switch {
+		case strings.HasSuffix(line, "list-sessions --short --no-formatting"):
+			return "pr-app\n", nil
+		case strings.HasSuffix(line, "action dump-layout"):
+			return layout, nil
+		case len(args) == 5 && args[3] == "dump-screen":
+			return "", os.WriteFile(args[4], []byte("Claude Code\n\n❯ \n? for shortcuts\n"), 0o644)
+		}
+		return "", nil
+	})
+	d := session.Detector{Mux: z, Debounce: session.Debounce{Hysteresis: 1}}
+
+	// The editor has the focus: the agent can't be read without moving it
+	for range 3 {
+		live := d.Detect("pr-app", "feat")
+		if !live.Exists || live.PaneID == "" || live.Status != session.StatusUnknown || !live.Unreadable {
+			t.Errorf("unfocused agent: got %+v, want unknown and unreadable", live)
+		}
+	}
+
+	layout = detectLayout(true)
+	if live := d.Detect("pr-app", "feat"); live.Status != session.StatusIdle || live.Unreadable {
+		t.Errorf("focused agent: got %+v, want idle", live)
+	}
+
+	for _, call := range calls {
+		if strings.Contains(call, "focus") || strings.Contains(call, "go-to-tab") {
+			t.Errorf("polling moved the focus: %s", call)
+		}
+	}
+}
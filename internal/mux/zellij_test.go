@@ -0,0 +1,164 @@
+package mux
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// script is a fake CLI: it answers commands from a table and records them.
+type script struct {
+	answers map[string]string // command line → output
+	calls   []string
+}
+
+func (s *script) run(name string, args ...string) (string, error) {
+	line := strings.Join(append([]string{name}, args...), " ")
+	s.calls = append(s.calls, line)
+	return s.answers[line], nil
+}
+
+const zellijLayout = `layout {
+    cwd "/repo"
+    tab name="dashboard" hide_floating_panes=true {
+        pane size=1 borderless=true {
+            plugin location="zellij:tab-bar"
+        }
+        pane command="parkranger" cwd="/repo"
+        pane size=2 borderless=true {
+            plugin location="zellij:status-bar"
+        }
+    }
+    tab name="feat" focus=true {
+        pane size=1 borderless=true {
+            plugin location="zellij:tab-bar"
+        }
+        pane split_direction="vertical" {
+            pane split_direction="horizontal" size="60%" {
+                pane cwd="/wt" focus=true
+                pane cwd="/wt" name="shell \"2\""
+            }
+            pane command="/usr/bin/claude" cwd="/wt" name="agent" size="40%" {
+                args "--resume"
+                start_suspended false
+            }
+        }
+        floating_panes {
+            pane cwd="/tmp"
+        }
+    }
+    new_tab_template {
+        pane
+    }
+}
+`
+
+func TestParseZellijLayout(t *testing.T) {
+	tabs, err := parseZellijLayout(zellijLayout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tabs) != 2 || tabs[0].name != "dashboard" || tabs[0].focused || !tabs[1].focused {
+		t.Fatalf("tabs = %+v", tabs)
+	}
+	if got := tabs[0].panes; len(got) != 1 || got[0].command != "parkranger" {
+		t.Errorf("dashboard panes = %+v", got)
+	}
+	want := []zellijPane{
+		{focused: true},
+		{name: `shell "2"`, y: 0.5},
+		{name: "agent", command: "claude", x: 0.6},
+	}
+	if got := tabs[1].panes; !reflect.DeepEqual(got, want) {
+		t.Errorf("feat panes = %+v, want %+v", got, want)
+	}
+}
+
+func TestZellijTab_Cycle(t *testing.T) {
+	tabs, _ := parseZellijLayout(zellijLayout)
+	// Reading order is top-left, agent (top-right), then bottom-left
+	for index, want := range []int{0, 2, 1} {
+		if n, steps := tabs[1].cycle(index); n != 3 || steps != want {
+			t.Errorf("cycle(%d) = %d, %d, want 3, %d", index, n, steps, want)
+		}
+	}
+}
+
+func TestZellij_Panes(t *testing.T) {
+	s := &script{answers: map[string]string{
+		"zellij --session pr-app action dump-layout": zellijLayout,
+	}}
+	z := NewZellij()
+	z.run = s.run
+
+	panes := z.ListPanes("pr-app", "feat")
+	if len(panes) != 3 || !panes[2].Agent || panes[2].Command != "claude" {
+		t.Fatalf("panes = %+v", panes)
+	}
+	if again := z.ListPanes("pr-app", "feat"); again[2].ID != panes[2].ID {
+		t.Errorf("listing again changed the agent's ID: %s → %s", panes[2].ID, again[2].ID)
+	}
+
+	// Typing into the agent focuses it, one step on, and cycles back
+	s.calls = nil
+	if err := z.SendKeys(panes[2].ID, "claude"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"zellij --session pr-app action dump-layout",
+		"zellij --session pr-app action focus-next-pane",
+		"zellij --session pr-app action write-chars claude",
+		"zellij --session pr-app action write 13",
+		"zellij --session pr-app action focus-next-pane",
+		"zellij --session pr-app action focus-next-pane",
+	}
+	if !reflect.DeepEqual(s.calls, want) {
+		t.Errorf("calls = %q, want %q", s.calls, want)
+	}
+
+	// Splitting the first pane keeps the later IDs on their panes
+	id, err := z.SplitPane(panes[0].ID, "/wt", true, "30%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos := z.ids[id]; pos.index != 1 {
+		t.Errorf("new pane at %d, want 1", pos.index)
+	}
+	if pos := z.ids[panes[2].ID]; pos.index != 3 {
+		t.Errorf("agent pane at %d after the split, want 3", pos.index)
+	}
+}
+
+func TestZellij_Capture(t *testing.T) {
+	s := &script{answers: map[string]string{
+		"zellij --session pr-app action dump-layout": zellijLayout,
+	}}
+	z := NewZellij()
+	z.run = func(name string, args ...string) (string, error) {
+		if len(args) == 5 && args[3] == "dump-screen" {
+			os.WriteFile(args[4], []byte("one\ntwo\nthree\n"), 0o644)
+		}
+		return s.run(name, args...)
+	}
+	panes := z.ListPanes("pr-app", "feat")
+	s.calls = nil
+	got, err := z.Capture(panes[0], 2)
+	if err != nil || got != "two\nthree" {
+		t.Errorf("Capture = %q, %v", got, err)
+	}
+	if got, _ := z.Capture(Pane{ID: "z99"}, 2); got != "" {
+		t.Errorf("Capture of an unknown pane = %q", got)
+	}
+
+	// Other panes aren't read: that would move the focus under the user
+	if _, err := z.Capture(panes[2], 2); !errors.Is(err, ErrNotFocused) {
+		t.Errorf("Capture of an unfocused pane: err = %v", err)
+	}
+	for _, call := range s.calls {
+		if strings.Contains(call, "focus") || strings.Contains(call, "go-to-tab") {
+			t.Errorf("Capture moved the focus: %s", call)
+		}
+	}
+}
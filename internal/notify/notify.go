@@ -0,0 +1,161 @@
+// Package notify alerts the user when an agent stops working: busy → waiting
+// (it needs input) or busy → idle (it finished). Notifications fan out to
+// pluggable sinks and are rate-limited per worktree.
+package notify
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/grins/parkranger/internal/config"
+	"github.com/grins/parkranger/internal/engine"
+	"github.com/grins/parkranger/internal/session"
+)
+
+// Notification describes one agent status transition worth alerting on.
+type Notification struct {
+	Repo     string
+	Worktree string
+	Agent    string // profile name; "" is Claude
+	From     session.AgentStatus
+	To       session.AgentStatus
+	Time     time.Time
+}
+
+// Title returns a short heading naming the worktree.
+func (n Notification) Title() string {
+	return fmt.Sprintf("parkranger: %s/%s", n.Repo, n.Worktree)
+}
+
+// Body returns a one-line description of what happened.
+func (n Notification) Body() string {
+	agent := n.Agent
+	if agent == "" || agent == config.ClaudeAgent {
+		agent = "Claude"
+	}
+	switch n.To {
+	case session.StatusWaiting:
+		return agent + " needs your input"
+	case session.StatusIdle:
+		return agent + " finished"
+	case session.StatusError:
+		return agent + " hit an error"
+	case session.StatusExited:
+		return agent + " exited"
+	default:
+		return agent + " is " + n.To.String()
+	}
+}
+
+// Sink delivers a notification somewhere.
+type Sink interface {
+	Notify(n Notification) error
+}
+
+// Notifier turns engine events into notifications.
+type Notifier struct {
+	config func(repo string) config.Notify
+	out    io.Writer // terminal for bell/OSC sinks
+
+	// OnError is told about a sink that fails, once until it works again.
+	// New reports to stderr.
+	OnError func(sink string, err error)
+
+	mu     sync.Mutex
+	last   map[string]time.Time // per-worktree last notification
+	failed map[string]bool      // sinks whose last notification failed
+
+	// Swappable for tests.
+	sink func(name string, cfg config.Notify, out io.Writer) (Sink, error)
+}
+
+// New creates a notifier. cfg returns the notify settings for a repo, so
+// repos can opt out or pick different sinks. Terminal sinks write to stderr.
+func New(cfg func(repo string) config.Notify) *Notifier {
+	return &Notifier{
+		config: cfg,
+		out:    os.Stderr,
+		last:   make(map[string]time.Time),
+		failed: make(map[string]bool),
+		sink:   NewSink,
+		OnError: func(sink string, err error) {
+			fmt.Fprintf(os.Stderr, "warning: notify sink %s: %v\n", sink, err)
+		},
+	}
+}
+
+// Run handles events until the channel is closed.
+func (n *Notifier) Run(events <-chan engine.Event) {
+	for ev := range events {
+		n.Handle(ev)
+	}
+}
+
+// Handle notifies for ev if it is a busy → waiting/idle transition that the
+// repo's config asks for and that isn't rate-limited. Sink errors go to
+// OnError and never disturb detection.
+func (n *Notifier) Handle(ev engine.Event) {
+	if ev.Kind != engine.EventStatusChanged {
+		return
+	}
+	from, to := ev.Prev.Live.Status, ev.State.Live.Status
+	if from != session.StatusBusy {
+		return
+	}
+
+	cfg := n.config(ev.State.Repo)
+	if !cfg.Enabled || !slices.Contains(cfg.On, to.String()) {
+		return
+	}
+	if !n.allow(ev.Key, ev.Time, cfg.Cooldown.Duration) {
+		return
+	}
+
+	note := Notification{
+		Repo:     ev.State.Repo,
+		Worktree: ev.State.Worktree,
+		Agent:    ev.State.Live.Agent,
+		From:     from,
+		To:       to,
+		Time:     ev.Time,
+	}
+	for _, name := range cfg.Sinks {
+		s, err := n.sink(name, cfg, n.out)
+		if err == nil {
+			err = s.Notify(note)
+		}
+		n.report(name, err)
+	}
+}
+
+// report passes a sink's first failure to OnError; a success rearms it.
+func (n *Notifier) report(sink string, err error) {
+	n.mu.Lock()
+	first := err != nil && !n.failed[sink]
+	if err != nil {
+		n.failed[sink] = true
+	} else {
+		delete(n.failed, sink)
+	}
+	n.mu.Unlock()
+	if first && n.OnError != nil {
+		n.OnError(sink, err)
+	}
+}
+
+// allow applies the per-worktree cooldown, recording the notification if it
+// may go out. Other worktrees are never held back.
+func (n *Notifier) allow(key string, at time.Time, cooldown time.Duration) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if last, ok := n.last[key]; ok && at.Sub(last) < cooldown {
+		return false
+	}
+	n.last[key] = at
+	return true
+}
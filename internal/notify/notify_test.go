@@ -0,0 +1,201 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grins/parkranger/internal/config"
+	"github.com/grins/parkranger/internal/engine"
+	"github.com/grins/parkranger/internal/session"
+)
+
+// recorder is a sink that remembers what it was asked to deliver.
+type recorder struct {
+	got []Notification
+}
+
+func (r *recorder) Notify(n Notification) error {
+	r.got = append(r.got, n)
+	return nil
+}
+
+func testNotifier(cfg config.Notify) (*Notifier, *recorder) {
+	rec := &recorder{}
+	n := New(func(string) config.Notify { return cfg })
+	n.sink = func(string, config.Notify, io.Writer) (Sink, error) { return rec, nil }
+	return n, rec
+}
+
+func transition(wt string, from, to session.AgentStatus, at time.Time) engine.Event {
+	t := engine.Target{Repo: "repo", Worktree: wt}
+	return engine.Event{
+		Kind:  engine.EventStatusChanged,
+		Time:  at,
+		Key:   t.Key(),
+		Prev:  engine.State{Target: t, Live: session.LiveInfo{Exists: true, HasClaude: true, Status: from}},
+		State: engine.State{Target: t, Live: session.LiveInfo{Exists: true, HasClaude: true, Status: to}},
+	}
+}
+
+func TestHandle_Transitions(t *testing.T) {
+	n, rec := testNotifier(config.Default().Notify)
+	t0 := time.Unix(1000, 0)
+
+	n.Handle(transition("a", session.StatusIdle, session.StatusBusy, t0))
+	n.Handle(transition("b", session.StatusWaiting, session.StatusIdle, t0.Add(10*time.Second)))
+	if len(rec.got) != 0 {
+		t.Fatalf("non-busy transitions notified: %+v", rec.got)
+	}
+
+	n.Handle(transition("a", session.StatusBusy, session.StatusWaiting, t0.Add(20*time.Second)))
+	if len(rec.got) != 1 || rec.got[0].To != session.StatusWaiting || rec.got[0].Worktree != "a" {
+		t.Fatalf("got %+v", rec.got)
+	}
+	if rec.got[0].Body() != "Claude needs your input" {
+		t.Errorf("body = %q", rec.got[0].Body())
+	}
+
+	// Other agents are named in the body
+	ev := transition("c", session.StatusBusy, session.StatusIdle, t0.Add(30*time.Second))
+	ev.State.Live.Agent = "aider"
+	n.Handle(ev)
+	if len(rec.got) != 2 || rec.got[1].Body() != "aider finished" {
+		t.Errorf("got %+v", rec.got)
+	}
+}
+
+func TestHandle_RateLimited(t *testing.T) {
+	cfg := config.Default().Notify
+	cfg.Cooldown = config.Duration{Duration: time.Minute}
+	n, rec := testNotifier(cfg)
+	t0 := time.Unix(1000, 0)
+
+	n.Handle(transition("a", session.StatusBusy, session.StatusIdle, t0))
+	n.Handle(transition("a", session.StatusBusy, session.StatusWaiting, t0.Add(30*time.Second)))
+	if len(rec.got) != 1 {
+		t.Fatalf("cooldown not applied: %d notifications", len(rec.got))
+	}
+
+	// Another worktree has its own cooldown, even a second later
+	n.Handle(transition("b", session.StatusBusy, session.StatusIdle, t0.Add(time.Second)))
+	if len(rec.got) != 2 || rec.got[1].Worktree != "b" {
+		t.Fatalf("other worktree held back: %+v", rec.got)
+	}
+
+	n.Handle(transition("b", session.StatusBusy, session.StatusIdle, t0.Add(40*time.Second)))
+	n.Handle(transition("a", session.StatusBusy, session.StatusWaiting, t0.Add(2*time.Minute)))
+	if len(rec.got) != 3 {
+		t.Errorf("got %d notifications, want 3", len(rec.got))
+	}
+}
+
+func TestHandle_Config(t *testing.T) {
+	cfg := config.Default().Notify
+	cfg.On = []string{"waiting"}
+	n, rec := testNotifier(cfg)
+	n.Handle(transition("a", session.StatusBusy, session.StatusIdle, time.Unix(1000, 0)))
+	if len(rec.got) != 0 {
+		t.Error("idle notified although only waiting is enabled")
+	}
+
+	cfg.Enabled = false
+	n, rec = testNotifier(cfg)
+	n.Handle(transition("a", session.StatusBusy, session.StatusWaiting, time.Unix(1000, 0)))
+	if len(rec.got) != 0 {
+		t.Error("notified although disabled")
+	}
+}
+
+// failing is a sink that fails while err is set.
+type failing struct {
+	err error
+}
+
+func (f *failing) Notify(Notification) error { return f.err }
+
+func TestHandle_SinkErrors(t *testing.T) {
+	sink := &failing{err: errors.New("no server running")}
+	n := New(func(string) config.Notify { return config.Default().Notify })
+	n.sink = func(string, config.Notify, io.Writer) (Sink, error) { return sink, nil }
+	var reported []string
+	n.OnError = func(name string, err error) { reported = append(reported, name+": "+err.Error()) }
+	t0 := time.Unix(1000, 0)
+
+	// Reported once, however often it fails
+	for i := range 3 {
+		n.Handle(transition(string(rune('a'+i)), session.StatusBusy, session.StatusIdle, t0))
+	}
+	if len(reported) != 1 || reported[0] != "tmux: no server running" {
+		t.Fatalf("reported = %q, want the first failure", reported)
+	}
+
+	// ...until it works again
+	sink.err = nil
+	n.Handle(transition("d", session.StatusBusy, session.StatusIdle, t0))
+	sink.err = errors.New("still broken")
+	n.Handle(transition("e", session.StatusBusy, session.StatusIdle, t0))
+	if len(reported) != 2 {
+		t.Errorf("reported = %q, want the failure after a success too", reported)
+	}
+}
+
+func TestOSC(t *testing.T) {
+	t.Setenv("TMUX", "")
+	note := Notification{Repo: "repo", Worktree: "a;b", To: session.StatusIdle}
+
+	var buf bytes.Buffer
+	if err := (OSC{W: &buf, Code: 777}).Notify(note); err != nil {
+		t.Fatal(err)
+	}
+	want := "\x1b]777;notify;parkranger: repo/a b;Claude finished\x07"
+	if buf.String() != want {
+		t.Errorf("osc777 = %q, want %q", buf.String(), want)
+	}
+
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,1,0")
+	buf.Reset()
+	(OSC{W: &buf, Code: 9}).Notify(note)
+	if !strings.HasPrefix(buf.String(), "\x1bPtmux;\x1b\x1b]9;") || !strings.HasSuffix(buf.String(), "\x1b\\") {
+		t.Errorf("tmux passthrough = %q", buf.String())
+	}
+}
+
+func TestCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	c := Command{Shell: `printf '%s|%s' "$PARKRANGER_WORKTREE" "$PARKRANGER_STATUS" > ` + out}
+	if err := c.Notify(Notification{Repo: "repo", Worktree: "a", To: session.StatusWaiting}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "a|waiting" {
+		t.Errorf("command saw %q", data)
+	}
+}
+
+func TestNewSink_Unknown(t *testing.T) {
+	if _, err := NewSink("pager", config.Notify{}, io.Discard); err == nil {
+		t.Error("expected error for unknown sink")
+	}
+	if _, err := NewSink("command", config.Notify{}, io.Discard); err == nil {
+		t.Error("expected error for command sink without a command")
+	}
+}
+
+// Config validation accepts exactly the sinks NewSink can build.
+func TestNewSink_ConfigNames(t *testing.T) {
+	cfg := config.Default().Notify
+	for _, name := range config.SinkNames {
+		if _, err := NewSink(name, cfg, io.Discard); err != nil {
+			t.Errorf("NewSink(%q): %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/grins/parkranger/internal/config"
+	"github.com/grins/parkranger/internal/tmux"
+)
+
+// commandTimeout bounds how long a notification command may run.
+const commandTimeout = 10 * time.Second
+
+// NewSink returns the sink registered under name. Terminal sinks write to out.
+func NewSink(name string, cfg config.Notify, out io.Writer) (Sink, error) {
+	switch name {
+	case "bell":
+		return Bell{W: out}, nil
+	case "osc9":
+		return OSC{W: out, Code: 9}, nil
+	case "osc777":
+		return OSC{W: out, Code: 777}, nil
+	case "command":
+		if cfg.Command == "" {
+			return nil, fmt.Errorf("notify sink \"command\" needs notify.command")
+		}
+		return Command{Shell: cfg.Command}, nil
+	case "tmux":
+		return TmuxMessage{}, nil
+	default:
+		return nil, fmt.Errorf("unknown notify sink %q", name)
+	}
+}
+
+// Bell rings the terminal bell.
+type Bell struct {
+	W io.Writer
+}
+
+func (b Bell) Notify(Notification) error {
+	_, err := io.WriteString(b.W, "\a")
+	return err
+}
+
+// OSC emits a desktop notification escape sequence understood by most modern
+// terminals: OSC 9 (iTerm2, WezTerm, kitty) or OSC 777 (rxvt, foot, Ghostty).
+// Inside tmux the sequence is wrapped for passthrough (needs
+// `set -g allow-passthrough on`).
+type OSC struct {
+	W    io.Writer
+	Code int
+}
+
+func (o OSC) Notify(n Notification) error {
+	var seq string
+	switch o.Code {
+	case 777:
+		seq = fmt.Sprintf("\x1b]777;notify;%s;%s\x07", oscSafe(n.Title()), oscSafe(n.Body()))
+	default:
+		seq = fmt.Sprintf("\x1b]9;%s: %s\x07", oscSafe(n.Title()), oscSafe(n.Body()))
+	}
+	if tmux.IsInsideTmux() {
+		seq = tmuxPassthrough(seq)
+	}
+	_, err := io.WriteString(o.W, seq)
+	return err
+}
+
+// oscSafe strips characters that would terminate or split an OSC payload.
+func oscSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == ';' {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// tmuxPassthrough wraps an escape sequence in tmux's DCS passthrough,
+// doubling every ESC inside it.
+func tmuxPassthrough(seq string) string {
+	return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+}
+
+// Command runs a shell command, e.g. notify-send or terminal-notifier.
+// The notification is passed in PARKRANGER_* environment variables.
+type Command struct {
+	Shell string
+}
+
+func (c Command) Notify(n Notification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Shell)
+	cmd.Env = append(os.Environ(),
+		"PARKRANGER_TITLE="+n.Title(),
+		"PARKRANGER_BODY="+n.Body(),
+		"PARKRANGER_REPO="+n.Repo,
+		"PARKRANGER_WORKTREE="+n.Worktree,
+		"PARKRANGER_STATUS="+n.To.String(),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notify command: %w\n%s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// TmuxMessage shows the notification in the tmux status line.
+type TmuxMessage struct{}
+
+func (TmuxMessage) Notify(n Notification) error {
+	// display-message expands #{...} formats — escape literal #
+	msg := strings.ReplaceAll(n.Title()+" — "+n.Body(), "#", "##")
+	return tmux.DisplayMessage(msg, 5000)
+}
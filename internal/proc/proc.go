@@ -0,0 +1,159 @@
+// Package proc reads the system process table, to find which processes run
+// under a tmux pane's shell. Linux reads /proc directly; elsewhere it falls
+// back to ps.
+package proc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Process is one entry in the process table.
+type Process struct {
+	PID  int
+	PPID int
+	Comm string   // executable name (possibly truncated by the kernel)
+	Args []string // command line; empty for kernel threads and zombies
+}
+
+// Name reports whether p is the program name: its comm, or the base name of
+// argv[0] (agents that set their process title, like node-based CLIs, show
+// up either way).
+func (p Process) Name(name string) bool {
+	if p.Comm == name {
+		return true
+	}
+	return len(p.Args) > 0 && filepath.Base(p.Args[0]) == name
+}
+
+// Table is a process table snapshot, keyed by PID.
+type Table map[int]Process
+
+// Find returns the first process in the tree rooted at pid (pid included,
+// breadth first) for which match returns true.
+func (t Table) Find(pid int, match func(Process) bool) (Process, bool) {
+	children := make(map[int][]int, len(t))
+	for _, p := range t {
+		children[p.PPID] = append(children[p.PPID], p.PID)
+	}
+
+	queue := []int{pid}
+	seen := make(map[int]bool)
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if seen[cur] {
+			continue
+		}
+		seen[cur] = true
+		if p, ok := t[cur]; ok && match(p) {
+			return p, true
+		}
+		queue = append(queue, children[cur]...)
+	}
+	return Process{}, false
+}
+
+// Snapshot reads the current process table.
+func Snapshot() (Table, error) {
+	if t, err := readProc("/proc"); err == nil && len(t) > 0 {
+		return t, nil
+	}
+	return readPS()
+}
+
+// readProc builds the table from a procfs mount.
+func readProc(root string) (Table, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	t := make(Table, len(entries))
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		// Processes exit between ReadDir and here; skip them
+		stat, err := os.ReadFile(filepath.Join(root, e.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		p, err := parseStat(string(stat))
+		if err != nil {
+			continue
+		}
+		if cmdline, err := os.ReadFile(filepath.Join(root, e.Name(), "cmdline")); err == nil {
+			p.Args = parseCmdline(cmdline)
+		}
+		p.PID = pid
+		t[pid] = p
+	}
+	return t, nil
+}
+
+// parseStat parses /proc/<pid>/stat: "pid (comm) state ppid ...". comm may
+// contain spaces and parentheses, so it runs to the last ')'.
+func parseStat(stat string) (Process, error) {
+	open := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return Process{}, fmt.Errorf("malformed stat: %q", stat)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(stat[:open]))
+	if err != nil {
+		return Process{}, fmt.Errorf("malformed stat pid: %w", err)
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 2 {
+		return Process{}, fmt.Errorf("malformed stat: %q", stat)
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return Process{}, fmt.Errorf("malformed stat ppid: %w", err)
+	}
+	return Process{PID: pid, PPID: ppid, Comm: stat[open+1 : end]}, nil
+}
+
+// parseCmdline splits a NUL-separated /proc/<pid>/cmdline.
+func parseCmdline(data []byte) []string {
+	data = bytes.TrimRight(data, "\x00")
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(string(data), "\x00")
+}
+
+// readPS builds the table from ps, for systems without /proc (macOS).
+func readPS() (Table, error) {
+	out, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "ppid=", "-o", "args=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("ps: %w", err)
+	}
+	return parsePS(string(out)), nil
+}
+
+// parsePS parses "pid ppid args..." lines. ps doesn't quote args, so they're
+// split on whitespace — good enough to identify argv[0].
+func parsePS(out string) Table {
+	t := make(Table)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		args := fields[2:]
+		t[pid] = Process{PID: pid, PPID: ppid, Comm: filepath.Base(args[0]), Args: args}
+	}
+	return t
+}
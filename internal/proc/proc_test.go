@@ -0,0 +1,108 @@
+package proc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseStat(t *testing.T) {
+	tests := []struct {
+		stat    string
+		want    Process
+		wantErr bool
+	}{
+		{"42 (zsh) S 1 42 42 34816", Process{PID: 42, PPID: 1, Comm: "zsh"}, false},
+		{"7 (tmux: server) S 1 7 7 0", Process{PID: 7, PPID: 1, Comm: "tmux: server"}, false},
+		{"9 (a) b)) R 3 9", Process{PID: 9, PPID: 3, Comm: "a) b)"}, false},
+		{"garbage", Process{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseStat(tt.stat)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseStat(%q) err = %v", tt.stat, err)
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseStat(%q) = %+v, want %+v", tt.stat, got, tt.want)
+		}
+	}
+}
+
+func TestParsePS(t *testing.T) {
+	got := parsePS(`    1     0 /sbin/launchd
+  100     1 -zsh
+  200   100 /usr/local/bin/claude --resume abc
+bad line
+`)
+	if len(got) != 3 {
+		t.Fatalf("got %d processes, want 3", len(got))
+	}
+	if p := got[200]; p.PPID != 100 || p.Comm != "claude" || !reflect.DeepEqual(p.Args, []string{"/usr/local/bin/claude", "--resume", "abc"}) {
+		t.Errorf("pid 200 = %+v", p)
+	}
+}
+
+func TestReadProc(t *testing.T) {
+	root := t.TempDir()
+	write := func(pid, stat, cmdline string) {
+		dir := filepath.Join(root, pid)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644)
+		os.WriteFile(filepath.Join(dir, "cmdline"), []byte(cmdline), 0o644)
+	}
+	write("10", "10 (bash) S 1 10", "-bash\x00")
+	write("11", "11 (claude) S 10 11", "claude\x00--resume\x00abc\x00")
+	os.MkdirAll(filepath.Join(root, "self"), 0o755) // non-numeric entries are skipped
+
+	got, err := readProc(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Table{
+		10: {PID: 10, PPID: 1, Comm: "bash", Args: []string{"-bash"}},
+		11: {PID: 11, PPID: 10, Comm: "claude", Args: []string{"claude", "--resume", "abc"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readProc = %+v, want %+v", got, want)
+	}
+}
+
+func TestFind(t *testing.T) {
+	table := Table{
+		1:  {PID: 1, PPID: 0, Comm: "init"},
+		10: {PID: 10, PPID: 1, Comm: "zsh"},
+		11: {PID: 11, PPID: 10, Comm: "node", Args: []string{"npm", "run", "dev"}},
+		12: {PID: 12, PPID: 10, Comm: "MainThread", Args: []string{"claude"}},
+		20: {PID: 20, PPID: 1, Comm: "zsh"},
+		21: {PID: 21, PPID: 20, Comm: "claude"},
+	}
+	isClaude := func(p Process) bool { return p.Name("claude") }
+
+	if p, ok := table.Find(10, isClaude); !ok || p.PID != 12 {
+		t.Errorf("Find(10) = %+v, %v; want pid 12 (matched by argv[0])", p, ok)
+	}
+	if p, ok := table.Find(21, isClaude); !ok || p.PID != 21 {
+		t.Errorf("Find(21) = %+v, %v; want the root itself", p, ok)
+	}
+	if _, ok := table.Find(11, isClaude); ok {
+		t.Error("Find(11) should not look at siblings")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	table, err := Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	self, ok := table[os.Getpid()]
+	if !ok {
+		t.Fatal("snapshot doesn't include this process")
+	}
+	if self.PPID != os.Getppid() {
+		t.Errorf("PPID = %d, want %d", self.PPID, os.Getppid())
+	}
+}
@@ -0,0 +1,127 @@
+// Package registry persists the set of repos shown on the multi-repo dashboard.
+// The registry is a small JSON file under the parkranger config directory.
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/grins/parkranger/internal/git"
+	"github.com/grins/parkranger/internal/tmux"
+	"github.com/grins/parkranger/internal/xdg"
+)
+
+// Repo is a registered repository. Path is always the main repo root, never a
+// linked worktree, so each repo maps to exactly one pr-<name> tmux session.
+type Repo struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// Registry is the on-disk list of repos.
+type Registry struct {
+	Repos []Repo `json:"repos"`
+
+	path string
+}
+
+// DefaultPath returns the registry file location: <config dir>/repos.json.
+func DefaultPath() string {
+	return filepath.Join(xdg.ConfigDir(), "repos.json")
+}
+
+// Load reads the registry at path. A missing file yields an empty registry.
+func Load(path string) (*Registry, error) {
+	r := &Registry{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Save writes the registry back to the path it was loaded from.
+// The file is written to a temp file and renamed so a crash never truncates it.
+func (r *Registry) Save() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// Add registers the repo containing path. Worktree paths are resolved to their
+// main repo. Returns the stored entry; adding an already-registered repo is a no-op.
+func (r *Registry) Add(path string) (Repo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Repo{}, err
+	}
+
+	root, err := git.MainRepoRoot(abs)
+	if err != nil {
+		return Repo{}, fmt.Errorf("%s is not a git repo", path)
+	}
+
+	repo := Repo{Name: git.RepoName(root), Path: root}
+
+	for _, existing := range r.Repos {
+		if existing.Path == repo.Path {
+			return existing, nil
+		}
+		// Names double as tmux session names (pr-<name>), so they must be
+		// unique once sanitized: "my.app" and "my-app" share pr-my-app.
+		if tmux.SessionName(existing.Name) == tmux.SessionName(repo.Name) {
+			return Repo{}, fmt.Errorf("a repo named %q is already registered at %s", existing.Name, existing.Path)
+		}
+	}
+
+	r.Repos = append(r.Repos, repo)
+	sort.Slice(r.Repos, func(i, j int) bool {
+		return r.Repos[i].Name < r.Repos[j].Name
+	})
+	return repo, nil
+}
+
+// Remove unregisters the repo matching nameOrPath (by name or main root path).
+func (r *Registry) Remove(nameOrPath string) (Repo, error) {
+	abs, _ := filepath.Abs(nameOrPath)
+
+	for i, repo := range r.Repos {
+		if repo.Name == nameOrPath || repo.Path == abs {
+			r.Repos = append(r.Repos[:i], r.Repos[i+1:]...)
+			return repo, nil
+		}
+	}
+	return Repo{}, fmt.Errorf("repo %q is not registered", nameOrPath)
+}
+
+// Find returns the repo with the given name, or nil.
+func (r *Registry) Find(name string) *Repo {
+	for i := range r.Repos {
+		if r.Repos[i].Name == name {
+			return &r.Repos[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package registry
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func initTestRepo(t *testing.T, dir string) string {
+	t.Helper()
+
+	cmds := [][]string{
+		{"git", "init", "-b", "main"},
+		{"git", "config", "user.email", "test@test.com"},
+		{"git", "config", "user.name", "Test"},
+		{"git", "commit", "--allow-empty", "-m", "init"},
+	}
+	for _, args := range cmds {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v: %s\n%s", args, err, out)
+		}
+	}
+
+	// Resolve symlinks (macOS /var → /private/var) so paths compare equal.
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolved
+}
+
+func TestLoadMissing(t *testing.T) {
+	r, err := Load(filepath.Join(t.TempDir(), "repos.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Repos) != 0 {
+		t.Errorf("expected empty registry, got %v", r.Repos)
+	}
+}
+
+func TestAddSaveLoad(t *testing.T) {
+	repo := initTestRepo(t, t.TempDir())
+	path := filepath.Join(t.TempDir(), "sub", "repos.json")
+
+	r, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, err := r.Add(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Path != repo || added.Name != filepath.Base(repo) {
+		t.Errorf("added = %+v", added)
+	}
+
+	// Adding twice is a no-op
+	if _, err := r.Add(repo); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Repos) != 1 {
+		t.Fatalf("expected 1 repo, got %d", len(r.Repos))
+	}
+
+	if err := r.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Repos) != 1 || loaded.Repos[0] != added {
+		t.Errorf("loaded = %+v", loaded.Repos)
+	}
+	if loaded.Find(added.Name) == nil {
+		t.Errorf("Find(%q) = nil", added.Name)
+	}
+}
+
+func TestAddDuplicateName(t *testing.T) {
+	a := initTestRepo(t, filepath.Join(t.TempDir()))
+	bParent := t.TempDir()
+	b := filepath.Join(bParent, filepath.Base(a))
+	if err := exec.Command("mkdir", b).Run(); err != nil {
+		t.Fatal(err)
+	}
+	b = initTestRepo(t, b)
+
+	r := &Registry{}
+	if _, err := r.Add(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Add(b); err == nil {
+		t.Error("expected error for duplicate repo name")
+	}
+}
+
+func TestAddSameSessionName(t *testing.T) {
+	var repos []string
+	for _, name := range []string{"my.app", "my-app"} {
+		dir := filepath.Join(t.TempDir(), name)
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		repos = append(repos, initTestRepo(t, dir))
+	}
+	a, b := repos[0], repos[1]
+
+	r := &Registry{}
+	if _, err := r.Add(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Add(b); err == nil {
+		t.Error("expected error for a name with the same tmux session")
+	}
+}
+
+func TestAddNotRepo(t *testing.T) {
+	r := &Registry{}
+	if _, err := r.Add(t.TempDir()); err == nil {
+		t.Error("expected error for non-repo path")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	r := &Registry{Repos: []Repo{
+		{Name: "a", Path: "/src/a"},
+		{Name: "b", Path: "/src/b"},
+	}}
+
+	if _, err := r.Remove("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Remove("/src/b"); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Repos) != 0 {
+		t.Errorf("expected empty, got %v", r.Repos)
+	}
+	if _, err := r.Remove("a"); err == nil {
+		t.Error("expected error removing unregistered repo")
+	}
+}
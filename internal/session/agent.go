@@ -0,0 +1,175 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/grins/parkranger/internal/config"
+)
+
+// AgentProfile describes one kind of coding agent: how to start and resume
+// it, which process marks its panes, how to read its screen and where its
+// past sessions live.
+type AgentProfile interface {
+	Name() string
+	// Process is the program name that marks a pane as running the agent.
+	Process() string
+	// LaunchCommand starts a fresh session.
+	LaunchCommand() string
+	// ResumeCommand resumes session id, or returns "" if the agent can't.
+	ResumeCommand(id string) string
+	// Classify reads a pane capture; hasAgent reports whether the agent's
+	// UI is on screen.
+	Classify(sc Screen) (status AgentStatus, hasAgent bool)
+	// Sessions lists past sessions run in the worktree, newest first.
+	Sessions(worktreePath string) ([]Session, error)
+}
+
+// Claude is the built-in Claude Code profile.
+type Claude struct {
+	Command string   // launch command; "claude" if empty
+	Rules   *RuleSet // screen rules; the built-in ones if nil
+}
+
+func (Claude) Name() string { return config.ClaudeAgent }
+
+func (c Claude) Process() string { return config.CommandProgram(c.LaunchCommand()) }
+
+func (c Claude) LaunchCommand() string {
+	if c.Command != "" {
+		return c.Command
+	}
+	return "claude"
+}
+
+func (c Claude) ResumeCommand(id string) string {
+	return fmt.Sprintf("%s --resume %s", c.LaunchCommand(), id)
+}
+
+func (c Claude) Classify(sc Screen) (AgentStatus, bool) { return c.RuleSet().Classify(sc) }
+
+// RuleSet returns the rules the profile classifies with.
+func (c Claude) RuleSet() *RuleSet {
+	if c.Rules != nil {
+		return c.Rules
+	}
+	return builtinRuleSet()
+}
+
+func (Claude) Sessions(worktreePath string) ([]Session, error) { return ListSessions(worktreePath) }
+
+// RegexProfile is an agent profile defined in config ([[agents]]): its
+// screen is classified by an ordered list of regex rules.
+type RegexProfile struct {
+	agent config.Agent
+	rules *RuleSet
+}
+
+// NewRegexProfile compiles a config-defined agent profile. Each of its
+// rules is a single-condition rule of the shared rule format.
+func NewRegexProfile(a config.Agent) (*RegexProfile, error) {
+	rules := make([]config.Rule, len(a.Rules))
+	for i, r := range a.Rules {
+		rules[i] = config.Rule{
+			Name:   fmt.Sprintf("%s rule %d", a.Name, i),
+			Status: r.Status,
+			When:   []config.Condition{{Pattern: r.Pattern, Bottom: r.Bottom}},
+		}
+	}
+	rs, err := CompileRules(rules)
+	if err != nil {
+		return nil, fmt.Errorf("agent %w", err)
+	}
+	return &RegexProfile{agent: a, rules: rs}, nil
+}
+
+func (p *RegexProfile) Name() string { return p.agent.Name }
+
+func (p *RegexProfile) Process() string { return p.agent.ProcessName() }
+
+func (p *RegexProfile) LaunchCommand() string { return p.agent.Command }
+
+func (p *RegexProfile) ResumeCommand(id string) string {
+	if p.agent.Resume == "" {
+		return ""
+	}
+	return strings.ReplaceAll(p.agent.Resume, "{id}", id)
+}
+
+// Classify returns the status of the first rule whose pattern matches its
+// region of the screen. No match means the agent's UI wasn't recognised.
+func (p *RegexProfile) Classify(sc Screen) (AgentStatus, bool) { return p.rules.Classify(sc) }
+
+// RuleSet returns the rules the profile classifies with.
+func (p *RegexProfile) RuleSet() *RuleSet { return p.rules }
+
+// Sessions lists the files matching the profile's history glob, using each
+// file's name (without extension) as the session ID.
+func (p *RegexProfile) Sessions(worktreePath string) ([]Session, error) {
+	if p.agent.History == "" {
+		return nil, nil
+	}
+	pattern := strings.ReplaceAll(p.agent.History, "{worktree}", worktreePath)
+	if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			pattern = filepath.Join(home, rest)
+		}
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("agent %s history: %w", p.agent.Name, err)
+	}
+
+	var sessions []Session
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		base := filepath.Base(path)
+		sessions = append(sessions, Session{
+			ID:      strings.TrimSuffix(base, filepath.Ext(base)),
+			CWD:     worktreePath,
+			ModTime: info.ModTime(),
+			Path:    path,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ModTime.After(sessions[j].ModTime)
+	})
+	return sessions, nil
+}
+
+// DefaultProfiles is the built-in Claude profile alone.
+func DefaultProfiles() []AgentProfile {
+	return []AgentProfile{Claude{}}
+}
+
+// Profiles builds every agent profile in cfg, the one new windows launch
+// (cfg.Agent) first. Claude panes are classified with cfg.Rules ahead of the
+// built-in rules.
+func Profiles(cfg config.Config) ([]AgentProfile, error) {
+	rules, err := ClaudeRules(cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+	profiles := []AgentProfile{Claude{Command: cfg.AgentCommand, Rules: rules}}
+	for _, a := range cfg.Agents {
+		p, err := NewRegexProfile(a)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+	for i, p := range profiles {
+		if p.Name() == cfg.Agent {
+			profiles = append([]AgentProfile{p}, slices.Delete(profiles, i, i+1)...)
+			break
+		}
+	}
+	return profiles, nil
+}
@@ -0,0 +1,141 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/grins/parkranger/internal/config"
+)
+
+func TestRegexProfile_Classify(t *testing.T) {
+	p, err := NewRegexProfile(config.Agent{
+		Name:    "aider",
+		Command: "aider",
+		Rules: []config.AgentRule{
+			{Pattern: `\(Y\)es/\(N\)o`, Status: "waiting", Bottom: 3},
+			{Pattern: `(?i)^Tokens:.*sent`, Status: "busy", Bottom: 5},
+			{Pattern: `^> $`, Status: "idle", Bottom: 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		screen   string
+		status   AgentStatus
+		hasAgent bool
+	}{
+		{"waiting", "Add file to chat? (Y)es/(N)o [Yes]:\n\n", StatusWaiting, true},
+		{"busy", "tokens: 2.1k sent, 300 received\nediting...", StatusBusy, true},
+		{"idle prompt", "aider v0.80\n> \n\n", StatusIdle, true},
+		{"prompt scrolled up", "> \nsome output", StatusUnknown, false},
+		{"question out of region", "(Y)es/(N)o\na\nb\nc\nd", StatusUnknown, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, hasAgent := p.Classify(ParseANSI(tt.screen))
+			if status != tt.status || hasAgent != tt.hasAgent {
+				t.Errorf("Classify = %v/%v, want %v/%v", status, hasAgent, tt.status, tt.hasAgent)
+			}
+		})
+	}
+}
+
+func TestNewRegexProfile_Invalid(t *testing.T) {
+	bad := []config.AgentRule{
+		{Pattern: "(", Status: "idle"},
+		{Pattern: "x", Status: "sleeping"},
+	}
+	for _, r := range bad {
+		if _, err := NewRegexProfile(config.Agent{Name: "x", Rules: []config.AgentRule{r}}); err == nil {
+			t.Errorf("expected error for rule %+v", r)
+		}
+	}
+}
+
+func TestRegexProfile_Commands(t *testing.T) {
+	p, _ := NewRegexProfile(config.Agent{Name: "codex", Command: "codex --full-auto", Resume: "codex resume {id}"})
+	if got := p.LaunchCommand(); got != "codex --full-auto" {
+		t.Errorf("LaunchCommand = %q", got)
+	}
+	if got := p.ResumeCommand("abc"); got != "codex resume abc" {
+		t.Errorf("ResumeCommand = %q", got)
+	}
+	if got := p.Process(); got != "codex" {
+		t.Errorf("Process = %q", got)
+	}
+
+	noResume, _ := NewRegexProfile(config.Agent{Name: "gemini", Command: "gemini"})
+	if got := noResume.ResumeCommand("abc"); got != "" {
+		t.Errorf("ResumeCommand without resume = %q, want empty", got)
+	}
+}
+
+func TestRegexProfile_Sessions(t *testing.T) {
+	wt := t.TempDir()
+	write := func(name string, age time.Duration) {
+		path := filepath.Join(wt, ".history", name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-age)
+		os.Chtimes(path, mtime, mtime)
+	}
+	write("old.md", 2*time.Hour)
+	write("new.md", time.Hour)
+	write("notes.txt", 0)
+
+	p, _ := NewRegexProfile(config.Agent{Name: "aider", Command: "aider", History: "{worktree}/.history/*.md"})
+	sessions, err := p.Sessions(wt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "new" || sessions[1].ID != "old" {
+		t.Errorf("Sessions = %+v, want new, old", sessions)
+	}
+
+	none, _ := NewRegexProfile(config.Agent{Name: "gemini", Command: "gemini"})
+	if s, err := none.Sessions(wt); s != nil || err != nil {
+		t.Errorf("Sessions without history = %v, %v", s, err)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	cfg := config.Default()
+	cfg.AgentCommand = "claude --model opus"
+	cfg.Agents = []config.Agent{
+		{Name: "aider", Command: "aider"},
+		{Name: "codex", Command: "codex"},
+	}
+
+	var names []string
+	profiles, err := Profiles(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range profiles {
+		names = append(names, p.Name())
+	}
+	if got := names; len(got) != 3 || got[0] != "claude" || got[1] != "aider" || got[2] != "codex" {
+		t.Errorf("default order = %v", got)
+	}
+	if got := profiles[0].ResumeCommand("abc"); got != "claude --model opus --resume abc" {
+		t.Errorf("claude resume = %q", got)
+	}
+
+	cfg.Agent = "codex"
+	profiles, _ = Profiles(cfg)
+	names = names[:0]
+	for _, p := range profiles {
+		names = append(names, p.Name())
+	}
+	if got := names; got[0] != "codex" || got[1] != "claude" || got[2] != "aider" {
+		t.Errorf("selected agent first = %v", got)
+	}
+}
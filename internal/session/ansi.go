@@ -0,0 +1,262 @@
+package session
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Color is a terminal colour in the forms lipgloss.Color accepts: "" for the
+// terminal default, "0"–"255" for palette colours, "#rrggbb" for true colour.
+type Color string
+
+// Style is the SGR state a run of text was drawn with.
+type Style struct {
+	FG, BG    Color
+	Bold      bool
+	Dim       bool
+	Italic    bool
+	Underline bool
+	Reverse   bool
+}
+
+// Span is a run of text in one style.
+type Span struct {
+	Text  string
+	Style Style
+}
+
+// Screen is a pane capture split into lines of styled spans.
+type Screen struct {
+	Lines [][]Span
+}
+
+// ParseANSI parses captured pane output containing escape sequences. SGR
+// (colour/attribute) sequences become span styles; every other control
+// sequence (cursor movement, OSC titles and hyperlinks, charset switches) is
+// dropped.
+func ParseANSI(s string) Screen {
+	var (
+		sc    Screen
+		line  []Span
+		text  strings.Builder
+		style Style
+	)
+	flush := func() {
+		if text.Len() > 0 {
+			line = append(line, Span{Text: text.String(), Style: style})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\n':
+			flush()
+			sc.Lines = append(sc.Lines, line)
+			line = nil
+		case c == '\r':
+		case c == 0x1b && i+1 < len(s):
+			switch s[i+1] {
+			case '[': // CSI: params, then a final byte in @–~
+				j := i + 2
+				for j < len(s) && (s[j] < 0x40 || s[j] > 0x7e) {
+					j++
+				}
+				if j < len(s) && s[j] == 'm' {
+					next := applySGR(style, s[i+2:j])
+					if next != style {
+						flush()
+						style = next
+					}
+				}
+				i = j
+			case ']': // OSC: runs to BEL or ESC \
+				j := i + 2
+				for j < len(s) && s[j] != 0x07 && !(s[j] == 0x1b && j+1 < len(s) && s[j+1] == '\\') {
+					j++
+				}
+				if j < len(s) && s[j] == 0x1b {
+					j++
+				}
+				i = j
+			case '(', ')': // charset designation: ESC ( B
+				i += 2
+			default:
+				i++
+			}
+		default:
+			text.WriteByte(c)
+		}
+	}
+	flush()
+	if len(line) > 0 || !strings.HasSuffix(s, "\n") {
+		sc.Lines = append(sc.Lines, line)
+	}
+	return sc
+}
+
+// applySGR returns st updated by the parameters of an SGR sequence.
+func applySGR(st Style, params string) Style {
+	// Colon sub-parameters ("38:2::r:g:b") carry the same values as the
+	// semicolon form; an empty colour-space slot is dropped.
+	params = strings.ReplaceAll(params, "::", ":")
+	fields := strings.FieldsFunc(params, func(r rune) bool { return r == ';' || r == ':' })
+	if len(fields) == 0 {
+		return Style{}
+	}
+	nums := make([]int, len(fields))
+	for i, f := range fields {
+		nums[i], _ = strconv.Atoi(f)
+	}
+
+	for i := 0; i < len(nums); i++ {
+		switch n := nums[i]; {
+		case n == 0:
+			st = Style{}
+		case n == 1:
+			st.Bold = true
+		case n == 2:
+			st.Dim = true
+		case n == 3:
+			st.Italic = true
+		case n == 4:
+			st.Underline = true
+		case n == 7:
+			st.Reverse = true
+		case n == 22:
+			st.Bold, st.Dim = false, false
+		case n == 23:
+			st.Italic = false
+		case n == 24:
+			st.Underline = false
+		case n == 27:
+			st.Reverse = false
+		case n >= 30 && n <= 37:
+			st.FG = paletteColor(n - 30)
+		case n >= 90 && n <= 97:
+			st.FG = paletteColor(n - 90 + 8)
+		case n == 39:
+			st.FG = ""
+		case n >= 40 && n <= 47:
+			st.BG = paletteColor(n - 40)
+		case n >= 100 && n <= 107:
+			st.BG = paletteColor(n - 100 + 8)
+		case n == 49:
+			st.BG = ""
+		case n == 38 || n == 48:
+			c, used := extendedColor(nums[i+1:])
+			if n == 38 {
+				st.FG = c
+			} else {
+				st.BG = c
+			}
+			i += used
+		}
+	}
+	return st
+}
+
+// extendedColor parses the arguments after 38/48: "5;n" or "2;r;g;b".
+// Returns the colour and how many arguments it consumed.
+func extendedColor(args []int) (Color, int) {
+	switch {
+	case len(args) >= 2 && args[0] == 5:
+		return paletteColor(args[1]), 2
+	case len(args) >= 4 && args[0] == 2:
+		return Color(fmt.Sprintf("#%02x%02x%02x", args[1]&0xff, args[2]&0xff, args[3]&0xff)), 4
+	}
+	return "", len(args)
+}
+
+func paletteColor(n int) Color {
+	return Color(strconv.Itoa(n))
+}
+
+// Text returns the screen without styling, one line per row.
+func (sc Screen) Text() string {
+	lines := make([]string, len(sc.Lines))
+	for i := range sc.Lines {
+		lines[i] = sc.LineText(i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LineText returns row i without styling.
+func (sc Screen) LineText(i int) string {
+	var b strings.Builder
+	for _, sp := range sc.Lines[i] {
+		b.WriteString(sp.Text)
+	}
+	return b.String()
+}
+
+// Render returns the screen with its styles re-encoded as SGR sequences
+// only, each line self-contained (reset at its end), so lines can be
+// truncated or dropped independently.
+func (sc Screen) Render() string {
+	lines := make([]string, len(sc.Lines))
+	for i, line := range sc.Lines {
+		var b strings.Builder
+		for _, sp := range line {
+			if sp.Style == (Style{}) {
+				b.WriteString(sp.Text)
+				continue
+			}
+			b.WriteString(sp.Style.sgr())
+			b.WriteString(sp.Text)
+			b.WriteString("\x1b[0m")
+		}
+		lines[i] = b.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sgr encodes the style as a single SGR sequence.
+func (st Style) sgr() string {
+	var codes []string
+	for _, a := range []struct {
+		on   bool
+		code string
+	}{{st.Bold, "1"}, {st.Dim, "2"}, {st.Italic, "3"}, {st.Underline, "4"}, {st.Reverse, "7"}} {
+		if a.on {
+			codes = append(codes, a.code)
+		}
+	}
+	if st.FG != "" {
+		codes = append(codes, st.FG.sgr(30, 90, 38))
+	}
+	if st.BG != "" {
+		codes = append(codes, st.BG.sgr(40, 100, 48))
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
+
+// sgr encodes the colour given the base codes for normal (30/40), bright
+// (90/100) and extended (38/48) colours.
+func (c Color) sgr(normal, bright, extended int) string {
+	if r, g, b, ok := c.rgb(); ok {
+		return fmt.Sprintf("%d;2;%d;%d;%d", extended, r, g, b)
+	}
+	n, _ := strconv.Atoi(string(c))
+	switch {
+	case n < 8:
+		return strconv.Itoa(normal + n)
+	case n < 16:
+		return strconv.Itoa(bright + n - 8)
+	default:
+		return fmt.Sprintf("%d;5;%d", extended, n)
+	}
+}
+
+func (c Color) rgb() (r, g, b int, ok bool) {
+	if len(c) != 7 || c[0] != '#' {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(string(c[1:]), 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff), true
+}
@@ -0,0 +1,116 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseANSI(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want [][]Span
+	}{
+		{"plain", "hello\nworld", [][]Span{{{Text: "hello"}}, {{Text: "world"}}}},
+		{
+			"basic colours and reset",
+			"a\x1b[31mred\x1b[0mb",
+			[][]Span{{{Text: "a"}, {Text: "red", Style: Style{FG: "1"}}, {Text: "b"}}},
+		},
+		{
+			"bright, 256 and true colour",
+			"\x1b[94mx\x1b[38;5;208my\x1b[48;2;255;0;16mz",
+			[][]Span{{
+				{Text: "x", Style: Style{FG: "12"}},
+				{Text: "y", Style: Style{FG: "208"}},
+				{Text: "z", Style: Style{FG: "208", BG: "#ff0010"}},
+			}},
+		},
+		{
+			"colon sub-parameters",
+			"\x1b[38:2::1:2:3mx",
+			[][]Span{{{Text: "x", Style: Style{FG: "#010203"}}}},
+		},
+		{
+			"attributes on and off",
+			"\x1b[1;7mon\x1b[22;27moff",
+			[][]Span{{{Text: "on", Style: Style{Bold: true, Reverse: true}}, {Text: "off"}}},
+		},
+		{
+			"style carries across lines",
+			"\x1b[32mone\ntwo\x1b[m",
+			[][]Span{{{Text: "one", Style: Style{FG: "2"}}}, {{Text: "two", Style: Style{FG: "2"}}}},
+		},
+		{
+			"non-SGR sequences dropped",
+			"\x1b]0;title\x07a\x1b[2Kb\x1b]8;;http://x\x1b\\c\x1b(Bd",
+			[][]Span{{{Text: "abcd"}}},
+		},
+		{"empty line kept", "a\n\nb", [][]Span{{{Text: "a"}}, nil, {{Text: "b"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseANSI(tt.in).Lines
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseANSI(%q) =\n %+v\nwant\n %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreen_TextAndRender(t *testing.T) {
+	in := "\x1b[1;38;5;208m❯ 1. Yes\x1b[0m\n  2. No\x1b]0;t\x07"
+	sc := ParseANSI(in)
+
+	if got, want := sc.Text(), "❯ 1. Yes\n  2. No"; got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+
+	rendered := sc.Render()
+	if want := "\x1b[1;38;5;208m❯ 1. Yes\x1b[0m\n  2. No"; rendered != want {
+		t.Errorf("Render = %q, want %q", rendered, want)
+	}
+	// Rendering is lossless for styles
+	if again := ParseANSI(rendered); !reflect.DeepEqual(again, sc) {
+		t.Errorf("re-parse = %+v, want %+v", again, sc)
+	}
+}
+
+func TestClassifyScreen_HighlightedSelection(t *testing.T) {
+	// A selection dialog whose footer is out of the bottom rows: as plain
+	// text the ❯ reads as the input prompt.
+	dialog := "Claude wants to run: rm -rf build\n" +
+		"\x1b[34m❯ 1. Yes\x1b[0m\n" +
+		"  2. Yes, and don't ask again\n" +
+		"  3. No\n\n\n\n\n\n"
+	// Boxed, the option sits behind a coloured border.
+	boxed := "\x1b[90m╭──────────╮\x1b[0m\n" +
+		"\x1b[90m│\x1b[0m Run it?  \x1b[90m│\x1b[0m\n" +
+		"\x1b[90m│\x1b[0m \x1b[34m❯ 1. Yes\x1b[0m \x1b[90m│\x1b[0m\n" +
+		"\x1b[90m│\x1b[0m   2. No  \x1b[90m│\x1b[0m\n\n\n\n\n\n"
+	// The same text typed at the idle input prompt isn't highlighted, boxed
+	// or not.
+	typed := "Claude Code\n\n❯ 1. fix the tests\n\n? for shortcuts  /help\n"
+	typedBoxed := "Claude Code\n\n\x1b[90m│\x1b[0m ❯ 1. fix the tests \x1b[90m│\x1b[0m\n\n? for shortcuts  /help\n"
+
+	tests := []struct {
+		name   string
+		in     string
+		status AgentStatus
+	}{
+		{"highlighted option", dialog, StatusWaiting},
+		{"boxed option", boxed, StatusWaiting},
+		{"typed input", typed, StatusIdle},
+		{"boxed typed input", typedBoxed, StatusIdle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, hasClaude := Claude{}.Classify(ParseANSI(tt.in))
+			if status != tt.status || !hasClaude {
+				t.Errorf("got %v/%v, want %v/true", status, hasClaude, tt.status)
+			}
+		})
+	}
+}
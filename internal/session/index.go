@@ -0,0 +1,289 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grins/parkranger/internal/atomicfile"
+	"github.com/grins/parkranger/internal/xdg"
+)
+
+// Index caches what parkranger reads from Claude session files: the
+// metadata shown in session lists and the token usage of each session.
+// Entries are keyed by file path and checked against the file's size and
+// mtime; a file that grew is read from where the last read stopped, since
+// Claude only ever appends to it. Safe for concurrent use.
+type Index struct {
+	mu        sync.Mutex
+	path      string
+	files     map[string]*indexEntry
+	changed   map[string]bool // entries read since the last Save
+	forgotten map[string]bool // entries whose file is gone
+}
+
+// indexEntry is what the index knows about one session file.
+type indexEntry struct {
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mod_time"`
+	Offset  int64       `json:"offset"` // bytes read; whole lines only
+	Lines   int         `json:"lines"`  // lines read
+	Meta    sessionMeta `json:"meta"`
+	Usage   usageLog    `json:"usage"`
+}
+
+// indexVersion changes whenever indexEntry does, invalidating old caches.
+const indexVersion = 3
+
+// indexFile is the on-disk layout.
+type indexFile struct {
+	Version int                    `json:"version"`
+	Files   map[string]*indexEntry `json:"files"`
+}
+
+// DefaultIndexPath returns the index location: <cache dir>/sessions.json.
+func DefaultIndexPath() string {
+	return filepath.Join(xdg.CacheDir(), "sessions.json")
+}
+
+// OpenIndex loads the index at path. It is only a cache, so a missing,
+// unreadable or outdated file yields an empty index.
+func OpenIndex(path string) *Index {
+	return &Index{
+		path:      path,
+		files:     readIndex(path),
+		changed:   make(map[string]bool),
+		forgotten: make(map[string]bool),
+	}
+}
+
+// readIndex loads the entries in the index file, or none.
+func readIndex(path string) map[string]*indexEntry {
+	files := make(map[string]*indexEntry)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return files
+	}
+	var f indexFile
+	if err := json.Unmarshal(data, &f); err != nil || f.Version != indexVersion {
+		return files
+	}
+	for k, e := range f.Files {
+		if e != nil {
+			files[k] = e
+		}
+	}
+	return files
+}
+
+var (
+	defaultMu  sync.Mutex
+	defaultIdx *Index
+)
+
+// defaultIndex returns the process-wide index at DefaultIndexPath, opening
+// it again if the cache directory changed.
+func defaultIndex() *Index {
+	path := DefaultIndexPath()
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultIdx == nil || defaultIdx.path != path {
+		defaultIdx = OpenIndex(path)
+	}
+	return defaultIdx
+}
+
+// SaveIndex writes what ListSessions and ReadUsage read since the last save
+// to the index at DefaultIndexPath. Call it once per command or dashboard
+// load rather than per call: each save rewrites the whole file.
+func SaveIndex() error {
+	return defaultIndex().Save()
+}
+
+// ListSessions is ListSessions answered from the index.
+func (x *Index) ListSessions(worktreePath string) ([]Session, error) {
+	dir := ProjectDir(worktreePath)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	seen := make(map[string]bool, len(entries))
+	var sessions []Session
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+
+		filePath := filepath.Join(dir, e.Name())
+		seen[filePath] = true
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		entry, err := x.entry(filePath, info)
+		if err != nil || entry.Meta.CWD == "" || !pathsMatch(entry.Meta.CWD, worktreePath) {
+			continue
+		}
+
+		sessions = append(sessions, Session{
+			ID:          entry.Meta.ID,
+			CWD:         entry.Meta.CWD,
+			FirstPrompt: entry.Meta.FirstPrompt,
+			FullPrompt:  entry.Meta.FullPrompt,
+			ModTime:     info.ModTime(),
+			Started:     entry.Meta.Started,
+			GitBranch:   entry.Meta.GitBranch,
+			Path:        filePath,
+		})
+	}
+
+	// Drop session files deleted from this project dir
+	for path := range x.files {
+		if filepath.Dir(path) == dir && !seen[path] {
+			delete(x.files, path)
+			delete(x.changed, path)
+			x.forgotten[path] = true
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ModTime.After(sessions[j].ModTime)
+	})
+
+	return sessions, nil
+}
+
+// ReadUsage is ReadUsage answered from the index.
+func (x *Index) ReadUsage(path string) ([]UsageRecord, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	e, err := x.entry(path, info)
+	if err != nil {
+		return nil, err
+	}
+	return append([]UsageRecord(nil), e.Usage.Records...), nil
+}
+
+// entry returns the up-to-date entry for the file at path, reading whatever
+// was appended since it was last seen. Caller holds mu.
+func (x *Index) entry(path string, info os.FileInfo) (*indexEntry, error) {
+	e, ok := x.files[path]
+	if ok && e.Size == info.Size() && e.ModTime.Equal(info.ModTime()) {
+		return e, nil
+	}
+	if !ok || info.Size() < e.Offset {
+		// New, or truncated and rewritten: start over
+		e = &indexEntry{Meta: sessionMeta{ID: strings.TrimSuffix(filepath.Base(path), ".jsonl")}}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(e.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := e.read(f); err != nil {
+		return nil, err
+	}
+
+	e.Size, e.ModTime = info.Size(), info.ModTime()
+	x.files[path] = e
+	x.changed[path] = true
+	delete(x.forgotten, path)
+	return e, nil
+}
+
+// read consumes whole lines from r, which is positioned at e.Offset. A
+// trailing line without a newline is still being written and is left for
+// next time.
+func (e *indexEntry) read(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e.Offset += int64(len(line))
+		e.Lines++
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if e.Lines <= metaLines {
+			e.Meta.add(line)
+		}
+		if entry, err := parseEntry(line); err == nil {
+			e.Usage.add(entry)
+		}
+	}
+}
+
+// Save writes the entries read since the last Save. Several processes may
+// share the file, so it merges, under a lock, with what is on disk, keeping
+// the entries this process did not touch, and drops entries whose file no
+// longer exists.
+func (x *Index) Save() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if len(x.changed) == 0 && len(x.forgotten) == 0 {
+		return nil
+	}
+
+	unlock, err := atomicfile.Lock(x.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	merged := readIndex(x.path)
+	for path := range x.forgotten {
+		delete(merged, path)
+	}
+	for path := range x.changed {
+		merged[path] = x.files[path]
+	}
+	for path := range merged {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(merged, path)
+		}
+	}
+
+	data, err := json.Marshal(indexFile{Version: indexVersion, Files: merged})
+	if err != nil {
+		return err
+	}
+
+	if err := atomicfile.Write(x.path, append(data, '\n')); err != nil {
+		return err
+	}
+	x.files = merged
+	x.changed = make(map[string]bool)
+	x.forgotten = make(map[string]bool)
+	return nil
+}
@@ -0,0 +1,140 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestMain keeps the session index out of the user's cache directory.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "parkranger-session-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CACHE_HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestIndex_Incremental(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	wt := "/work/repo"
+	dir := ProjectDir(wt)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "s1.jsonl")
+	write := func(content string, flag int) {
+		t.Helper()
+		f, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString(content); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	assistant := func(id string, out int) string {
+		return `{"type":"assistant","timestamp":"2025-06-01T10:00:00Z","message":{"id":"` + id + `","model":"m","usage":{"output_tokens":` + strconv.Itoa(out) + `}}}` + "\n"
+	}
+
+	write(`{"type":"user","cwd":"/work/repo","gitBranch":"main","message":{"role":"user","content":"Hello"}}`+"\n"+assistant("m1", 1), os.O_TRUNC)
+	x := OpenIndex(filepath.Join(t.TempDir(), "sessions.json"))
+	sessions, err := x.ListSessions(wt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "s1" || sessions[0].FirstPrompt != "Hello" || sessions[0].GitBranch != "main" {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+
+	// Appended lines are read from the last offset; a half-written line waits
+	offset := x.files[path].Offset
+	write(assistant("m1", 2)+assistant("m2", 3)+`{"type":"assis`, os.O_APPEND)
+	records, err := x.ReadUsage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Responses != 2 || records[0].OutputTokens != 2+3 {
+		t.Fatalf("records = %+v, want m1 updated and m2 added", records)
+	}
+	e := x.files[path]
+	if e.Lines != 4 || e.Offset <= offset {
+		t.Errorf("entry read %d lines to offset %d", e.Lines, e.Offset)
+	}
+	info, _ := os.Stat(path)
+	if e.Offset >= info.Size() {
+		t.Errorf("offset %d includes the partial line (size %d)", e.Offset, info.Size())
+	}
+
+	// Saved entries are used as they are by the next process
+	if err := x.Save(); err != nil {
+		t.Fatal(err)
+	}
+	y := OpenIndex(x.path)
+	if got := y.files[path]; got == nil || got.Offset != e.Offset || len(got.Usage.Recent) != 2 {
+		t.Fatalf("reopened entry = %+v", got)
+	}
+	write(`tant","message":{"id":"m2","model":"m","usage":{"output_tokens":4}}}`+"\n", os.O_APPEND)
+	if records, _ := y.ReadUsage(path); len(records) != 1 || records[0].Responses != 2 || records[0].OutputTokens != 2+4 {
+		t.Errorf("after reopening, records = %+v", records)
+	}
+
+	// A rewritten, shorter file is read from the start
+	write(`{"type":"user","cwd":"/work/repo","message":{"role":"user","content":"Again"}}`+"\n", os.O_TRUNC)
+	sessions, _ = y.ListSessions(wt)
+	if records, _ := y.ReadUsage(path); len(sessions) != 1 || sessions[0].FirstPrompt != "Again" || len(records) != 0 {
+		t.Errorf("after rewrite, sessions = %+v, records = %+v", sessions, records)
+	}
+
+	// Deleted files leave the index
+	os.Remove(path)
+	if sessions, _ := y.ListSessions(wt); len(sessions) != 0 {
+		t.Errorf("sessions = %+v after delete", sessions)
+	}
+	if err := y.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if files := readIndex(x.path); len(files) != 0 {
+		t.Errorf("saved index = %v, want empty", files)
+	}
+}
+
+func TestIndex_Unchanged(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	wt := "/work/other"
+	dir := ProjectDir(wt)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "s2.jsonl")
+	if err := os.WriteFile(path, []byte(`{"type":"user","cwd":"/work/other","message":{"role":"user","content":"Hi"}}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	x := OpenIndex(filepath.Join(t.TempDir(), "sessions.json"))
+	if _, err := x.ListSessions(wt); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same size and mtime: the cached metadata is trusted without reading
+	x.files[path].Meta.FirstPrompt = "cached"
+	sessions, _ := x.ListSessions(wt)
+	if len(sessions) != 1 || sessions[0].FirstPrompt != "cached" {
+		t.Errorf("sessions = %+v, want the cached entry", sessions)
+	}
+	if len(x.changed) != 0 {
+		t.Errorf("changed = %v, want nothing to save", x.changed)
+	}
+	if time.Since(sessions[0].ModTime) > time.Minute {
+		t.Errorf("ModTime = %v", sessions[0].ModTime)
+	}
+}
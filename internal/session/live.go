@@ -2,10 +2,15 @@ package session
 
 import (
 	"crypto/sha256"
-	"regexp"
+	"errors"
+	"fmt"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/grins/parkranger/internal/tmux"
+	"github.com/grins/parkranger/internal/mux"
+	"github.com/grins/parkranger/internal/proc"
 )
 
 // AgentStatus represents the detected state of a Claude agent in a tmux pane.
@@ -16,6 +21,8 @@ const (
 	StatusIdle
 	StatusBusy
 	StatusWaiting
+	StatusError  // the agent reported an error: crash output, API failure, rate limit
+	StatusExited // the agent's pane is back at a shell or dead
 )
 
 func (s AgentStatus) String() string {
@@ -26,199 +33,400 @@ func (s AgentStatus) String() string {
 		return "busy"
 	case StatusWaiting:
 		return "waiting"
+	case StatusError:
+		return "error"
+	case StatusExited:
+		return "exited"
 	default:
 		return "unknown"
 	}
 }
 
-// busyActivityRe matches Claude's activity stats line, e.g.
-// "↓ 20.1k tokens · thought for 288s" or "tokens · thinking"
-var busyActivityRe = regexp.MustCompile(`(?i)\d+\.?\d*k?\s+tokens?\s*·\s*(?:thinking|thought)`)
+// MarshalText encodes the status by name so persisted state stays readable.
+func (s AgentStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a status name written by MarshalText.
+func (s *AgentStatus) UnmarshalText(text []byte) error {
+	status, err := ParseStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
 
-// LiveInfo describes the live state of a tmux session's Claude pane.
+// ParseStatus returns the status with the given name.
+func ParseStatus(name string) (AgentStatus, error) {
+	for _, s := range []AgentStatus{StatusUnknown, StatusIdle, StatusBusy, StatusWaiting, StatusError, StatusExited} {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return StatusUnknown, fmt.Errorf("unknown agent status %q", name)
+}
+
+// LiveInfo describes the live state of a worktree's tmux window. A window
+// may run several agents; the headline fields describe the most actionable
+// one (see Priority) and Panes lists them all.
 type LiveInfo struct {
-	Exists      bool        // tmux session exists
-	HasClaude   bool        // Claude UI detected in pane
-	Status      AgentStatus // idle/busy/waiting
-	PaneContent string      // raw captured pane text (for preview)
+	Exists      bool         `json:"exists"`                 // tmux session exists
+	HasClaude   bool         `json:"has_claude"`             // Claude UI detected in pane, or its pane exited
+	Agent       string       `json:"agent,omitempty"`        // profile name of the headline pane
+	Status      AgentStatus  `json:"status"`                 // idle/busy/waiting/error/exited
+	Since       time.Time    `json:"since,omitzero"`         // when the headline pane entered Status
+	PaneID      string       `json:"pane_id,omitempty"`      // agent pane the status was read from
+	Unreadable  bool         `json:"unreadable,omitempty"`   // the multiplexer can't read the agent pane (mux.ErrNotFocused)
+	PaneContent string       `json:"pane_content,omitempty"` // captured pane text, escapes stripped
+	PaneStyled  string       `json:"pane_styled,omitempty"`  // PaneContent with SGR colours (for preview)
+	Panes       []PaneStatus `json:"panes,omitempty"`        // every agent pane, in pane order
+}
+
+// PaneStatus is the detected state of one agent pane.
+type PaneStatus struct {
+	ID        string      `json:"id"`
+	Title     string      `json:"title,omitempty"` // pane title; Claude sets it to the current task
+	HasClaude bool        `json:"has_claude"`
+	Status    AgentStatus `json:"status"`
+	Since     time.Time   `json:"since,omitzero"` // when the pane entered Status
+}
+
+// Priority ranks statuses by how urgently they need the user:
+// waiting > error > exited > busy > idle > unknown.
+func (s AgentStatus) Priority() int {
+	switch s {
+	case StatusWaiting:
+		return 5
+	case StatusError:
+		return 4
+	case StatusExited:
+		return 3
+	case StatusBusy:
+		return 2
+	case StatusIdle:
+		return 1
+	default:
+		return 0
+	}
 }
 
-// Detector wraps stateful agent detection with hash-based change tracking.
-// Create one per worktree and reuse across polls.
+// CaptureLines is how many rows at the bottom of an agent pane detection
+// reads: enough for the most recent activity and any dialog.
+const CaptureLines = 30
+
+// Detector wraps stateful agent detection: each agent pane's raw
+// classification goes through a Tracker, which debounces it and keeps the
+// time it entered its status. Create one per worktree and reuse across polls.
 type Detector struct {
-	prevHash   [32]byte
-	prevStatus AgentStatus
-	hasHistory bool
+	Profiles []AgentProfile  // agents to look for, in preference order; DefaultProfiles if empty
+	Debounce Debounce        // DefaultDebounce if zero
+	Mux      mux.Multiplexer // mux.Default() if nil
+
+	trackers map[string]*Tracker // per agent pane ID
 }
 
-// Detect checks the tmux window for a running Claude agent, using hash-based
-// change detection to upgrade Unknown→Busy when pane content is changing.
+// Detect finds the agent panes in the tmux window and classifies each one.
+// The headline is the most actionable pane. If the multiplexer can't be
+// read, the window is taken to still exist with an unknown status, and the
+// pane history is kept for the next call.
 func (d *Detector) Detect(sessionName, windowName string) LiveInfo {
-	if !tmux.WindowExists(sessionName, windowName) {
+	snap, err := d.mux().Snapshot()
+	if err != nil {
+		return LiveInfo{Exists: true, Status: StatusUnknown}
+	}
+	return d.DetectIn(snap, sessionName, windowName)
+}
+
+// DetectIn is Detect reading the window's panes from snap, so one snapshot
+// can serve every window of a poll. Only the captures go to the multiplexer.
+func (d *Detector) DetectIn(snap mux.Snapshot, sessionName, windowName string) LiveInfo {
+	now := time.Now()
+	win, ok := snap.Window(sessionName, windowName)
+	if !ok {
+		d.trackers = nil
 		return LiveInfo{}
 	}
+	panes := win.Panes
 
-	target := sessionName + ":" + windowName + ".1"
-	output, err := tmux.CapturePaneBottom(target, 30)
-	if err != nil || output == "" {
+	agents := agentPanes(panes, processes, d.profiles())
+	if len(agents) == 0 {
+		d.trackers = nil
 		return LiveInfo{Exists: true}
 	}
 
-	status, hasClaude := classifyPaneOutput(output)
+	titles := make(map[string]string, len(panes))
+	for _, p := range panes {
+		titles[p.ID] = p.Title
+	}
+
+	trackers := make(map[string]*Tracker, len(agents))
+	var infos []LiveInfo
+	for _, ap := range agents {
+		id := ap.id
+		tr, ok := d.trackers[id]
+		if !ok {
+			tr = &Tracker{}
+		}
+		tr.Debounce = d.debounce()
+		trackers[id] = tr
 
-	// Hash-based change detection: if pattern detection is inconclusive
-	// but the pane content changed, the agent is likely streaming output.
-	hash := sha256.Sum256([]byte(output))
-	if status == StatusUnknown && d.hasHistory && hash != d.prevHash {
-		status = StatusBusy
-		hasClaude = true
+		raw, err := d.mux().Capture(mux.Pane{ID: id, Height: ap.height}, CaptureLines)
+		if !ap.exited && (err != nil || raw == "") {
+			infos = append(infos, LiveInfo{Exists: true, PaneID: id, Unreadable: errors.Is(err, mux.ErrNotFocused)})
+			continue
+		}
+		infos = append(infos, observePane(now, tr, ap, raw))
 	}
+	// Panes that went away drop out of the history
+	d.trackers = trackers
 
-	d.prevHash = hash
-	d.prevStatus = status
-	d.hasHistory = true
+	return aggregate(infos, titles)
+}
+
+// observePane classifies one capture of an agent pane and runs it through
+// the pane's tracker.
+func observePane(now time.Time, tr *Tracker, ap agentPane, raw string) LiveInfo {
+	screen := ParseANSI(raw)
+	output := screen.Text()
+
+	// Hashing the stripped text ignores colour-only churn (cursor blink,
+	// highlight animations).
+	obs := Observation{Status: StatusExited, HasAgent: true, Hash: sha256.Sum256([]byte(output))}
+	if !ap.exited {
+		obs.Status, obs.HasAgent = ap.profile.Classify(screen)
+	}
+	tr.Observe(now, obs)
+	status, hasAgent, since := tr.Status()
 
 	return LiveInfo{
 		Exists:      true,
-		HasClaude:   hasClaude,
+		HasClaude:   hasAgent,
+		Agent:       ap.profile.Name(),
 		Status:      status,
+		Since:       since,
+		PaneID:      ap.id,
 		PaneContent: output,
+		PaneStyled:  screen.Render(),
 	}
 }
 
-// DetectLive checks the tmux window for a running Claude agent.
-// Captures the bottom 30 lines of pane <session>:<window>.1 (the right pane
-// where claude runs) to always see the most recent activity.
-// This is the stateless version — prefer Detector for repeated polling.
-func DetectLive(sessionName, windowName string) LiveInfo {
-	if !tmux.WindowExists(sessionName, windowName) {
-		return LiveInfo{}
+// aggregate folds per-pane results into one LiveInfo headed by the most
+// actionable pane: highest Priority, then panes showing the Claude UI, then
+// pane order.
+func aggregate(infos []LiveInfo, titles map[string]string) LiveInfo {
+	best := 0
+	for i, info := range infos {
+		b := infos[best]
+		if p, bp := info.Status.Priority(), b.Status.Priority(); p > bp || (p == bp && info.HasClaude && !b.HasClaude) {
+			best = i
+		}
 	}
 
-	target := sessionName + ":" + windowName + ".1"
-	output, err := tmux.CapturePaneBottom(target, 30)
-	if err != nil || output == "" {
-		return LiveInfo{Exists: true}
+	live := infos[best]
+	live.Panes = make([]PaneStatus, len(infos))
+	for i, info := range infos {
+		live.Panes[i] = PaneStatus{
+			ID:        info.PaneID,
+			Title:     titles[info.PaneID],
+			HasClaude: info.HasClaude,
+			Status:    info.Status,
+			Since:     info.Since,
+		}
 	}
+	return live
+}
 
-	status, hasClaude := classifyPaneOutput(output)
-	return LiveInfo{
-		Exists:      true,
-		HasClaude:   hasClaude,
-		Status:      status,
-		PaneContent: output,
+func (d *Detector) debounce() Debounce {
+	if d.Debounce == (Debounce{}) {
+		return DefaultDebounce
 	}
+	return d.Debounce
 }
 
-// bottomN returns the last n elements of a string slice.
-func bottomN(lines []string, n int) []string {
-	if len(lines) <= n {
-		return lines
+func (d *Detector) mux() mux.Multiplexer {
+	if d.Mux != nil {
+		return d.Mux
 	}
-	return lines[len(lines)-n:]
+	return mux.Default()
 }
 
-// classifyPaneOutput determines the agent status from captured pane content.
-//
-// Status indicators in Claude Code appear at the bottom of the terminal pane.
-// We restrict pattern matching to the bottom N lines to avoid false positives
-// from stale indicators that scrolled up but remain visible in the capture.
-//
-// Detection priority (from CLAUDE.md):
-//  1. ⌕ (U+2315) → idle, not claude (search UI overlay)
-//  2. "ctrl+r to toggle" → unknown (history search UI)
-//  3. Permission prompts / "esc to cancel" → waiting
-//  4. ✢ (U+2722) spinner / activity stats / "esc to interrupt" → busy
-//  5. Claude UI elements visible → idle
-//  6. Default → unknown
-func classifyPaneOutput(output string) (AgentStatus, bool) {
-	// Split into lines and trim trailing blanks (tmux capture-pane may include them)
-	lines := strings.Split(output, "\n")
-	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
-		lines = lines[:len(lines)-1]
-	}
-	if len(lines) == 0 {
-		return StatusUnknown, false
+func (d *Detector) profiles() []AgentProfile {
+	if len(d.Profiles) > 0 {
+		return d.Profiles
 	}
+	return DefaultProfiles()
+}
 
-	lower := strings.ToLower(output)
+// DetectLive checks the tmux window for running agents (DefaultProfiles
+// unless profiles are given), capturing the bottom 30 lines of their panes
+// to always see the most recent activity. This is the stateless version —
+// prefer Detector for repeated polling.
+func DetectLive(sessionName, windowName string, profiles ...AgentProfile) LiveInfo {
+	d := Detector{Profiles: profiles}
+	return d.Detect(sessionName, windowName)
+}
 
-	// Bottom slices for targeted detection.
-	// Claude Code's status bar, prompts, and input area are always at the
-	// bottom of the pane. Checking only the bottom avoids stale patterns
-	// from earlier output that scrolled up but remain visible.
-	b15 := bottomN(lines, 15)
-	b10 := bottomN(lines, 10)
-	b5 := bottomN(lines, 5)
-	bot10 := strings.ToLower(strings.Join(b10, "\n"))
-	bot5 := strings.ToLower(strings.Join(b5, "\n"))
-	raw5 := strings.Join(b5, "\n")       // preserve case for unicode (❯)
-	raw15 := strings.Join(b15, "\n")     // preserve case for unicode (✢)
+// Explanation is how one agent pane's capture was classified.
+type Explanation struct {
+	PaneID string
+	Agent  string // profile name
+	Match  Match  // Index -1 if no rule matched
+	Rules  bool   // the profile classifies with a RuleSet; Match is only set if so
+	Status AgentStatus
+}
 
-	// 1. Search UI override — ⌕ (U+2315) anywhere → idle, not Claude
-	if strings.Contains(output, "\u2315") {
-		return StatusIdle, false
-	}
+// ruleProfile is an agent profile that classifies with a RuleSet.
+type ruleProfile interface {
+	RuleSet() *RuleSet
+}
 
-	// 2. History search — bottom 10 → unknown (state hold)
-	if strings.Contains(bot10, "ctrl+r to toggle") {
-		return StatusUnknown, false
+// ExplainWindow captures every agent pane in the window (found as Detect
+// finds them) and reports which rule classified each. Detect's change
+// tracking is left out: it needs a previous poll.
+func ExplainWindow(sessionName, windowName string, profiles ...AgentProfile) ([]Explanation, error) {
+	if len(profiles) == 0 {
+		profiles = DefaultProfiles()
 	}
-
-	// 3. Waiting — needs user attention (bottom of pane only)
-	//    "esc to cancel": active input/dialog UI element (bottom 5)
-	//    "No, and tell Claude...": ephemeral selection text, only visible when active (bottom 10)
-	//    "do you want" / "would you like": question text (bottom 5 to avoid stale matches)
-	if strings.Contains(bot5, "esc to cancel") ||
-		strings.Contains(bot10, "no, and tell claude what to do differently") ||
-		strings.Contains(bot5, "do you want") ||
-		strings.Contains(bot5, "would you like") {
-		return StatusWaiting, true
+	panes := mux.Default().ListPanes(sessionName, windowName)
+	if panes == nil {
+		return nil, fmt.Errorf("no window %s:%s", sessionName, windowName)
+	}
+	var out []Explanation
+	for _, ap := range agentPanes(panes, processes, profiles) {
+		if ap.exited {
+			out = append(out, Explanation{PaneID: ap.id, Agent: ap.profile.Name(), Match: Match{Index: -1}, Status: StatusExited})
+			continue
+		}
+		e, err := ExplainPane(ap.id, ap.profile)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, e)
 	}
+	return out, nil
+}
 
-	// 4. Busy — Claude is working
-	//    ✢ (U+2722) spinner: only visible during active processing (bottom 15)
-	//    Activity stats: "tokens · thinking/thought" (bottom 10)
-	//    Legacy: "esc to interrupt" / "ctrl+c to interrupt" (bottom 5)
-	if strings.Contains(raw15, "\u2722") {
-		return StatusBusy, true
+// ExplainPane captures one pane and classifies it with profile.
+func ExplainPane(target string, profile AgentProfile) (Explanation, error) {
+	raw, err := mux.Default().Capture(mux.Pane{ID: target}, CaptureLines)
+	if err != nil {
+		return Explanation{}, fmt.Errorf("capture %s: %w", target, err)
+	}
+	screen := ParseANSI(raw)
+	e := Explanation{PaneID: target, Agent: profile.Name(), Match: Match{Index: -1}}
+	if rp, ok := profile.(ruleProfile); ok {
+		e.Rules = true
+		e.Match = rp.RuleSet().Explain(screen)
+		e.Status = e.Match.Status
+	} else {
+		e.Status, _ = profile.Classify(screen)
 	}
-	if busyActivityRe.MatchString(bot10) {
-		return StatusBusy, true
+	return e, nil
+}
+
+// agentPane is a pane running an agent, with the profile that reads it.
+type agentPane struct {
+	id      string
+	height  int // rows, for capturing; 0 if unknown
+	profile AgentProfile
+	exited  bool // the agent is gone: the pane is dead or back at its shell
+}
+
+// agentPanes returns the panes running one of the profiles' agents, in pane
+// order. A pane runs an agent if its foreground command is the agent or the
+// agent is anywhere in the process tree under the pane's shell (wrappers,
+// `npx`, shell functions); earlier profiles win when several match.
+//
+// Panes a layout tagged as agent panes are returned as exited when they are
+// dead or sit at a shell prompt. A tagged pane running something else is
+// only returned when no agent runs anywhere in the window, read with the
+// first profile, so the last screen of an agent is still classified.
+func agentPanes(panes []mux.Pane, procs func() proc.Table, profiles []AgentProfile) []agentPane {
+	var found, tagged []agentPane
+	var table proc.Table
+	for _, p := range panes {
+		if p.Dead {
+			if p.Agent {
+				found = append(found, agentPane{id: p.ID, height: p.Height, profile: profiles[0], exited: true})
+			}
+			continue
+		}
+		if prof := paneAgent(p, profiles, func() proc.Table {
+			if table == nil {
+				table = procs()
+			}
+			return table
+		}); prof != nil {
+			found = append(found, agentPane{id: p.ID, height: p.Height, profile: prof})
+			continue
+		}
+		if p.Agent && isShell(p.Command) {
+			found = append(found, agentPane{id: p.ID, height: p.Height, profile: profiles[0], exited: true})
+		} else if p.Agent {
+			tagged = append(tagged, agentPane{id: p.ID, height: p.Height, profile: profiles[0]})
+		}
 	}
-	if strings.Contains(bot5, "esc to interrupt") ||
-		strings.Contains(bot5, "ctrl+c to interrupt") {
-		return StatusBusy, true
+	if len(found) == 0 {
+		return tagged
 	}
+	return found
+}
 
-	// 5. Claude UI elements → idle
-	//    "claude" branding anywhere in output + prompt/hint indicators at bottom
-	//    Also detect model names (opus, sonnet, haiku) and "ctx:" in status bar
-	hasPrompt := strings.Contains(raw5, "❯") ||
-		strings.Contains(bot10, "type a message") ||
-		strings.Contains(bot10, "type your message")
-	hasHints := strings.Contains(bot10, "/help") ||
-		strings.Contains(bot10, "shift+")
+// shells are the programs an agent pane returns to when the agent exits.
+var shells = []string{"sh", "bash", "zsh", "fish", "dash", "ksh", "mksh", "tcsh", "csh", "nu", "xonsh", "elvish"}
 
-	// Model name or context indicator in status bar → Claude session
-	hasModelBar := strings.Contains(bot5, "ctx:") ||
-		strings.Contains(bot5, "opus") ||
-		strings.Contains(bot5, "sonnet") ||
-		strings.Contains(bot5, "haiku")
+// isShell reports whether a pane's foreground command is an interactive shell.
+func isShell(command string) bool {
+	return slices.Contains(shells, strings.TrimPrefix(command, "-"))
+}
 
-	if strings.Contains(lower, "claude") && (hasPrompt || hasHints) {
-		return StatusIdle, true
+// paneAgent returns the profile whose agent runs in pane p, or nil. The
+// process table is only read if no foreground command matches.
+func paneAgent(p mux.Pane, profiles []AgentProfile, table func() proc.Table) AgentProfile {
+	for _, prof := range profiles {
+		if p.Command == prof.Process() {
+			return prof
+		}
 	}
-
-	// Also idle when Claude header scrolled off-screen but prompt + hints visible
-	if hasPrompt && hasHints {
-		return StatusIdle, true
+	if p.PID <= 0 {
+		return nil // the multiplexer doesn't know the pane's process
 	}
+	for _, prof := range profiles {
+		name := prof.Process()
+		if _, ok := table().Find(p.PID, func(pr proc.Process) bool { return pr.Name(name) }); ok {
+			return prof
+		}
+	}
+	return nil
+}
 
-	// Model/ctx bar + prompt → idle Claude (header may have scrolled off)
-	if hasModelBar && hasPrompt {
-		return StatusIdle, true
+// processes returns a process table snapshot, shared by every Detect call
+// within procTTL so a poll cycle over many windows walks /proc once.
+func processes() proc.Table {
+	procCache.Lock()
+	defer procCache.Unlock()
+	if time.Since(procCache.at) < procTTL {
+		return procCache.table
 	}
+	table, err := proc.Snapshot()
+	if err != nil {
+		table = proc.Table{}
+	}
+	procCache.table, procCache.at = table, time.Now()
+	return table
+}
 
-	return StatusUnknown, false
+const procTTL = 250 * time.Millisecond
+
+var procCache struct {
+	sync.Mutex
+	table proc.Table
+	at    time.Time
+}
+
+// classifyPaneOutput classifies plain captured text with the built-in
+// Claude rules (see claude_rules.toml).
+func classifyPaneOutput(output string) (AgentStatus, bool) {
+	return builtinRuleSet().Classify(ParseANSI(output))
 }
@@ -1,8 +1,17 @@
 package session
 
 import (
+	"errors"
+	"os/exec"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/grins/parkranger/internal/config"
+	"github.com/grins/parkranger/internal/mux"
+	"github.com/grins/parkranger/internal/proc"
+	"github.com/grins/parkranger/internal/tmux"
 )
 
 func TestClassifyPaneOutput_SearchUI(t *testing.T) {
@@ -157,6 +166,8 @@ func TestAgentStatusString(t *testing.T) {
 		{StatusIdle, "idle"},
 		{StatusBusy, "busy"},
 		{StatusWaiting, "waiting"},
+		{StatusError, "error"},
+		{StatusExited, "exited"},
 	}
 	for _, tt := range tests {
 		if got := tt.s.String(); got != tt.want {
@@ -342,7 +353,18 @@ func TestBottomN(t *testing.T) {
 	}
 }
 
-func TestBusyActivityRe(t *testing.T) {
+func TestBusyActivityRule(t *testing.T) {
+	var activity []config.Rule
+	for _, r := range BuiltinRules().Rules {
+		if r.Name == "activity" {
+			activity = append(activity, r)
+		}
+	}
+	rs, err := CompileRules(activity)
+	if err != nil || len(activity) != 1 {
+		t.Fatalf("activity rule: %v (%d found)", err, len(activity))
+	}
+
 	tests := []struct {
 		input string
 		want  bool
@@ -356,9 +378,371 @@ func TestBusyActivityRe(t *testing.T) {
 		{"tokens without number", false},
 	}
 	for _, tt := range tests {
-		got := busyActivityRe.MatchString(tt.input)
-		if got != tt.want {
-			t.Errorf("busyActivityRe.MatchString(%q) = %v, want %v", tt.input, got, tt.want)
+		status, _ := rs.Classify(ParseANSI(tt.input))
+		if got := status == StatusBusy; got != tt.want {
+			t.Errorf("activity rule on %q = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestAgentStatusText(t *testing.T) {
+	for _, s := range []AgentStatus{StatusUnknown, StatusIdle, StatusBusy, StatusWaiting} {
+		text, err := s.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got AgentStatus
+		if err := got.UnmarshalText(text); err != nil {
+			t.Fatal(err)
+		}
+		if got != s {
+			t.Errorf("round trip %v → %q → %v", s, text, got)
+		}
+	}
+
+	var s AgentStatus
+	if err := s.UnmarshalText([]byte("sleepy")); err == nil {
+		t.Error("expected error for unknown status name")
+	}
+}
+
+func TestAgentPanes(t *testing.T) {
+	table := proc.Table{
+		100: {PID: 100, PPID: 1, Comm: "zsh"},
+		101: {PID: 101, PPID: 100, Comm: "nvim"},
+		200: {PID: 200, PPID: 1, Comm: "zsh"},
+		201: {PID: 201, PPID: 200, Comm: "npx"},
+		202: {PID: 202, PPID: 201, Comm: "claude"},
+		300: {PID: 300, PPID: 1, Comm: "zsh"},
+		400: {PID: 400, PPID: 1, Comm: "zsh"},
+		401: {PID: 401, PPID: 400, Comm: "python3", Args: []string{"aider", "--no-pretty"}},
+	}
+	procs := func() proc.Table { return table }
+	aider, err := NewRegexProfile(config.Agent{Name: "aider", Command: "aider"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles := []AgentProfile{Claude{}, aider}
+
+	tests := []struct {
+		name  string
+		panes []tmux.Pane
+		want  []string
+	}{
+		{
+			"process tree",
+			[]tmux.Pane{
+				{ID: "%1", PID: 100, Command: "nvim"},
+				{ID: "%2", PID: 200, Command: "npx"},
+			},
+			[]string{"%2 claude"},
+		},
+		{
+			"foreground command, any position",
+			[]tmux.Pane{
+				{ID: "%5", PID: 500, Command: "claude"},
+				{ID: "%1", PID: 100, Command: "nvim"},
+				{ID: "%6", PID: 600, Command: "claude"},
+			},
+			[]string{"%5 claude", "%6 claude"},
+		},
+		{
+			"tagged fallback",
+			[]tmux.Pane{
+				{ID: "%1", PID: 100, Command: "nvim"},
+				{ID: "%3", PID: 300, Command: "less", Agent: true},
+			},
+			[]string{"%3 claude"},
+		},
+		{
+			"running agent beats tag",
+			[]tmux.Pane{
+				{ID: "%3", PID: 300, Command: "less", Agent: true},
+				{ID: "%2", PID: 200, Command: "npx"},
+			},
+			[]string{"%2 claude"},
+		},
+		{
+			"tagged pane back at its shell",
+			[]tmux.Pane{
+				{ID: "%3", PID: 300, Command: "zsh", Agent: true},
+				{ID: "%2", PID: 200, Command: "npx"},
+			},
+			[]string{"%3 claude exited", "%2 claude"},
+		},
+		{
+			"dead panes",
+			[]tmux.Pane{
+				{ID: "%8", PID: 800, Command: "claude", Agent: true, Dead: true},
+				{ID: "%9", PID: 900, Command: "claude", Dead: true},
+			},
+			[]string{"%8 claude exited"},
+		},
+		{
+			"mixed agents",
+			[]tmux.Pane{
+				{ID: "%4", PID: 400, Command: "python3"},
+				{ID: "%2", PID: 200, Command: "npx"},
+				{ID: "%7", PID: 700, Command: "aider"},
+			},
+			[]string{"%4 aider", "%2 claude", "%7 aider"},
+		},
+		{"none", []tmux.Pane{{ID: "%1", PID: 100, Command: "nvim"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, ap := range agentPanes(tt.panes, procs, profiles) {
+				desc := ap.id + " " + ap.profile.Name()
+				if ap.exited {
+					desc += " exited"
+				}
+				got = append(got, desc)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("agentPanes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDetect_FindsAgentPane runs a fake agent in the first pane of a window on
+// a private tmux server: detection must follow it, not pane index 1.
+func TestDetect_FindsAgentPane(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	t.Setenv("TMUX", "")
+	t.Cleanup(func() { exec.Command("tmux", "kill-server").Run() })
+
+	dir := t.TempDir()
+	if err := tmux.CreateSession("pr-test", dir); err != nil {
+		t.Fatal(err)
+	}
+	agentPane, err := tmux.NewWindow("pr-test", "feat", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmux.SplitPane(agentPane, dir, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := exec.Command("tmux", "respawn-pane", "-k", "-t", agentPane, "sleep 30").Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	d := Detector{Profiles: []AgentProfile{Claude{Command: "sleep"}}}
+	var live LiveInfo
+	for range 50 {
+		if live = d.Detect("pr-test", "feat"); live.PaneID != "" {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !live.Exists || live.PaneID != agentPane {
+		t.Errorf("Detect = %+v, want agent pane %s", live, agentPane)
+	}
+
+	if live := d.Detect("pr-test", "missing"); live.Exists {
+		t.Errorf("Detect on missing window = %+v", live)
+	}
+}
+
+// TestDetect_Exited tags a pane whose agent has already exited: once back at
+// its shell, and once dead with remain-on-exit.
+func TestDetect_Exited(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	t.Setenv("TMUX", "")
+	t.Cleanup(func() { exec.Command("tmux", "kill-server").Run() })
+
+	dir := t.TempDir()
+	if err := tmux.CreateSession("pr-test", dir); err != nil {
+		t.Fatal(err)
+	}
+	pane, err := tmux.NewWindow("pr-test", "feat", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmux.TagAgentPane(pane); err != nil {
+		t.Fatal(err)
+	}
+
+	d := Detector{}
+	wait := func(cmd ...string) LiveInfo {
+		t.Helper()
+		if err := exec.Command("tmux", cmd...).Run(); err != nil {
+			t.Fatal(err)
+		}
+		var live LiveInfo
+		for range 50 {
+			if live = d.Detect("pr-test", "feat"); live.Status == StatusExited {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		return live
+	}
+
+	if live := wait("respawn-pane", "-k", "-t", pane, "sh"); live.Status != StatusExited || live.PaneID != pane {
+		t.Errorf("pane at a shell: %+v", live)
+	}
+	if err := exec.Command("tmux", "set-option", "-p", "-t", pane, "remain-on-exit", "on").Run(); err != nil {
+		t.Fatal(err)
+	}
+	live := wait("respawn-pane", "-k", "-t", pane, "true")
+	if live.Status != StatusExited || !live.HasClaude {
+		t.Errorf("dead pane: %+v", live)
+	}
+}
+
+func TestDetector_Fake(t *testing.T) {
+	m := mux.NewFake()
+	m.EnsureSession("pr-app", "/repo")
+	editor, _ := m.NewWindow("pr-app", "feat", "/wt")
+	agent, _ := m.SplitPane(editor, "/wt", true, "")
+	m.Update(editor, func(p *mux.Pane) { p.Command = "nvim" })
+	m.Update(agent, func(p *mux.Pane) { p.Command = "claude"; p.Agent = true })
+
+	d := Detector{Mux: m, Debounce: Debounce{Hysteresis: 1}}
+	steps := []struct {
+		name   string
+		screen string
+		update func(*mux.Pane)
+		want   AgentStatus
+	}{
+		{"idle", "Claude Code\n\n❯ \n? for shortcuts", nil, StatusIdle},
+		{"busy", "✢ Thinking… (esc to interrupt)\n\n❯ \n? for shortcuts", nil, StatusBusy},
+		{"back at the shell", "$ ", func(p *mux.Pane) { p.Command = "zsh" }, StatusExited},
+	}
+	for _, st := range steps {
+		m.SetScreen(agent, st.screen)
+		if st.update != nil {
+			m.Update(agent, st.update)
+		}
+		live := d.Detect("pr-app", "feat")
+		if !live.Exists || live.Status != st.want || live.PaneID != agent {
+			t.Errorf("%s: got %+v, want %v in %s", st.name, live, st.want, agent)
 		}
 	}
+
+	// An unreadable multiplexer is no reason to think the window is gone
+	m.SnapshotErr = errors.New("server unreachable")
+	if live := d.Detect("pr-app", "feat"); !live.Exists || live.Status != StatusUnknown {
+		t.Errorf("unreadable: got %+v, want unknown", live)
+	}
+	m.SnapshotErr = nil
+
+	m.KillWindow("pr-app", "feat")
+	if live := d.Detect("pr-app", "feat"); live.Exists {
+		t.Errorf("closed window: got %+v", live)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	titles := map[string]string{"%1": "✳ Refactor", "%2": "✳ Fix tests"}
+	tests := []struct {
+		name  string
+		infos []LiveInfo
+		want  string // headline pane
+	}{
+		{
+			"waiting beats busy",
+			[]LiveInfo{
+				{PaneID: "%1", HasClaude: true, Status: StatusBusy},
+				{PaneID: "%2", HasClaude: true, Status: StatusWaiting},
+			},
+			"%2",
+		},
+		{
+			"waiting beats error beats exited",
+			[]LiveInfo{
+				{PaneID: "%1", HasClaude: true, Status: StatusExited},
+				{PaneID: "%2", HasClaude: true, Status: StatusError},
+			},
+			"%2",
+		},
+		{
+			"exited beats busy",
+			[]LiveInfo{
+				{PaneID: "%1", HasClaude: true, Status: StatusBusy},
+				{PaneID: "%2", HasClaude: true, Status: StatusExited},
+			},
+			"%2",
+		},
+		{
+			"busy beats idle",
+			[]LiveInfo{
+				{PaneID: "%1", HasClaude: true, Status: StatusIdle},
+				{PaneID: "%2", HasClaude: true, Status: StatusBusy},
+			},
+			"%2",
+		},
+		{
+			"idle beats unknown",
+			[]LiveInfo{
+				{PaneID: "%1", Status: StatusUnknown},
+				{PaneID: "%2", HasClaude: true, Status: StatusIdle},
+			},
+			"%2",
+		},
+		{
+			"tie goes to the claude UI",
+			[]LiveInfo{
+				{PaneID: "%1", Status: StatusIdle},
+				{PaneID: "%2", HasClaude: true, Status: StatusIdle},
+			},
+			"%2",
+		},
+		{
+			"then pane order",
+			[]LiveInfo{
+				{PaneID: "%1", HasClaude: true, Status: StatusWaiting},
+				{PaneID: "%2", HasClaude: true, Status: StatusWaiting},
+			},
+			"%1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := aggregate(tt.infos, titles)
+			if got.PaneID != tt.want {
+				t.Errorf("headline = %s, want %s", got.PaneID, tt.want)
+			}
+			if len(got.Panes) != len(tt.infos) {
+				t.Fatalf("got %d panes, want %d", len(got.Panes), len(tt.infos))
+			}
+			for i, p := range got.Panes {
+				in := tt.infos[i]
+				if p.ID != in.PaneID || p.Status != in.Status || p.HasClaude != in.HasClaude || p.Title != titles[in.PaneID] {
+					t.Errorf("pane %d = %+v, want %+v", i, p, in)
+				}
+			}
+		})
+	}
+}
+
+func TestClassifyPaneOutput_Error(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   AgentStatus
+	}{
+		{"api error", "⏺ Working on it\n  ⎿  API Error: 529 {\"type\":\"overloaded_error\"}\n\n❯ \n? for shortcuts", StatusError},
+		{"usage limit", "Claude usage limit reached. Your limit will reset at 5pm\n\n❯ \n", StatusError},
+		{"crash", "node:internal/process\nError: Cannot find module 'x'\n    at Module._resolveFilename\n", StatusError},
+		{"retrying is busy", "API Error (529) · Retrying in 5 seconds\n✢ Thinking… (esc to interrupt)\n", StatusBusy},
+		{"indented error in tool output", "Claude Code\n  ⎿  Error: tests failed\n\n❯ \n? for shortcuts", StatusIdle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _ := classifyPaneOutput(tt.output); status != tt.want {
+				t.Errorf("got %v, want %v", status, tt.want)
+			}
+		})
+	}
 }
@@ -2,13 +2,16 @@
 package session
 
 import (
-	"bufio"
 	"encoding/json"
-	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
+// metaLines is how many lines at the start of a session file metadata is
+// read from.
+const metaLines = 20
+
 // sessionMeta holds metadata extracted from the first few lines of a JSONL session file.
 type sessionMeta struct {
 	ID          string
@@ -16,63 +19,42 @@ type sessionMeta struct {
 	FirstPrompt string // 80-char truncated for list labels
 	FullPrompt  string // 4000-char version for preview
 	GitBranch   string
+	Started     time.Time // timestamp of the first entry that has one
 }
 
-// parseJSONLMeta reads the first 10 lines of a JSONL session file and extracts metadata.
-// Returns nil if cwd doesn't match worktreePath (filters out duplicated parent sessions).
-func parseJSONLMeta(filePath, worktreePath string) (*sessionMeta, error) {
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
+// add takes what metadata it can from one line of a session file. Fields
+// already set are kept.
+func (meta *sessionMeta) add(line []byte) {
+	var entry struct {
+		Type      string          `json:"type"`
+		CWD       string          `json:"cwd"`
+		Message   json.RawMessage `json:"message"`
+		GitBranch string          `json:"gitBranch"`
+		Timestamp time.Time       `json:"timestamp"`
 	}
-	defer f.Close()
-
-	scanner := bufio.NewScanner(f)
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // 1MB buffer
-
-	meta := &sessionMeta{
-		ID: strings.TrimSuffix(filepath.Base(filePath), ".jsonl"),
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return
 	}
 
-	for i := 0; i < 20 && scanner.Scan(); i++ {
-		line := scanner.Bytes()
-
-		var entry struct {
-			Type      string          `json:"type"`
-			CWD       string          `json:"cwd"`
-			Message   json.RawMessage `json:"message"`
-			GitBranch string          `json:"gitBranch"`
-		}
-		if err := json.Unmarshal(line, &entry); err != nil {
-			continue
-		}
-
-		if entry.CWD != "" && meta.CWD == "" {
-			meta.CWD = entry.CWD
-		}
-
-		if entry.GitBranch != "" && meta.GitBranch == "" {
-			meta.GitBranch = entry.GitBranch
-		}
-
-		if entry.Type == "user" && meta.FirstPrompt == "" {
-			short, full := extractMessageText(entry.Message)
-			if short != "" && !isBoilerplate(short) {
-				meta.FirstPrompt = short
-				meta.FullPrompt = full
-			}
-		}
+	if entry.CWD != "" && meta.CWD == "" {
+		meta.CWD = entry.CWD
 	}
 
-	if meta.CWD == "" {
-		return nil, nil
+	if entry.GitBranch != "" && meta.GitBranch == "" {
+		meta.GitBranch = entry.GitBranch
 	}
 
-	if !pathsMatch(meta.CWD, worktreePath) {
-		return nil, nil
+	if !entry.Timestamp.IsZero() && meta.Started.IsZero() {
+		meta.Started = entry.Timestamp
 	}
 
-	return meta, nil
+	if entry.Type == "user" && meta.FirstPrompt == "" {
+		short, full := extractMessageText(entry.Message)
+		if short != "" && !isBoilerplate(short) {
+			meta.FirstPrompt = short
+			meta.FullPrompt = full
+		}
+	}
 }
 
 // extractMessageText pulls display text from a Claude JSONL message field.
@@ -3,7 +3,9 @@ package session
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestExtractMessageText_String(t *testing.T) {
@@ -74,31 +76,28 @@ func TestPathsMatch(t *testing.T) {
 	}
 }
 
-func TestParseJSONLMeta(t *testing.T) {
-	dir := t.TempDir()
-	worktreePath := "/Users/grins/git/thegrid/.trees/dev-1301"
+// readMeta feeds the lines of a session file through sessionMeta.add, as
+// the index does.
+func readMeta(id, content string) sessionMeta {
+	meta := sessionMeta{ID: id}
+	for _, line := range strings.Split(content, "\n") {
+		if line != "" {
+			meta.add([]byte(line))
+		}
+	}
+	return meta
+}
 
+func TestSessionMeta(t *testing.T) {
 	// Real Claude JSONL format: message is {role, content}
-	content := `{"type":"system","cwd":"/Users/grins/git/thegrid/.trees/dev-1301","gitBranch":"dev-1301","content":"init"}
-{"type":"user","cwd":"/Users/grins/git/thegrid/.trees/dev-1301","message":{"role":"user","content":"Fix the tooltip positioning bug"}}
+	meta := readMeta("abc123", `{"type":"system","cwd":"/Users/grins/git/thegrid/.trees/dev-1301","gitBranch":"dev-1301","content":"init"}
+{"type":"user","cwd":"/Users/grins/git/thegrid/.trees/dev-1301","timestamp":"2025-06-01T10:00:00Z","message":{"role":"user","content":"Fix the tooltip positioning bug"}}
 {"type":"assistant","cwd":"/Users/grins/git/thegrid/.trees/dev-1301","message":{"role":"assistant","content":"I'll help fix that."}}
-`
-	path := filepath.Join(dir, "abc123.jsonl")
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-		t.Fatal(err)
-	}
-
-	meta, err := parseJSONLMeta(path, worktreePath)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if meta == nil {
-		t.Fatal("expected non-nil meta")
-	}
+`)
 	if meta.ID != "abc123" {
 		t.Errorf("ID = %q, want abc123", meta.ID)
 	}
-	if meta.CWD != worktreePath {
+	if meta.CWD != "/Users/grins/git/thegrid/.trees/dev-1301" {
 		t.Errorf("CWD = %q", meta.CWD)
 	}
 	if meta.FirstPrompt != "Fix the tooltip positioning bug" {
@@ -107,45 +106,41 @@ func TestParseJSONLMeta(t *testing.T) {
 	if meta.GitBranch != "dev-1301" {
 		t.Errorf("GitBranch = %q", meta.GitBranch)
 	}
+	if want := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC); !meta.Started.Equal(want) {
+		t.Errorf("Started = %v, want %v", meta.Started, want)
+	}
 }
 
-func TestParseJSONLMeta_WrongCWD(t *testing.T) {
-	dir := t.TempDir()
-	content := `{"type":"system","cwd":"/Users/grins/git/thegrid","content":"init"}
-{"type":"user","cwd":"/Users/grins/git/thegrid","message":{"role":"user","content":"something"}}
-`
-	path := filepath.Join(dir, "wrong.jsonl")
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-		t.Fatal(err)
+func TestSessionMeta_ArrayMessage(t *testing.T) {
+	meta := readMeta("arr", `{"type":"system","cwd":"/tmp/test","content":"init"}
+{"type":"user","cwd":"/tmp/test","message":{"role":"user","content":[{"type":"text","text":"Hello world"}]}}
+`)
+	if meta.FirstPrompt != "Hello world" {
+		t.Errorf("FirstPrompt = %q", meta.FirstPrompt)
 	}
+}
 
-	meta, err := parseJSONLMeta(path, "/Users/grins/git/thegrid/.trees/dev-1301")
-	if err != nil {
+func TestListSessions_WrongCWD(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	wt := "/Users/grins/git/thegrid/.trees/dev-1301"
+	dir := ProjectDir(wt)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
 		t.Fatal(err)
 	}
-	if meta != nil {
-		t.Error("expected nil for wrong cwd")
-	}
-}
-
-func TestParseJSONLMeta_ArrayMessage(t *testing.T) {
-	dir := t.TempDir()
-	content := `{"type":"system","cwd":"/tmp/test","content":"init"}
-{"type":"user","cwd":"/tmp/test","message":{"role":"user","content":[{"type":"text","text":"Hello world"}]}}
+	// A session of the parent repo, duplicated into the worktree's project dir
+	content := `{"type":"system","cwd":"/Users/grins/git/thegrid","content":"init"}
+{"type":"user","cwd":"/Users/grins/git/thegrid","message":{"role":"user","content":"something"}}
 `
-	path := filepath.Join(dir, "arr.jsonl")
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+	if err := os.WriteFile(filepath.Join(dir, "wrong.jsonl"), []byte(content), 0o644); err != nil {
 		t.Fatal(err)
 	}
 
-	meta, err := parseJSONLMeta(path, "/tmp/test")
+	x := OpenIndex(filepath.Join(t.TempDir(), "sessions.json"))
+	sessions, err := x.ListSessions(wt)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if meta == nil {
-		t.Fatal("expected non-nil meta")
-	}
-	if meta.FirstPrompt != "Hello world" {
-		t.Errorf("FirstPrompt = %q", meta.FirstPrompt)
+	if len(sessions) != 0 {
+		t.Errorf("sessions = %+v, want none for the wrong cwd", sessions)
 	}
 }
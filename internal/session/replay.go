@@ -0,0 +1,108 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A recording is a timed sequence of pane captures with the status expected
+// after each, stored as JSON lines: a RecordingHeader, then one Frame per
+// line. `parkranger record` writes them from a live pane; replaying them
+// through a Detector checks the whole status timeline, debouncing included.
+
+// RecordingHeader is the first line of a recording.
+type RecordingHeader struct {
+	Agent        string    `json:"agent,omitempty"`  // profile the pane ran; claude if empty
+	Target       string    `json:"target,omitempty"` // where it was recorded
+	RecordedAt   time.Time `json:"recorded_at,omitzero"`
+	Hysteresis   int       `json:"hysteresis,omitempty"`     // Debounce.Hysteresis during recording
+	QuietAfterMS int64     `json:"quiet_after_ms,omitempty"` // Debounce.QuietAfter during recording
+}
+
+// Debounce returns the debounce settings the recording was made with.
+func (h RecordingHeader) Debounce() Debounce {
+	return Debounce{Hysteresis: h.Hysteresis, QuietAfter: time.Duration(h.QuietAfterMS) * time.Millisecond}
+}
+
+// Frame is one capture in a recording.
+type Frame struct {
+	AtMS   int64  `json:"at_ms"`          // offset from the start of the recording
+	Screen string `json:"screen"`         // capture-pane -e output
+	Want   string `json:"want,omitempty"` // expected status after this frame; unchecked if empty
+}
+
+// Recording is a parsed recording file.
+type Recording struct {
+	Header RecordingHeader
+	Frames []Frame
+}
+
+// ReadRecording parses a recording.
+func ReadRecording(r io.Reader) (Recording, error) {
+	var rec Recording
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&rec.Header); err != nil {
+		return rec, fmt.Errorf("recording header: %w", err)
+	}
+	for {
+		var f Frame
+		err := dec.Decode(&f)
+		if errors.Is(err, io.EOF) {
+			return rec, nil
+		}
+		if err != nil {
+			return rec, fmt.Errorf("frame %d: %w", len(rec.Frames), err)
+		}
+		if f.Want != "" {
+			if _, err := ParseStatus(f.Want); err != nil {
+				return rec, fmt.Errorf("frame %d: %w", len(rec.Frames), err)
+			}
+		}
+		rec.Frames = append(rec.Frames, f)
+	}
+}
+
+// RecordingWriter writes a recording as frames arrive.
+type RecordingWriter struct {
+	enc *json.Encoder
+}
+
+// NewRecordingWriter writes the header and returns a writer for the frames.
+func NewRecordingWriter(w io.Writer, h RecordingHeader) (*RecordingWriter, error) {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(h); err != nil {
+		return nil, err
+	}
+	return &RecordingWriter{enc: enc}, nil
+}
+
+// WriteFrame appends a frame.
+func (rw *RecordingWriter) WriteFrame(f Frame) error {
+	return rw.enc.Encode(f)
+}
+
+// replayPane is the pane ID replayed frames are tracked under.
+const replayPane = "%replay"
+
+// Replay feeds recorded frames of one pane running profile through the
+// detector, as if each had been captured at its offset, and returns what
+// the detector reports after each. Successive calls continue the same
+// timeline, so a recorder can replay frames as it captures them.
+func (d *Detector) Replay(frames []Frame, profile AgentProfile) []LiveInfo {
+	tr, ok := d.trackers[replayPane]
+	if !ok {
+		tr = &Tracker{Debounce: d.debounce()}
+		d.trackers = map[string]*Tracker{replayPane: tr}
+	}
+	infos := make([]LiveInfo, len(frames))
+	for i, f := range frames {
+		now := time.UnixMilli(f.AtMS)
+		info := observePane(now, tr, agentPane{id: replayPane, profile: profile}, f.Screen)
+		infos[i] = aggregate([]LiveInfo{info}, nil)
+	}
+	return infos
+}
@@ -0,0 +1,78 @@
+package session
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReplay replays every recording in testdata/replay through a Detector
+// and checks the status after each frame. The current files are synthetic
+// (see testdata/replay/README.md); record real ones from a live pane with
+// `parkranger record <target>`, then correct the "want" of any frame the
+// detector got wrong.
+func TestReplay(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "replay", "*.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no recordings in testdata/replay")
+	}
+
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".jsonl"), func(t *testing.T) {
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			rec, err := ReadRecording(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := Detector{Debounce: rec.Header.Debounce()}
+			got := d.Replay(rec.Frames, Claude{})
+			for i, frame := range rec.Frames {
+				if frame.Want != "" && got[i].Status.String() != frame.Want {
+					t.Errorf("frame %d (%dms): got %v, want %s\n%s", i, frame.AtMS, got[i].Status, frame.Want, got[i].PaneContent)
+				}
+			}
+		})
+	}
+}
+
+func TestRecording_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewRecordingWriter(&buf, RecordingHeader{Agent: "claude", Hysteresis: 3, QuietAfterMS: 5000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := []Frame{
+		{AtMS: 0, Screen: "\x1b[1mClaude Code\x1b[0m\n❯ \n", Want: "idle"},
+		{AtMS: 500, Screen: "<html> & more\n"},
+	}
+	for _, f := range frames {
+		if err := w.WriteFrame(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec, err := ReadRecording(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Header.Debounce().QuietAfter.Seconds() != 5 || rec.Header.Agent != "claude" {
+		t.Errorf("header = %+v", rec.Header)
+	}
+	if len(rec.Frames) != 2 || rec.Frames[0] != frames[0] || rec.Frames[1] != frames[1] {
+		t.Errorf("frames = %+v", rec.Frames)
+	}
+
+	if _, err := ReadRecording(strings.NewReader("{}\n{\"at_ms\":0,\"want\":\"sleepy\"}\n")); err == nil {
+		t.Error("expected an error for an unknown status")
+	}
+}
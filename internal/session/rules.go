@@ -0,0 +1,206 @@
+package session
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/grins/parkranger/internal/config"
+)
+
+// claudeRules is the built-in Claude Code rule file.
+//
+//go:embed claude_rules.toml
+var claudeRules string
+
+// RuleSet classifies a screen by an ordered list of rules: the first rule
+// whose conditions all match decides the status.
+type RuleSet struct {
+	rules []rule
+}
+
+type rule struct {
+	source   config.Rule
+	name     string
+	status   AgentStatus
+	hasAgent bool
+	when     []condition
+}
+
+type condition struct {
+	re          *regexp.Regexp
+	bottom      int
+	highlighted bool
+}
+
+// Match is the outcome of classifying a screen: which rule fired and what
+// each of its conditions matched.
+type Match struct {
+	Index    int // position of the rule in the set; -1 if none matched
+	Rule     string
+	Status   AgentStatus
+	HasAgent bool
+	When     []config.Condition // the rule's conditions
+	Evidence []string           // matched text, one per condition
+}
+
+// CompileRules compiles config rules, in order.
+func CompileRules(rules []config.Rule) (*RuleSet, error) {
+	rs := &RuleSet{}
+	for i, r := range rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i)
+		}
+		status, err := ParseStatus(r.Status)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if len(r.When) == 0 {
+			return nil, fmt.Errorf("%s: no conditions", name)
+		}
+		compiled := rule{source: r, name: name, status: status, hasAgent: r.AgentVisible()}
+		for j, c := range r.When {
+			flags := "(?m)"
+			if c.IgnoreCase {
+				flags = "(?mi)"
+			}
+			re, err := regexp.Compile(flags + c.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s condition %d: %w", name, j, err)
+			}
+			compiled.when = append(compiled.when, condition{re: re, bottom: c.Bottom, highlighted: c.Highlighted})
+		}
+		rs.rules = append(rs.rules, compiled)
+	}
+	return rs, nil
+}
+
+// BuiltinRules returns the built-in Claude Code rules.
+func BuiltinRules() config.RuleFile {
+	f, err := config.ParseRules(claudeRules)
+	if err != nil {
+		panic("session: built-in rules: " + err.Error())
+	}
+	return f
+}
+
+// builtinRuleSet is BuiltinRules compiled once.
+var builtinRuleSet = sync.OnceValue(func() *RuleSet {
+	rs, err := CompileRules(BuiltinRules().Rules)
+	if err != nil {
+		panic("session: built-in rules: " + err.Error())
+	}
+	return rs
+})
+
+// ClaudeRules compiles a user rule file into the rule set Claude panes are
+// classified with: the user's rules first, then the built-in ones unless
+// the file drops them.
+func ClaudeRules(f config.RuleFile) (*RuleSet, error) {
+	rules := f.Rules
+	if f.KeepBuiltin() {
+		rules = append(rules[:len(rules):len(rules)], BuiltinRules().Rules...)
+	}
+	return CompileRules(rules)
+}
+
+// Classify returns the status of the first matching rule, or unknown (no
+// agent) if none matches.
+func (rs *RuleSet) Classify(sc Screen) (AgentStatus, bool) {
+	m := rs.Explain(sc)
+	return m.Status, m.HasAgent
+}
+
+// Explain classifies the screen and reports which rule fired.
+func (rs *RuleSet) Explain(sc Screen) Match {
+	// tmux capture-pane may end in blank rows; regions count from the last
+	// non-blank one.
+	lines := make([]string, len(sc.Lines))
+	for i := range sc.Lines {
+		lines[i] = sc.LineText(i)
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	end := len(lines)
+	if end == 0 {
+		return Match{Index: -1}
+	}
+
+	texts := make(map[int]string) // region text by bottom
+	region := func(bottom int) string {
+		if t, ok := texts[bottom]; ok {
+			return t
+		}
+		r := lines
+		if bottom > 0 {
+			r = bottomN(lines, bottom)
+		}
+		texts[bottom] = strings.Join(r, "\n")
+		return texts[bottom]
+	}
+
+	for i, r := range rs.rules {
+		evidence := make([]string, 0, len(r.when))
+		for _, c := range r.when {
+			var found string
+			var ok bool
+			if c.highlighted {
+				found, ok = c.matchHighlighted(sc, regionStart(end, c.bottom), end)
+			} else if loc := c.re.FindStringIndex(region(c.bottom)); loc != nil {
+				found, ok = region(c.bottom)[loc[0]:loc[1]], true
+			}
+			if !ok {
+				break
+			}
+			evidence = append(evidence, found)
+		}
+		if len(evidence) == len(r.when) {
+			return Match{Index: i, Rule: r.name, Status: r.status, HasAgent: r.hasAgent, When: r.source.When, Evidence: evidence}
+		}
+	}
+	return Match{Index: -1}
+}
+
+// regionStart is the first line of the bottom n of end lines (all if n is 0).
+func regionStart(end, bottom int) int {
+	if bottom <= 0 || bottom >= end {
+		return 0
+	}
+	return end - bottom
+}
+
+// matchHighlighted finds a line in [start, end) whose match is drawn in a
+// colour or reversed. Box borders don't count: they are coloured around
+// plain text too.
+func (c condition) matchHighlighted(sc Screen, start, end int) (string, bool) {
+	for i := start; i < end; i++ {
+		loc := c.re.FindStringIndex(sc.LineText(i))
+		if loc == nil {
+			continue
+		}
+		off := 0
+		for _, sp := range sc.Lines[i] {
+			spStart := off
+			off += len(sp.Text)
+			if off <= loc[0] || spStart >= loc[1] {
+				continue
+			}
+			if strings.Trim(sp.Text, " │") != "" && (sp.Style.FG != "" || sp.Style.Reverse) {
+				return sc.LineText(i)[loc[0]:loc[1]], true
+			}
+		}
+	}
+	return "", false
+}
+
+// bottomN returns the last n elements of a string slice.
+func bottomN(lines []string, n int) []string {
+	if len(lines) <= n {
+		return lines
+	}
+	return lines[len(lines)-n:]
+}
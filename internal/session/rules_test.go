@@ -0,0 +1,98 @@
+package session
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grins/parkranger/internal/config"
+)
+
+func TestBuiltinRules_Valid(t *testing.T) {
+	if err := BuiltinRules().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRuleSet_Explain(t *testing.T) {
+	no := false
+	rs, err := CompileRules([]config.Rule{
+		{Name: "banner", Status: "idle", HasAgent: &no, When: []config.Condition{{Pattern: "WELCOME", IgnoreCase: true}}},
+		{Name: "prompt", Status: "waiting", When: []config.Condition{
+			{Pattern: `^\? `, Bottom: 1},
+			{Pattern: "tool", Bottom: 3},
+		}},
+		{Name: "working", Status: "busy", When: []config.Condition{{Pattern: "Working", Bottom: 2}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		in    string
+		index int
+		want  AgentStatus
+		agent bool
+	}{
+		{"case-insensitive", "welcome back\n> ", 0, StatusIdle, false},
+		{"all conditions", "run tool?\n? yes/no\n\n\n", 1, StatusWaiting, true},
+		{"one condition missing", "run it?\n? yes/no", -1, StatusUnknown, false},
+		{"outside the bottom region", "Working\na\nb", -1, StatusUnknown, false},
+		{"inside the bottom region", "a\nWorking\nb\n\n", 2, StatusBusy, true},
+		{"blank lines inside the region count", "Working\n\nb", -1, StatusUnknown, false},
+		{"empty", "\n\n", -1, StatusUnknown, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := rs.Explain(ParseANSI(tt.in))
+			if m.Index != tt.index || m.Status != tt.want || m.HasAgent != tt.agent {
+				t.Errorf("got rule %d %v/%v, want rule %d %v/%v", m.Index, m.Status, m.HasAgent, tt.index, tt.want, tt.agent)
+			}
+			if m.Index >= 0 && len(m.Evidence) != len(rs.rules[m.Index].when) {
+				t.Errorf("evidence = %q", m.Evidence)
+			}
+		})
+	}
+}
+
+func TestClaudeRules_UserFirst(t *testing.T) {
+	user := config.Rule{Name: "custom", Status: "busy", When: []config.Condition{{Pattern: "compacting"}}}
+	screen := ParseANSI("Claude Code\ncompacting\n❯ \n? for shortcuts  /help")
+
+	rs, err := ClaudeRules(config.RuleFile{Rules: []config.Rule{user}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := rs.Explain(screen); m.Rule != "custom" || m.Status != StatusBusy {
+		t.Errorf("user rule didn't win: %+v", m)
+	}
+	if m := rs.Explain(ParseANSI("Claude Code\n❯ \n? for shortcuts")); m.Rule != "prompt" {
+		t.Errorf("built-in rules dropped: %+v", m)
+	}
+
+	builtin := false
+	rs, err = ClaudeRules(config.RuleFile{Builtin: &builtin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := rs.Explain(screen); m.Index != -1 {
+		t.Errorf("builtin = false kept the built-in rules: %+v", m)
+	}
+}
+
+func TestCompileRules_Errors(t *testing.T) {
+	tests := []struct {
+		rule config.Rule
+		want string
+	}{
+		{config.Rule{Name: "a", Status: "sleepy", When: []config.Condition{{Pattern: "x"}}}, "unknown agent status"},
+		{config.Rule{Name: "b", Status: "idle"}, "no conditions"},
+		{config.Rule{Name: "c", Status: "idle", When: []config.Condition{{Pattern: "("}}}, "c condition 0"},
+	}
+	for _, tt := range tests {
+		_, err := CompileRules([]config.Rule{tt.rule})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("CompileRules(%s) = %v, want %q", tt.rule.Name, err, tt.want)
+		}
+	}
+}
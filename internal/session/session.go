@@ -3,7 +3,6 @@ package session
 import (
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 )
@@ -15,7 +14,9 @@ type Session struct {
 	FirstPrompt string // 80-char truncated for list labels
 	FullPrompt  string // 4000-char version for preview
 	ModTime     time.Time
+	Started     time.Time // first entry's timestamp; zero if unknown
 	GitBranch   string
+	Path        string // the session file
 }
 
 // EncodePath converts an absolute path to Claude's project directory encoding.
@@ -39,47 +40,8 @@ func ProjectDir(worktreePath string) string {
 // ListSessions finds Claude Code sessions that were actually run in the given worktree path.
 // It scans JSONL files in the project directory, filtering by cwd match.
 // Returns sessions sorted by ModTime descending (most recent first).
+// Metadata comes from the session index, so files unchanged since the last
+// listing are not read again; SaveIndex keeps it for the next process.
 func ListSessions(worktreePath string) ([]Session, error) {
-	dir := ProjectDir(worktreePath)
-
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	var sessions []Session
-	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
-			continue
-		}
-
-		filePath := filepath.Join(dir, e.Name())
-		meta, err := parseJSONLMeta(filePath, worktreePath)
-		if err != nil || meta == nil {
-			continue
-		}
-
-		info, err := e.Info()
-		if err != nil {
-			continue
-		}
-
-		sessions = append(sessions, Session{
-			ID:          meta.ID,
-			CWD:         meta.CWD,
-			FirstPrompt: meta.FirstPrompt,
-			FullPrompt:  meta.FullPrompt,
-			ModTime:     info.ModTime(),
-			GitBranch:   meta.GitBranch,
-		})
-	}
-
-	sort.Slice(sessions, func(i, j int) bool {
-		return sessions[i].ModTime.After(sessions[j].ModTime)
-	})
-
-	return sessions, nil
+	return defaultIndex().ListSessions(worktreePath)
 }
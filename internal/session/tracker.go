@@ -0,0 +1,93 @@
+package session
+
+import "time"
+
+// Debounce configures how a Tracker smooths raw classifications.
+type Debounce struct {
+	// Hysteresis is how many consecutive polls must read idle (or an
+	// unrecognised screen) before a Busy pane follows; 1 or less follows at
+	// the first one. Waiting, errors and exits are never delayed.
+	Hysteresis int
+	// QuietAfter turns an unrecognised screen into Idle once its content
+	// has been unchanged this long, if the agent's UI was ever recognised;
+	// 0 disables the timer.
+	QuietAfter time.Duration
+}
+
+// DefaultDebounce is used when a Detector's Debounce is left zero.
+var DefaultDebounce = Debounce{Hysteresis: 2, QuietAfter: 10 * time.Second}
+
+// Observation is one poll's raw reading of a pane.
+type Observation struct {
+	Status   AgentStatus // what the profile classified the screen as
+	HasAgent bool        // the profile recognised the agent's UI
+	Hash     [32]byte    // hash of the screen text, for change detection
+}
+
+// Tracker is the status state machine of one pane. Each poll's raw
+// observation goes through Observe, which upgrades changing unrecognised
+// screens to Busy, holds Busy through short lulls, falls back to Idle after
+// a quiet spell and keeps the time the current status was entered.
+type Tracker struct {
+	Debounce Debounce
+
+	status   AgentStatus
+	hasAgent bool
+	since    time.Time // when status was entered
+
+	hash      [32]byte
+	hashed    bool
+	changed   time.Time // last content change
+	agentSeen bool      // a rule recognised the agent UI at some point
+	pending   int       // consecutive settled polls while Busy
+}
+
+// Status returns the current status, whether the agent UI is on screen and
+// when the status was entered.
+func (t *Tracker) Status() (AgentStatus, bool, time.Time) {
+	return t.status, t.hasAgent, t.since
+}
+
+// Observe feeds one poll's observation taken at now and reports whether the
+// status changed. It has no side effects beyond the tracker.
+func (t *Tracker) Observe(now time.Time, o Observation) bool {
+	changed := t.hashed && o.Hash != t.hash
+	if !t.hashed || changed {
+		t.changed = now
+	}
+	t.hash, t.hashed = o.Hash, true
+	if o.HasAgent {
+		t.agentSeen = true
+	}
+
+	next, agent := o.Status, o.HasAgent
+	if next == StatusUnknown {
+		switch {
+		case changed:
+			// Unrecognised but changing: the agent is likely streaming output
+			next, agent = StatusBusy, true
+		case t.Debounce.QuietAfter > 0 && t.agentSeen && now.Sub(t.changed) >= t.Debounce.QuietAfter:
+			next, agent = StatusIdle, true
+		}
+	}
+
+	// Hysteresis: stay Busy until enough polls in a row say it settled. A
+	// prompt for the user or an exit is shown as soon as it is seen.
+	if t.status == StatusBusy && (next == StatusIdle || next == StatusUnknown) {
+		t.pending++
+		if t.pending < t.Debounce.Hysteresis {
+			next, agent = StatusBusy, t.hasAgent
+		} else {
+			t.pending = 0
+		}
+	} else {
+		t.pending = 0
+	}
+
+	t.hasAgent = agent
+	if next == t.status && !t.since.IsZero() {
+		return false
+	}
+	t.status, t.since = next, now
+	return true
+}
@@ -0,0 +1,115 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTracker_Observe(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	screen := func(n byte) [32]byte { return [32]byte{n} }
+
+	type step struct {
+		after  time.Duration // since t0
+		obs    Observation
+		want   AgentStatus
+		agent  bool
+		change bool // Observe reports a change
+	}
+	tests := []struct {
+		name     string
+		debounce Debounce
+		steps    []step
+	}{
+		{
+			"changing unknown screen is busy",
+			Debounce{Hysteresis: 1},
+			[]step{
+				{0, Observation{Hash: screen(1)}, StatusUnknown, false, true},
+				{time.Second, Observation{Hash: screen(2)}, StatusBusy, true, true},
+				{2 * time.Second, Observation{Hash: screen(2)}, StatusUnknown, false, true},
+			},
+		},
+		{
+			"hysteresis holds busy through a lull",
+			Debounce{Hysteresis: 3},
+			[]step{
+				{0, Observation{Status: StatusBusy, HasAgent: true, Hash: screen(1)}, StatusBusy, true, true},
+				{1 * time.Second, Observation{Status: StatusIdle, HasAgent: true, Hash: screen(2)}, StatusBusy, true, false},
+				{2 * time.Second, Observation{Status: StatusBusy, HasAgent: true, Hash: screen(3)}, StatusBusy, true, false},
+				{3 * time.Second, Observation{Status: StatusIdle, HasAgent: true, Hash: screen(4)}, StatusBusy, true, false},
+				{4 * time.Second, Observation{Status: StatusIdle, HasAgent: true, Hash: screen(4)}, StatusBusy, true, false},
+				{5 * time.Second, Observation{Status: StatusIdle, HasAgent: true, Hash: screen(4)}, StatusIdle, true, true},
+			},
+		},
+		{
+			"waiting skips hysteresis",
+			Debounce{Hysteresis: 5},
+			[]step{
+				{0, Observation{Status: StatusBusy, HasAgent: true, Hash: screen(1)}, StatusBusy, true, true},
+				{time.Second, Observation{Status: StatusWaiting, HasAgent: true, Hash: screen(2)}, StatusWaiting, true, true},
+			},
+		},
+		{
+			"exiting skips hysteresis",
+			Debounce{Hysteresis: 5},
+			[]step{
+				{0, Observation{Status: StatusBusy, HasAgent: true, Hash: screen(1)}, StatusBusy, true, true},
+				{time.Second, Observation{Status: StatusExited, HasAgent: true, Hash: screen(2)}, StatusExited, true, true},
+			},
+		},
+		{
+			"quiet screen falls back to idle",
+			Debounce{Hysteresis: 1, QuietAfter: 10 * time.Second},
+			[]step{
+				{0, Observation{Status: StatusIdle, HasAgent: true, Hash: screen(1)}, StatusIdle, true, true},
+				{time.Second, Observation{Hash: screen(2)}, StatusBusy, true, true},
+				{2 * time.Second, Observation{Hash: screen(2)}, StatusUnknown, false, true},
+				{10 * time.Second, Observation{Hash: screen(2)}, StatusUnknown, false, false},
+				{11 * time.Second, Observation{Hash: screen(2)}, StatusIdle, true, true},
+			},
+		},
+		{
+			"no quiet idle without the agent UI",
+			Debounce{Hysteresis: 1, QuietAfter: time.Second},
+			[]step{
+				{0, Observation{Hash: screen(1)}, StatusUnknown, false, true},
+				{time.Minute, Observation{Hash: screen(1)}, StatusUnknown, false, false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := Tracker{Debounce: tt.debounce}
+			for i, s := range tt.steps {
+				changed := tr.Observe(t0.Add(s.after), s.obs)
+				status, agent, _ := tr.Status()
+				if status != s.want || agent != s.agent {
+					t.Fatalf("step %d: got %v/%v, want %v/%v", i, status, agent, s.want, s.agent)
+				}
+				if changed != s.change {
+					t.Fatalf("step %d: changed = %v, want %v", i, changed, s.change)
+				}
+			}
+		})
+	}
+}
+
+func TestTracker_TimeInState(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	tr := Tracker{Debounce: Debounce{Hysteresis: 1}}
+	tr.Observe(t0, Observation{Status: StatusBusy, HasAgent: true})
+	tr.Observe(t0.Add(time.Minute), Observation{Status: StatusBusy, HasAgent: true})
+
+	if _, _, since := tr.Status(); !since.Equal(t0) {
+		t.Errorf("since = %v, want the first poll", since)
+	}
+	if !tr.Observe(t0.Add(3*time.Minute), Observation{Status: StatusWaiting, HasAgent: true}) {
+		t.Fatal("busy → waiting not reported")
+	}
+	tr.Observe(t0.Add(7*time.Minute), Observation{Status: StatusWaiting, HasAgent: true})
+	if _, _, since := tr.Status(); !since.Equal(t0.Add(3 * time.Minute)) {
+		t.Errorf("since = %v, want the transition time", since)
+	}
+}
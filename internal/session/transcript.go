@@ -0,0 +1,459 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EntryKind is the type of a transcript line.
+type EntryKind int
+
+const (
+	EntryOther     EntryKind = iota // file snapshots, queue operations, …
+	EntryUser                       // a user message: a prompt or tool results
+	EntryAssistant                  // one content block of an assistant message
+	EntrySummary                    // a summary of the conversation up to LeafUUID
+	EntrySystem                     // a notice from Claude Code (compaction, hook output, …)
+)
+
+func (k EntryKind) String() string {
+	switch k {
+	case EntryUser:
+		return "user"
+	case EntryAssistant:
+		return "assistant"
+	case EntrySummary:
+		return "summary"
+	case EntrySystem:
+		return "system"
+	}
+	return "other"
+}
+
+// Entry is one line of a Claude Code JSONL transcript.
+type Entry struct {
+	Line       int // 1-based line number in the file
+	Kind       EntryKind
+	Type       string // the raw "type" field
+	UUID       string
+	ParentUUID string
+	Time       time.Time
+	Sidechain  bool // part of a subagent's (Task tool) conversation
+	Meta       bool // injected by Claude Code rather than typed by the user
+	CWD        string
+	GitBranch  string
+	Version    string // Claude Code version
+
+	// Messages (user and assistant)
+	MessageID string // API message ID; an assistant message spans one entry per block
+	Model     string
+	Blocks    []Block
+	Usage     Usage
+
+	// Summaries and system entries
+	Summary  string
+	LeafUUID string
+	Text     string // system content
+}
+
+// BlockKind is the type of a message content block.
+type BlockKind int
+
+const (
+	BlockOther BlockKind = iota
+	BlockText
+	BlockThinking
+	BlockToolUse
+	BlockToolResult
+	BlockImage
+)
+
+func (k BlockKind) String() string {
+	switch k {
+	case BlockText:
+		return "text"
+	case BlockThinking:
+		return "thinking"
+	case BlockToolUse:
+		return "tool_use"
+	case BlockToolResult:
+		return "tool_result"
+	case BlockImage:
+		return "image"
+	}
+	return "other"
+}
+
+// Block is a content block of a message.
+type Block struct {
+	Kind    BlockKind
+	Text    string          // text, thinking, or a tool result's text
+	ToolID  string          // tool_use ID, also on its tool_result
+	Tool    string          // tool_use: the tool's name
+	Input   json.RawMessage // tool_use: the tool's arguments
+	IsError bool            // tool_result: the tool failed
+}
+
+// Usage is the token usage of an API response.
+type Usage struct {
+	InputTokens         int `json:"input_tokens"`
+	OutputTokens        int `json:"output_tokens"`
+	CacheCreationTokens int `json:"cache_creation_input_tokens"`
+	CacheReadTokens     int `json:"cache_read_input_tokens"`
+}
+
+// Add returns the sum of two usages.
+func (u Usage) Add(o Usage) Usage {
+	return Usage{
+		InputTokens:         u.InputTokens + o.InputTokens,
+		OutputTokens:        u.OutputTokens + o.OutputTokens,
+		CacheCreationTokens: u.CacheCreationTokens + o.CacheCreationTokens,
+		CacheReadTokens:     u.CacheReadTokens + o.CacheReadTokens,
+	}
+}
+
+// sub returns u less o.
+func (u Usage) sub(o Usage) Usage {
+	return Usage{
+		InputTokens:         u.InputTokens - o.InputTokens,
+		OutputTokens:        u.OutputTokens - o.OutputTokens,
+		CacheCreationTokens: u.CacheCreationTokens - o.CacheCreationTokens,
+		CacheReadTokens:     u.CacheReadTokens - o.CacheReadTokens,
+	}
+}
+
+// LineError reports a transcript line that isn't valid JSON, such as the
+// half-written last line of a live session.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string { return fmt.Sprintf("line %d: %v", e.Line, e.Err) }
+func (e *LineError) Unwrap() error { return e.Err }
+
+// ReadTranscript streams the entries of a JSONL transcript. A malformed
+// line yields a *LineError and iteration goes on with the next line; a read
+// error is yielded last. Lines may be of any length.
+func ReadTranscript(r io.Reader) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		br := bufio.NewReader(r)
+		for n := 1; ; n++ {
+			line, err := br.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				e, perr := parseEntry(line)
+				e.Line = n
+				if perr != nil {
+					if !yield(Entry{Line: n}, &LineError{Line: n, Err: perr}) {
+						return
+					}
+				} else if !yield(e, nil) {
+					return
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(Entry{}, err)
+				return
+			}
+		}
+	}
+}
+
+// TranscriptEntries streams the entries of the transcript file at path.
+func TranscriptEntries(path string) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		f, err := os.Open(path)
+		if err != nil {
+			yield(Entry{}, err)
+			return
+		}
+		defer f.Close()
+		for e, err := range ReadTranscript(f) {
+			if !yield(e, err) {
+				return
+			}
+		}
+	}
+}
+
+// rawEntry is the JSON shape of a transcript line.
+type rawEntry struct {
+	Type        string          `json:"type"`
+	UUID        string          `json:"uuid"`
+	ParentUUID  string          `json:"parentUuid"`
+	Timestamp   time.Time       `json:"timestamp"`
+	IsSidechain bool            `json:"isSidechain"`
+	IsMeta      bool            `json:"isMeta"`
+	CWD         string          `json:"cwd"`
+	GitBranch   string          `json:"gitBranch"`
+	Version     string          `json:"version"`
+	Message     json.RawMessage `json:"message"`
+	Summary     string          `json:"summary"`
+	LeafUUID    string          `json:"leafUuid"`
+	Content     json.RawMessage `json:"content"`
+}
+
+type rawMessage struct {
+	ID      string          `json:"id"`
+	Model   string          `json:"model"`
+	Content json.RawMessage `json:"content"`
+	Usage   Usage           `json:"usage"`
+}
+
+type rawBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	Thinking  string          `json:"thinking"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+func parseEntry(line []byte) (Entry, error) {
+	var raw rawEntry
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return Entry{}, err
+	}
+	e := Entry{
+		Type:       raw.Type,
+		UUID:       raw.UUID,
+		ParentUUID: raw.ParentUUID,
+		Time:       raw.Timestamp,
+		Sidechain:  raw.IsSidechain,
+		Meta:       raw.IsMeta,
+		CWD:        raw.CWD,
+		GitBranch:  raw.GitBranch,
+		Version:    raw.Version,
+		Summary:    raw.Summary,
+		LeafUUID:   raw.LeafUUID,
+	}
+	switch raw.Type {
+	case "user":
+		e.Kind = EntryUser
+	case "assistant":
+		e.Kind = EntryAssistant
+	case "summary":
+		e.Kind = EntrySummary
+	case "system":
+		e.Kind = EntrySystem
+		e.Text = contentText(raw.Content)
+	}
+
+	if (e.Kind == EntryUser || e.Kind == EntryAssistant) && len(raw.Message) > 0 {
+		var msg rawMessage
+		if err := json.Unmarshal(raw.Message, &msg); err != nil {
+			return Entry{}, fmt.Errorf("message: %w", err)
+		}
+		e.MessageID, e.Model, e.Usage = msg.ID, msg.Model, msg.Usage
+		e.Blocks = parseBlocks(msg.Content)
+	}
+	return e, nil
+}
+
+// parseBlocks reads message content: a plain string or an array of blocks.
+func parseBlocks(content json.RawMessage) []Block {
+	var s string
+	if json.Unmarshal(content, &s) == nil {
+		return []Block{{Kind: BlockText, Text: s}}
+	}
+	var raws []rawBlock
+	if json.Unmarshal(content, &raws) != nil {
+		return nil
+	}
+	blocks := make([]Block, 0, len(raws))
+	for _, r := range raws {
+		b := Block{Kind: BlockOther}
+		switch r.Type {
+		case "text":
+			b.Kind, b.Text = BlockText, r.Text
+		case "thinking":
+			b.Kind, b.Text = BlockThinking, r.Thinking
+		case "redacted_thinking":
+			b.Kind = BlockThinking
+		case "tool_use":
+			b.Kind, b.ToolID, b.Tool, b.Input = BlockToolUse, r.ID, r.Name, r.Input
+		case "tool_result":
+			b.Kind, b.ToolID, b.IsError = BlockToolResult, r.ToolUseID, r.IsError
+			b.Text = contentText(r.Content)
+		case "image":
+			b.Kind = BlockImage
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+// contentText flattens content, a string or an array of blocks, to its
+// text.
+func contentText(content json.RawMessage) string {
+	var s string
+	if json.Unmarshal(content, &s) == nil {
+		return s
+	}
+	var parts []string
+	for _, b := range parseBlocks(content) {
+		if b.Kind == BlockText && b.Text != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Role is who a turn is from.
+type Role int
+
+const (
+	RoleUser Role = iota
+	RoleAssistant
+)
+
+func (r Role) String() string {
+	if r == RoleAssistant {
+		return "assistant"
+	}
+	return "user"
+}
+
+// Turn is one message of a conversation. The entries of one assistant
+// message, which Claude Code writes one per content block, are merged.
+type Turn struct {
+	Role      Role
+	UUID      string // of the turn's first entry
+	MessageID string
+	Time      time.Time
+	Sidechain bool
+	Meta      bool
+	Model     string
+	Blocks    []Block
+	Usage     Usage // assistant turns: the response's usage
+}
+
+// Text returns the turn's text blocks, joined by blank lines.
+func (t Turn) Text() string {
+	var parts []string
+	for _, b := range t.Blocks {
+		if b.Kind == BlockText && b.Text != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Transcript is a conversation read from a Claude Code session file.
+type Transcript struct {
+	ID        string // session ID: the file name without .jsonl
+	CWD       string // working directory of the first entry that has one
+	GitBranch string
+	Version   string
+	Start     time.Time
+	End       time.Time
+	Summaries []string // in file order; the last one describes the session best
+	Turns     []Turn
+	System    []Entry
+	Malformed int // lines that weren't valid JSON
+}
+
+// LoadTranscript reads a whole session file.
+func LoadTranscript(path string) (*Transcript, error) {
+	t := &Transcript{ID: strings.TrimSuffix(filepath.Base(path), ".jsonl")}
+	for e, err := range TranscriptEntries(path) {
+		var lerr *LineError
+		if errors.As(err, &lerr) {
+			t.Malformed++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		t.Add(e)
+	}
+	return t, nil
+}
+
+// Add appends an entry to the conversation.
+func (t *Transcript) Add(e Entry) {
+	if t.CWD == "" {
+		t.CWD = e.CWD
+	}
+	if t.GitBranch == "" {
+		t.GitBranch = e.GitBranch
+	}
+	if e.Version != "" {
+		t.Version = e.Version
+	}
+	if !e.Time.IsZero() {
+		if t.Start.IsZero() || e.Time.Before(t.Start) {
+			t.Start = e.Time
+		}
+		if e.Time.After(t.End) {
+			t.End = e.Time
+		}
+	}
+
+	switch e.Kind {
+	case EntrySummary:
+		t.Summaries = append(t.Summaries, e.Summary)
+	case EntrySystem:
+		t.System = append(t.System, e)
+	case EntryUser, EntryAssistant:
+		role := RoleUser
+		if e.Kind == EntryAssistant {
+			role = RoleAssistant
+		}
+		if n := len(t.Turns); n > 0 && role == RoleAssistant && e.MessageID != "" {
+			// Later entries of a message repeat its usage, updated
+			if last := &t.Turns[n-1]; last.MessageID == e.MessageID {
+				last.Blocks = append(last.Blocks, e.Blocks...)
+				last.Usage = e.Usage
+				return
+			}
+		}
+		t.Turns = append(t.Turns, Turn{
+			Role:      role,
+			UUID:      e.UUID,
+			MessageID: e.MessageID,
+			Time:      e.Time,
+			Sidechain: e.Sidechain,
+			Meta:      e.Meta,
+			Model:     e.Model,
+			Blocks:    e.Blocks,
+			Usage:     e.Usage,
+		})
+	}
+}
+
+// Usage returns the token usage of every response, sidechains included.
+func (t *Transcript) Usage() Usage {
+	var u Usage
+	for _, turn := range t.Turns {
+		u = u.Add(turn.Usage)
+	}
+	return u
+}
+
+// ToolResult returns the result block of the tool call with ID id.
+func (t *Transcript) ToolResult(id string) (Block, bool) {
+	for _, turn := range t.Turns {
+		for _, b := range turn.Blocks {
+			if b.Kind == BlockToolResult && b.ToolID == id {
+				return b, true
+			}
+		}
+	}
+	return Block{}, false
+}
@@ -0,0 +1,127 @@
+package session
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grins/parkranger/internal/config"
+)
+
+func TestLoadTranscript(t *testing.T) {
+	tr, err := LoadTranscript("testdata/transcript.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.ID != "transcript" || tr.CWD != "/work/app" || tr.GitBranch != "feat" || tr.Version != "1.0.81" {
+		t.Errorf("header = %q %q %q %q", tr.ID, tr.CWD, tr.GitBranch, tr.Version)
+	}
+	if want := time.Date(2025, 6, 1, 10, 1, 1, 0, time.UTC); !tr.End.Equal(want) || tr.End.Sub(tr.Start) != 61*time.Second {
+		t.Errorf("span = %s – %s", tr.Start, tr.End)
+	}
+	if !reflect.DeepEqual(tr.Summaries, []string{"Listing Go files"}) || len(tr.System) != 1 || tr.System[0].Text != "Conversation compacted" {
+		t.Errorf("summaries %q, system %+v", tr.Summaries, tr.System)
+	}
+	if tr.Malformed != 1 {
+		t.Errorf("malformed = %d, want the half-written last line", tr.Malformed)
+	}
+
+	var roles []string
+	for _, turn := range tr.Turns {
+		r := turn.Role.String()
+		if turn.Sidechain {
+			r += " (sidechain)"
+		}
+		if turn.Meta {
+			r += " (meta)"
+		}
+		roles = append(roles, r)
+	}
+	want := []string{"user", "assistant", "user", "user (sidechain)", "assistant (sidechain)", "user (sidechain)", "assistant", "user (meta)"}
+	if !reflect.DeepEqual(roles, want) {
+		t.Fatalf("turns = %q, want %q", roles, want)
+	}
+
+	// The thinking and tool_use entries of msg_1 are one turn with the
+	// final usage
+	first := tr.Turns[1]
+	if len(first.Blocks) != 2 || first.Blocks[0].Kind != BlockThinking || first.Blocks[0].Text != "Use ls." {
+		t.Errorf("blocks = %+v", first.Blocks)
+	}
+	if use := first.Blocks[1]; use.Kind != BlockToolUse || use.Tool != "Bash" || string(use.Input) != `{"command":"ls *.go"}` {
+		t.Errorf("tool use = %+v", use)
+	}
+	if first.Usage != (Usage{InputTokens: 10, OutputTokens: 42, CacheCreationTokens: 100, CacheReadTokens: 1000}) {
+		t.Errorf("usage = %+v", first.Usage)
+	}
+	if res, ok := tr.ToolResult("toolu_1"); !ok || res.Text != "main.go\nmain_test.go" || res.IsError {
+		t.Errorf("result = %+v, %v", res, ok)
+	}
+	if res, ok := tr.ToolResult("toolu_2"); !ok || res.Text != "No matches" || !res.IsError {
+		t.Errorf("sidechain result = %+v, %v", res, ok)
+	}
+	if got := tr.Turns[6].Text(); got != "There are two Go files." {
+		t.Errorf("text = %q", got)
+	}
+	if u := tr.Usage(); u.OutputTokens != 70 || u.CacheReadTokens != 2600 {
+		t.Errorf("total usage = %+v", u)
+	}
+}
+
+func TestReadTranscript(t *testing.T) {
+	in := "{\"type\":\"user\",\"message\":{\"content\":\"hi\"}}\n\nnot json\n" +
+		`{"type":"file-history-snapshot","messageId":"m"}` + "\n" +
+		`{"type":"user","message":{"content":"` + strings.Repeat("x", 2<<20) + `"}}`
+
+	var kinds []string
+	var lineErr *LineError
+	for e, err := range ReadTranscript(strings.NewReader(in)) {
+		if errors.As(err, &lineErr) {
+			kinds = append(kinds, "error")
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		kinds = append(kinds, e.Kind.String())
+		if e.Line == 5 && len(e.Blocks[0].Text) != 2<<20 {
+			t.Errorf("long line cut to %d bytes", len(e.Blocks[0].Text))
+		}
+	}
+	if want := []string{"user", "error", "other", "user"}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("kinds = %q, want %q", kinds, want)
+	}
+	if lineErr.Line != 3 {
+		t.Errorf("error on line %d, want 3", lineErr.Line)
+	}
+
+	// Stopping early is fine
+	for range ReadTranscript(strings.NewReader(in)) {
+		break
+	}
+}
+
+func TestReadUsage(t *testing.T) {
+	records, err := ReadUsage("testdata/transcript.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("records = %+v, want one for the quarter hour", records)
+	}
+	r := records[0]
+	if r.Responses != 3 || r.OutputTokens != 42+20+8 || r.Model != "claude-sonnet-4-20250514" || !r.Time.Equal(time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("record = %+v, want each message's last usage in the 10:00 slot", r)
+	}
+
+	price := func(model string) (config.Price, bool) {
+		return config.Price{Input: 1e6, Output: 1e6}, model == "claude-sonnet-4-20250514"
+	}
+	var total UsageTotal
+	total.Add(r, price)
+	total.Add(UsageRecord{Model: "other", Responses: 1, Usage: Usage{OutputTokens: 1}}, price)
+	if total.Responses != 4 || total.Unpriced != 1 || total.Cost != 33+70 || total.Tokens() != 2854 {
+		t.Errorf("total = %+v", total)
+	}
+}
@@ -0,0 +1,123 @@
+package session
+
+import (
+	"time"
+
+	"github.com/grins/parkranger/internal/config"
+)
+
+// UsageSlot is the time resolution of usage records: responses are summed
+// per model and quarter hour, which keeps the session index small however
+// long a session runs, and still lines up with every time zone's days.
+const UsageSlot = 15 * time.Minute
+
+// UsageRecord is the token usage of a session's responses from one model
+// that started in one UsageSlot.
+type UsageRecord struct {
+	Time      time.Time // start of the slot
+	Model     string
+	Responses int
+	Usage
+}
+
+// ReadUsage returns the usage of the transcript file at path, as records in
+// time order. Claude Code writes a response's usage on each of its entries,
+// updated as it streams, so each message counts once, with the usage of its
+// last entry. Results come from the session index, which only reads what
+// was appended since the file was last seen.
+func ReadUsage(path string) ([]UsageRecord, error) {
+	return defaultIndex().ReadUsage(path)
+}
+
+// recentMessages is how many of a transcript's latest messages are kept
+// for their later entries to update. A message's entries are written
+// together, but subagents running in parallel can interleave theirs.
+const recentMessages = 8
+
+// usageLog accumulates the usage of a transcript, summed per slot and model.
+type usageLog struct {
+	Records []UsageRecord  `json:"records,omitempty"`
+	Recent  []usageMessage `json:"recent,omitempty"` // latest messages, oldest first
+}
+
+// usageMessage is a message whose usage is counted in a record and may
+// still be replaced by a later entry of the same message.
+type usageMessage struct {
+	ID     string `json:"id"`
+	Record int    `json:"record"` // index into Records
+	Usage  Usage  `json:"usage"`  // what was counted for it
+}
+
+// add counts an entry's usage, replacing what an earlier entry of the same
+// message counted.
+func (l *usageLog) add(e Entry) {
+	if e.Kind != EntryAssistant || e.Usage == (Usage{}) {
+		return
+	}
+	if e.MessageID != "" {
+		for i := range l.Recent {
+			if m := &l.Recent[i]; m.ID == e.MessageID {
+				r := &l.Records[m.Record]
+				r.Usage = r.Usage.sub(m.Usage).Add(e.Usage)
+				m.Usage = e.Usage
+				return
+			}
+		}
+	}
+
+	slot := e.Time.Truncate(UsageSlot)
+	i := len(l.Records) - 1
+	for ; i >= 0 && l.Records[i].Time.Equal(slot); i-- {
+		if l.Records[i].Model == e.Model {
+			break
+		}
+	}
+	if i < 0 || !l.Records[i].Time.Equal(slot) {
+		i = len(l.Records)
+		l.Records = append(l.Records, UsageRecord{Time: slot, Model: e.Model})
+	}
+	l.Records[i].Responses++
+	l.Records[i].Usage = l.Records[i].Usage.Add(e.Usage)
+
+	l.Recent = append(l.Recent, usageMessage{ID: e.MessageID, Record: i, Usage: e.Usage})
+	if n := len(l.Recent); n > recentMessages {
+		l.Recent = append([]usageMessage(nil), l.Recent[n-recentMessages:]...)
+	}
+}
+
+// Pricer returns the price of a model, or false if it has none. It is
+// config.Config.PriceFor.
+type Pricer func(model string) (config.Price, bool)
+
+// UsageTotal sums token usage and its estimated cost.
+type UsageTotal struct {
+	Usage
+	Responses int
+	Cost      float64 // US dollars
+	Unpriced  int     // responses from models without a price, not in Cost
+}
+
+// Add counts a record's responses.
+func (t *UsageTotal) Add(r UsageRecord, price Pricer) {
+	t.Usage = t.Usage.Add(r.Usage)
+	t.Responses += r.Responses
+	p, ok := price(r.Model)
+	if !ok {
+		t.Unpriced += r.Responses
+		return
+	}
+	t.Cost += p.Cost(r.InputTokens, r.OutputTokens, r.CacheCreationTokens, r.CacheReadTokens)
+}
+
+// Merge adds another total to t.
+func (t *UsageTotal) Merge(o UsageTotal) {
+	t.Usage = t.Usage.Add(o.Usage)
+	t.Responses += o.Responses
+	t.Cost += o.Cost
+	t.Unpriced += o.Unpriced
+}
+
+// Tokens returns every token counted, cache reads and writes included.
+func (u Usage) Tokens() int {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationTokens + u.CacheReadTokens
+}
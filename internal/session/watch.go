@@ -0,0 +1,219 @@
+package session
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchPollInterval is how often project dirs are rescanned when they can't
+// be watched (no inotify, or the dir doesn't exist yet).
+const watchPollInterval = 2 * time.Second
+
+// watchSettle coalesces the burst of writes Claude makes while streaming a
+// response into one scan.
+const watchSettle = 100 * time.Millisecond
+
+// WatchEventKind identifies what happened to a session file.
+type WatchEventKind int
+
+const (
+	SessionCreated WatchEventKind = iota
+	SessionUpdated
+)
+
+func (k WatchEventKind) String() string {
+	switch k {
+	case SessionCreated:
+		return "created"
+	case SessionUpdated:
+		return "updated"
+	}
+	return "unknown"
+}
+
+// WatchEvent reports a session file that appeared or was written to in the
+// project dir of a watched worktree. The session's cwd is not checked:
+// ListSessions tells whether it belongs to the worktree.
+type WatchEvent struct {
+	Kind     WatchEventKind
+	Worktree string // watched worktree path
+	ID       string // session ID
+	Path     string // the session file
+	ModTime  time.Time
+}
+
+// fileStamp is what a scan remembers of a session file.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// Watcher reports new sessions and new turns in the Claude project dirs of
+// a set of worktrees. It uses inotify where available and falls back to
+// rescanning the dirs every few seconds.
+type Watcher struct {
+	interval time.Duration
+	noNotify bool                 // poll even if inotify works (tests)
+	dirs     map[string][]string  // project dir → worktree paths
+	files    map[string]fileStamp // session file → last seen
+	events   chan WatchEvent
+}
+
+// NewWatcher returns a watcher over the given worktrees. Sessions already on
+// disk are the baseline: only changes after this call are reported.
+func NewWatcher(worktrees []string) *Watcher {
+	w := &Watcher{
+		interval: watchPollInterval,
+		dirs:     make(map[string][]string),
+		files:    make(map[string]fileStamp),
+		events:   make(chan WatchEvent, 64),
+	}
+	for _, wt := range worktrees {
+		dir := ProjectDir(wt)
+		w.dirs[dir] = append(w.dirs[dir], wt)
+	}
+	for dir := range w.dirs {
+		w.scan(dir)
+	}
+	return w
+}
+
+// Events returns the channel events are delivered on. It is closed when Run
+// returns.
+func (w *Watcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+// Run watches until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) {
+	defer close(w.events)
+
+	var (
+		n       notifier
+		changes <-chan string
+	)
+	if !w.noNotify {
+		if nn, err := newNotifier(); err == nil {
+			n, changes = nn, nn.changes()
+			defer n.close()
+		}
+	}
+	// Watch what exists now; the rest is polled until it appears
+	w.poll(ctx, n)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	pending := make(map[string]bool)
+	var settle <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case dir, ok := <-changes:
+			if !ok {
+				// The notifier failed; poll from now on
+				n, changes = nil, nil
+				continue
+			}
+			if _, ok := w.dirs[dir]; ok {
+				pending[dir] = true
+				if settle == nil {
+					settle = time.After(watchSettle)
+				}
+			}
+		case <-settle:
+			settle = nil
+			for dir := range pending {
+				if !w.send(ctx, w.scan(dir)) {
+					return
+				}
+			}
+			clear(pending)
+		case <-ticker.C:
+			if !w.poll(ctx, n) {
+				return
+			}
+		}
+	}
+}
+
+// poll scans every dir the notifier isn't watching, adding a watch first
+// where it can. Returns false if ctx was cancelled.
+func (w *Watcher) poll(ctx context.Context, n notifier) bool {
+	for dir := range w.dirs {
+		if n != nil {
+			if n.watching(dir) {
+				continue
+			}
+			n.add(dir) // fails while the dir doesn't exist
+		}
+		if !w.send(ctx, w.scan(dir)) {
+			return false
+		}
+	}
+	return true
+}
+
+// send delivers events, giving up if ctx is cancelled.
+func (w *Watcher) send(ctx context.Context, events []WatchEvent) bool {
+	for _, ev := range events {
+		select {
+		case w.events <- ev:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// scan compares a project dir's session files with the last scan.
+func (w *Watcher) scan(dir string) []WatchEvent {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var events []WatchEvent
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		stamp := fileStamp{size: info.Size(), modTime: info.ModTime()}
+		prev, seen := w.files[path]
+		if seen && prev == stamp {
+			continue
+		}
+		w.files[path] = stamp
+
+		kind := SessionUpdated
+		if !seen {
+			kind = SessionCreated
+		}
+		for _, wt := range w.dirs[dir] {
+			events = append(events, WatchEvent{
+				Kind:     kind,
+				Worktree: wt,
+				ID:       strings.TrimSuffix(e.Name(), ".jsonl"),
+				Path:     path,
+				ModTime:  info.ModTime(),
+			})
+		}
+	}
+	return events
+}
+
+// notifier reports directories whose contents changed.
+type notifier interface {
+	add(dir string) error
+	watching(dir string) bool
+	changes() <-chan string // closed if the notifier fails
+	close() error
+}
@@ -0,0 +1,114 @@
+//go:build linux
+
+package session
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotify is the Linux notifier. Each watched dir reports file creation,
+// writes and renames into it.
+type inotify struct {
+	fd   int
+	file *os.File // fd, read through the runtime poller so close unblocks it
+	ch   chan string
+	done chan struct{}
+
+	mu   sync.Mutex
+	dirs map[int32]string // watch descriptor → dir
+}
+
+const inotifyMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_MOVED_TO
+
+func newNotifier() (notifier, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	n := &inotify{
+		fd:   fd,
+		file: os.NewFile(uintptr(fd), "inotify"),
+		ch:   make(chan string, 16),
+		done: make(chan struct{}),
+		dirs: make(map[int32]string),
+	}
+	go n.read()
+	return n, nil
+}
+
+func (n *inotify) add(dir string) error {
+	wd, err := unix.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	n.mu.Lock()
+	n.dirs[int32(wd)] = dir
+	n.mu.Unlock()
+	return nil
+}
+
+func (n *inotify) watching(dir string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, d := range n.dirs {
+		if d == dir {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *inotify) changes() <-chan string { return n.ch }
+
+func (n *inotify) close() error {
+	close(n.done)
+	return n.file.Close()
+}
+
+// read turns inotify events into changed dirs until the file is closed.
+func (n *inotify) read() {
+	defer close(n.ch)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		size, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+		var changed []string
+		for off := 0; off+unix.SizeofInotifyEvent <= size; {
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			off += unix.SizeofInotifyEvent + nameLen
+
+			n.mu.Lock()
+			switch {
+			case mask&unix.IN_Q_OVERFLOW != 0:
+				// Events were lost: everything may have changed
+				for _, dir := range n.dirs {
+					changed = append(changed, dir)
+				}
+			case mask&unix.IN_IGNORED != 0:
+				// The dir was removed; it is polled until it is back
+				changed = append(changed, n.dirs[wd])
+				delete(n.dirs, wd)
+			default:
+				if dir, ok := n.dirs[wd]; ok {
+					changed = append(changed, dir)
+				}
+			}
+			n.mu.Unlock()
+		}
+		for _, dir := range changed {
+			select {
+			case n.ch <- dir:
+			case <-n.done:
+				return
+			}
+		}
+	}
+}
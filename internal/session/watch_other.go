@@ -0,0 +1,10 @@
+//go:build !linux
+
+package session
+
+import "errors"
+
+// newNotifier is only implemented on Linux; elsewhere the watcher polls.
+func newNotifier() (notifier, error) {
+	return nil, errors.New("no directory notifications on this platform")
+}
@@ -0,0 +1,101 @@
+package session
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nextEvent waits for the watcher's next event.
+func nextEvent(t *testing.T, w *Watcher) WatchEvent {
+	t.Helper()
+	select {
+	case ev := <-w.Events():
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watch event")
+		return WatchEvent{}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		name := "notify"
+		if poll {
+			name = "poll"
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			wt := "/work/watched"
+			dir := ProjectDir(wt)
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			old := filepath.Join(dir, "old.jsonl")
+			if err := os.WriteFile(old, []byte("{}\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			w := NewWatcher([]string{wt})
+			w.noNotify = poll
+			w.interval = time.Hour // only inotify can report in time
+			if poll {
+				w.interval = 50 * time.Millisecond
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				w.Run(ctx)
+				close(done)
+			}()
+
+			path := filepath.Join(dir, "new.jsonl")
+			if err := os.WriteFile(path, []byte("{}\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			ev := nextEvent(t, w)
+			if ev.Kind != SessionCreated || ev.ID != "new" || ev.Worktree != wt || ev.Path != path {
+				t.Errorf("event = %+v, want new session created", ev)
+			}
+
+			f, err := os.OpenFile(old, os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteString("{}\n")
+			f.Close()
+			if ev := nextEvent(t, w); ev.Kind != SessionUpdated || ev.ID != "old" {
+				t.Errorf("event = %+v, want old session updated", ev)
+			}
+
+			cancel()
+			<-done
+			if _, ok := <-w.Events(); ok {
+				t.Error("events still open after Run returned")
+			}
+		})
+	}
+}
+
+func TestWatcher_DirAppears(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	wt := "/work/later"
+	w := NewWatcher([]string{wt})
+	w.interval = 50 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	dir := ProjectDir(wt)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "first.jsonl"), []byte("{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, w); ev.Kind != SessionCreated || ev.ID != "first" {
+		t.Errorf("event = %+v, want first session created", ev)
+	}
+}
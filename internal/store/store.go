@@ -0,0 +1,248 @@
+// Package store persists per-worktree agent state across parkranger runs:
+// the last known status, the Claude session bound to each tmux window, and a
+// bounded history of status transitions. State is a single JSON file under
+// the XDG state directory.
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/grins/parkranger/internal/atomicfile"
+	"github.com/grins/parkranger/internal/session"
+	"github.com/grins/parkranger/internal/xdg"
+)
+
+// maxTransitions bounds the history kept per worktree.
+const maxTransitions = 100
+
+// Transition records a change of agent status.
+type Transition struct {
+	From session.AgentStatus `json:"from"`
+	To   session.AgentStatus `json:"to"`
+	At   time.Time           `json:"at"`
+}
+
+// Record is the persisted state of one worktree, keyed by worktree path.
+type Record struct {
+	Repo        string              `json:"repo"`
+	Worktree    string              `json:"worktree"`
+	Status      session.AgentStatus `json:"status"`
+	StatusSince time.Time           `json:"status_since,omitzero"`
+	LastSeen    time.Time           `json:"last_seen,omitzero"`   // last poll that found the window
+	Window      string              `json:"window,omitempty"`     // tmux target the session is bound to
+	SessionID   string              `json:"session_id,omitempty"` // Claude session running in Window
+	BoundAt     time.Time           `json:"bound_at,omitzero"`
+	Transitions []Transition        `json:"transitions,omitempty"`
+}
+
+// Store is the in-memory view of the state file. Safe for concurrent use.
+//
+// Several processes (dashboard, daemon, CLI) may hold the same file open, so
+// Save merges with what is on disk instead of overwriting it: only records
+// this process changed are written, and the newest session binding wins.
+type Store struct {
+	mu        sync.Mutex
+	path      string
+	records   map[string]*Record
+	changed   map[string]bool // records modified since the last Save
+	forgotten map[string]bool // records removed since the last Save
+}
+
+// file is the on-disk layout.
+type file struct {
+	Worktrees map[string]*Record `json:"worktrees"`
+}
+
+// DefaultPath returns the state file location: <state dir>/state.json.
+func DefaultPath() string {
+	return filepath.Join(xdg.StateDir(), "state.json")
+}
+
+// Open loads the store at path. A missing file yields an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:      path,
+		changed:   make(map[string]bool),
+		forgotten: make(map[string]bool),
+	}
+
+	records, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s.records = records
+	return s, nil
+}
+
+// readFile loads the records in the state file. A missing file yields none.
+func readFile(path string) (map[string]*Record, error) {
+	records := make(map[string]*Record)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return records, nil
+		}
+		return nil, err
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for k, r := range f.Worktrees {
+		if r != nil {
+			records[k] = r
+		}
+	}
+	return records, nil
+}
+
+// Get returns a copy of the record for the worktree at path.
+func (s *Store) Get(path string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[path]
+	if !ok {
+		return Record{}, false
+	}
+	return r.clone(), true
+}
+
+// record returns the record for path, creating it if needed. Caller holds mu.
+func (s *Store) record(path, repo, wt string) *Record {
+	r, ok := s.records[path]
+	if !ok {
+		r = &Record{}
+		s.records[path] = r
+	}
+	if repo != "" {
+		r.Repo = repo
+	}
+	if wt != "" {
+		r.Worktree = wt
+	}
+	return r
+}
+
+// RecordStatus stores the status observed for a live window at time at,
+// appending a transition when it differs from the last known status.
+// Returns true if the status changed.
+func (s *Store) RecordStatus(path, repo, wt string, status session.AgentStatus, at time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.record(path, repo, wt)
+	r.LastSeen = at
+	s.changed[path] = true
+
+	if r.Status == status && !r.StatusSince.IsZero() {
+		return false
+	}
+
+	r.Transitions = append(r.Transitions, Transition{From: r.Status, To: status, At: at})
+	if n := len(r.Transitions); n > maxTransitions {
+		r.Transitions = append([]Transition(nil), r.Transitions[n-maxTransitions:]...)
+	}
+	r.Status = status
+	r.StatusSince = at
+	return true
+}
+
+// StatusSince returns when the worktree at path entered status, if that is
+// its recorded status.
+func (s *Store) StatusSince(path string, status session.AgentStatus) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[path]
+	if !ok || r.Status != status || r.StatusSince.IsZero() {
+		return time.Time{}, false
+	}
+	return r.StatusSince, true
+}
+
+// BindSession records that the Claude session sessionID runs in the tmux
+// window target of the worktree at path.
+func (s *Store) BindSession(path, repo, wt, window, sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.record(path, repo, wt)
+	r.Window = window
+	r.SessionID = sessionID
+	r.BoundAt = time.Now()
+	s.changed[path] = true
+	delete(s.forgotten, path)
+}
+
+// Forget drops the record for a worktree (e.g. after it is deleted).
+func (s *Store) Forget(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, path)
+	delete(s.changed, path)
+	s.forgotten[path] = true
+}
+
+// Save merges this process's changes into the state file, if there are any,
+// and refreshes unchanged records from disk. The daemon and CLI commands
+// save concurrently, so the merge runs under a lock on state.json.lock, and
+// the file is written to a temp file and renamed so a crash never truncates
+// it.
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.changed) == 0 && len(s.forgotten) == 0 {
+		return nil
+	}
+
+	unlock, err := atomicfile.Lock(s.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	merged, err := readFile(s.path)
+	if err != nil {
+		return err
+	}
+	for path := range s.forgotten {
+		delete(merged, path)
+	}
+	for path := range s.changed {
+		mine := s.records[path]
+		if disk, ok := merged[path]; ok && disk.BoundAt.After(mine.BoundAt) {
+			// Another process bound a session more recently — keep its binding.
+			mine.Window, mine.SessionID, mine.BoundAt = disk.Window, disk.SessionID, disk.BoundAt
+		}
+		merged[path] = mine
+	}
+
+	data, err := json.MarshalIndent(file{Worktrees: merged}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.Write(s.path, append(data, '\n')); err != nil {
+		return err
+	}
+	s.records = merged
+	s.changed = make(map[string]bool)
+	s.forgotten = make(map[string]bool)
+	return nil
+}
+
+// clone returns a deep copy so callers can't mutate shared history.
+func (r *Record) clone() Record {
+	c := *r
+	c.Transitions = append([]Transition(nil), r.Transitions...)
+	return c
+}
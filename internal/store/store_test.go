@@ -0,0 +1,180 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grins/parkranger/internal/session"
+)
+
+func TestOpenMissing(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Get("/wt/a"); ok {
+		t.Error("expected no record in empty store")
+	}
+}
+
+func TestRecordStatus(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "state.json"))
+	t0 := time.Unix(1000, 0)
+
+	if !s.RecordStatus("/wt/a", "repo", "a", session.StatusBusy, t0) {
+		t.Error("first status should count as a change")
+	}
+	if s.RecordStatus("/wt/a", "repo", "a", session.StatusBusy, t0.Add(time.Second)) {
+		t.Error("same status should not count as a change")
+	}
+	if !s.RecordStatus("/wt/a", "repo", "a", session.StatusWaiting, t0.Add(2*time.Second)) {
+		t.Error("new status should count as a change")
+	}
+
+	r, ok := s.Get("/wt/a")
+	if !ok {
+		t.Fatal("record missing")
+	}
+	if r.Status != session.StatusWaiting || !r.StatusSince.Equal(t0.Add(2*time.Second)) {
+		t.Errorf("record = %+v", r)
+	}
+	if !r.LastSeen.Equal(t0.Add(2 * time.Second)) {
+		t.Errorf("LastSeen = %v", r.LastSeen)
+	}
+	if len(r.Transitions) != 2 {
+		t.Fatalf("transitions = %+v", r.Transitions)
+	}
+	if r.Transitions[1].From != session.StatusBusy || r.Transitions[1].To != session.StatusWaiting {
+		t.Errorf("transition = %+v", r.Transitions[1])
+	}
+}
+
+func TestTransitionsBounded(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "state.json"))
+	t0 := time.Unix(1000, 0)
+	for i := 0; i < maxTransitions+10; i++ {
+		status := session.StatusBusy
+		if i%2 == 0 {
+			status = session.StatusIdle
+		}
+		s.RecordStatus("/wt/a", "repo", "a", status, t0.Add(time.Duration(i)*time.Second))
+	}
+	r, _ := s.Get("/wt/a")
+	if len(r.Transitions) != maxTransitions {
+		t.Errorf("got %d transitions, want %d", len(r.Transitions), maxTransitions)
+	}
+}
+
+func TestSaveReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	s, _ := Open(path)
+	t0 := time.Unix(1000, 0).UTC()
+
+	s.RecordStatus("/wt/a", "repo", "a", session.StatusWaiting, t0)
+	s.BindSession("/wt/a", "repo", "a", "pr-repo:a", "abc-123")
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := loaded.Get("/wt/a")
+	if !ok {
+		t.Fatal("record missing after reload")
+	}
+	if r.Status != session.StatusWaiting || r.SessionID != "abc-123" || r.Window != "pr-repo:a" {
+		t.Errorf("reloaded = %+v", r)
+	}
+	if r.Repo != "repo" || r.Worktree != "a" || !r.StatusSince.Equal(t0) {
+		t.Errorf("reloaded = %+v", r)
+	}
+
+	loaded.Forget("/wt/a")
+	if _, ok := loaded.Get("/wt/a"); ok {
+		t.Error("record survived Forget")
+	}
+}
+
+func TestGetReturnsCopy(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "state.json"))
+	s.RecordStatus("/wt/a", "repo", "a", session.StatusBusy, time.Unix(1000, 0))
+
+	r, _ := s.Get("/wt/a")
+	r.Transitions[0].To = session.StatusIdle
+
+	again, _ := s.Get("/wt/a")
+	if again.Transitions[0].To != session.StatusBusy {
+		t.Error("mutating a returned record changed the store")
+	}
+}
+
+func TestSaveMergesConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	daemon, _ := Open(path)
+	cli, _ := Open(path)
+	t0 := time.Unix(1000, 0)
+
+	// The CLI binds a session; the daemon, which loaded before the bind,
+	// keeps recording status for the same and another worktree.
+	cli.BindSession("/wt/a", "repo", "a", "pr-repo:a", "abc-123")
+	if err := cli.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	daemon.RecordStatus("/wt/a", "repo", "a", session.StatusBusy, t0)
+	daemon.RecordStatus("/wt/b", "repo", "b", session.StatusIdle, t0)
+	if err := daemon.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, _ := Open(path)
+	a, _ := loaded.Get("/wt/a")
+	if a.SessionID != "abc-123" || a.Status != session.StatusBusy {
+		t.Errorf("merged a = %+v", a)
+	}
+	if b, ok := loaded.Get("/wt/b"); !ok || b.Status != session.StatusIdle {
+		t.Errorf("merged b = %+v, %v", b, ok)
+	}
+
+	// Forget in one process removes the record on disk.
+	cli.Forget("/wt/b")
+	if err := cli.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _ = Open(path)
+	if _, ok := loaded.Get("/wt/b"); ok {
+		t.Error("forgotten record still on disk")
+	}
+}
+
+func TestSaveConcurrentProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	const n = 10
+	var wg sync.WaitGroup
+	for i := range n {
+		// One store per writer, as in separate processes
+		s, _ := Open(path)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wt := fmt.Sprintf("/wt/%d", i)
+			s.BindSession(wt, "repo", wt, "pr-repo:"+wt, "s")
+			if err := s.Save(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	loaded, _ := Open(path)
+	for i := range n {
+		if _, ok := loaded.Get(fmt.Sprintf("/wt/%d", i)); !ok {
+			t.Errorf("binding %d lost", i)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package tmux
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrClosed is returned by Client.Run once the control connection is gone,
+// including for commands cut off mid-way, which may have run.
+var ErrClosed = errors.New("tmux control connection closed")
+
+// ErrUnsupported is returned by Dial when the tmux version can't attach a
+// control client without resizing the session's windows.
+var ErrUnsupported = errors.New("tmux control mode needs tmux 3.2 or newer")
+
+// errNewline is returned by Client.Run for arguments with line breaks, which
+// would end the command line early.
+var errNewline = errors.New("control mode can't send a newline")
+
+// EventKind identifies a control-mode notification.
+type EventKind int
+
+const (
+	// EventOutput: a pane in the client's session produced output.
+	EventOutput EventKind = iota
+	// EventWindowAdd: a window was created, in any session.
+	EventWindowAdd
+	// EventWindowClose: a window was closed, in any session.
+	EventWindowClose
+	// EventClientDetached: another client detached from the server.
+	EventClientDetached
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventOutput:
+		return "output"
+	case EventWindowAdd:
+		return "window-add"
+	case EventWindowClose:
+		return "window-close"
+	case EventClientDetached:
+		return "client-detached"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a notification from a control-mode client.
+type Event struct {
+	Kind   EventKind
+	Pane   string // %id, for EventOutput
+	Window string // @id, for window events
+	Client string // client name, for EventClientDetached
+	Output string // what the pane wrote, for EventOutput
+}
+
+// eventBuffer is how many notifications a Client queues for a slow reader
+// before dropping them.
+const eventBuffer = 256
+
+// Client is a tmux control-mode connection (tmux -C). Commands are sent over
+// it one line each and answered in order, so any number of goroutines can
+// share one tmux process instead of forking one per command. It also reports
+// output and window changes as Events.
+//
+// tmux only sends output for panes in the session the client is attached
+// to; window additions and closes arrive for every session. Like any client,
+// it counts towards the session's attached clients.
+type Client struct {
+	session string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stderr  bytes.Buffer
+
+	mu      sync.Mutex
+	pending []chan reply // waiting for the answer to each command sent, in order
+	closed  bool
+	err     error // why the connection closed
+
+	events chan Event
+	done   chan struct{}
+}
+
+type reply struct {
+	out string
+	err error
+}
+
+// Dial starts a control-mode client attached to session. The client does not
+// affect window sizes, which needs tmux 3.2 or newer: older versions get
+// ErrUnsupported without an attempt. Close it when done.
+func Dial(session string) (*Client, error) {
+	if !controlSupported() {
+		return nil, ErrUnsupported
+	}
+	c := &Client{
+		session: session,
+		cmd:     exec.Command("tmux", "-C", "attach-session", "-f", "ignore-size", "-t", session),
+		events:  make(chan Event, eventBuffer),
+		done:    make(chan struct{}),
+	}
+	c.cmd.Stderr = &c.stderr
+	stdin, err := c.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := c.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("tmux -C: %w", err)
+	}
+	c.stdin = stdin
+	go c.read(stdout)
+
+	// A round trip confirms the attach worked
+	if _, err := c.Run("display-message", "-p", ""); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("tmux control mode on %s: %w", session, err)
+	}
+	return c, nil
+}
+
+// Session returns the session the client is attached to.
+func (c *Client) Session() string {
+	return c.session
+}
+
+// Events returns the client's notifications. The channel is closed when the
+// connection ends. Notifications are dropped while the buffer is full.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Done is closed when the connection ends.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Run sends a tmux command and returns its output. Arguments are quoted, so
+// they reach the command as given; they may not contain newlines.
+func (c *Client) Run(args ...string) (string, error) {
+	line, err := commandLine(args)
+	if err != nil {
+		return "", fmt.Errorf("tmux %s: %w", strings.Join(args, " "), err)
+	}
+	ch := make(chan reply, 1)
+
+	// Sending and queueing under one lock keeps pending in command order
+	c.mu.Lock()
+	if c.closed {
+		err := c.err
+		c.mu.Unlock()
+		return "", err
+	}
+	if _, err := io.WriteString(c.stdin, line+"\n"); err != nil {
+		c.mu.Unlock()
+		return "", fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	c.pending = append(c.pending, ch)
+	c.mu.Unlock()
+
+	r := <-ch
+	if r.err != nil && !errors.Is(r.err, ErrClosed) {
+		return "", fmt.Errorf("tmux %s: %w", strings.Join(args, " "), r.err)
+	}
+	return r.out, r.err
+}
+
+// Close detaches the client and waits for tmux to exit.
+func (c *Client) Close() error {
+	c.stdin.Close() // tmux exits at the end of its input
+	<-c.done
+	return nil
+}
+
+// read parses everything tmux writes: %begin/%end blocks answering commands,
+// and notifications between them.
+func (c *Client) read(r io.Reader) {
+	br := bufio.NewReader(r)
+	var (
+		block    []string
+		number   string // command number of the open block; "" outside blocks
+		ours     bool   // the open block answers a command we sent
+		attachEr string // error from the attach itself
+	)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		if number != "" {
+			if failed, ok := blockEnd(line, number); ok {
+				out := strings.Join(block, "\n")
+				switch {
+				case ours:
+					c.answer(out, failed)
+				case failed:
+					attachEr = out
+				}
+				block, number = nil, ""
+				continue
+			}
+			block = append(block, line)
+			continue
+		}
+
+		if f := strings.Fields(line); len(f) == 4 && f[0] == "%begin" {
+			number, ours = f[2], f[3] == "1"
+			continue
+		}
+		if line == "%exit" || strings.HasPrefix(line, "%exit ") {
+			break
+		}
+		if ev, ok := parseNotification(line); ok {
+			select {
+			case c.events <- ev:
+			default:
+			}
+		}
+	}
+
+	c.cmd.Wait()
+	reason := ErrClosed
+	if msg := strings.TrimSpace(attachEr + " " + c.stderr.String()); msg != "" {
+		reason = fmt.Errorf("%w: %s", ErrClosed, msg)
+	}
+	c.mu.Lock()
+	c.closed, c.err = true, reason
+	for _, ch := range c.pending {
+		ch <- reply{err: fmt.Errorf("before tmux answered: %w", reason)}
+	}
+	c.pending = nil
+	c.mu.Unlock()
+	close(c.events)
+	close(c.done)
+}
+
+// answer hands a finished block to the oldest waiting command.
+func (c *Client) answer(out string, failed bool) {
+	c.mu.Lock()
+	if len(c.pending) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	ch := c.pending[0]
+	c.pending = c.pending[1:]
+	c.mu.Unlock()
+
+	if failed {
+		ch <- reply{err: errors.New(out)}
+		return
+	}
+	ch <- reply{out: out}
+}
+
+// blockEnd reports whether line closes the block with the given command
+// number, and whether it closes it with an error. Matching the number keeps
+// command output that happens to start with "%end" from ending the block.
+func blockEnd(line, number string) (failed, ok bool) {
+	f := strings.Fields(line)
+	if len(f) != 4 || f[2] != number {
+		return false, false
+	}
+	switch f[0] {
+	case "%end":
+		return false, true
+	case "%error":
+		return true, true
+	}
+	return false, false
+}
+
+// parseNotification parses the notifications Client reports. Windows in
+// other sessions are reported as "unlinked" and count the same.
+func parseNotification(line string) (Event, bool) {
+	name, rest, _ := strings.Cut(line, " ")
+	switch name {
+	case "%output":
+		pane, data, ok := strings.Cut(rest, " ")
+		if !ok {
+			return Event{}, false
+		}
+		return Event{Kind: EventOutput, Pane: pane, Output: unescapeOutput(data)}, true
+	case "%window-add", "%unlinked-window-add":
+		return Event{Kind: EventWindowAdd, Window: rest}, true
+	case "%window-close", "%unlinked-window-close":
+		return Event{Kind: EventWindowClose, Window: rest}, true
+	case "%client-detached":
+		return Event{Kind: EventClientDetached, Client: rest}, true
+	}
+	return Event{}, false
+}
+
+// unescapeOutput decodes %output data, where tmux writes control characters
+// and backslashes as three-digit octal escapes.
+func unescapeOutput(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// commandLine quotes args into a tmux command line. Single quotes keep tmux
+// from expanding anything; an embedded quote closes, escapes and reopens.
+func commandLine(args []string) (string, error) {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if strings.ContainsAny(a, "\r\n") {
+			return "", errNewline
+		}
+		quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " "), nil
+}
+
+// TakeSnapshot is the package's TakeSnapshot over the connection.
+func (c *Client) TakeSnapshot() (Snapshot, error) {
+	return takeSnapshot(c.run)
+}
+
+// CaptureBottomStyled is the package's CaptureBottomStyled over the
+// connection.
+func (c *Client) CaptureBottomStyled(target string, height, lines int) (string, error) {
+	return captureBottomStyled(c.run, target, height, lines)
+}
+
+// run runs a read-only command over the connection, forking tmux instead if
+// the connection is gone or fails mid-command: reading twice is harmless.
+func (c *Client) run(args ...string) (string, error) {
+	out, err := c.Run(args...)
+	if errors.Is(err, ErrClosed) || errors.Is(err, errNewline) {
+		return run(args...)
+	}
+	return strings.TrimSpace(out), err
+}
+
+// controlVersion matches the version in `tmux -V`: "tmux 3.3a", "tmux
+// next-3.4". Builds from git ("tmux master") have no number.
+var controlVersion = regexp.MustCompile(`(\d+)\.(\d+)`)
+
+// controlSupported reports whether the installed tmux has attach-session
+// -f ignore-size (3.2).
+func controlSupported() bool {
+	out, err := exec.Command("tmux", "-V").Output()
+	return err == nil && versionSupported(string(out))
+}
+
+// versionSupported reports whether `tmux -V` output is 3.2 or newer.
+func versionSupported(version string) bool {
+	m := controlVersion.FindStringSubmatch(version)
+	if m == nil {
+		return strings.Contains(version, "master")
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return major > 3 || major == 3 && minor >= 2
+}
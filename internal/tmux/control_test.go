@@ -0,0 +1,142 @@
+package tmux
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNotification(t *testing.T) {
+	tests := []struct {
+		line string
+		want Event
+		ok   bool
+	}{
+		{`%output %3 hello\015\012`, Event{Kind: EventOutput, Pane: "%3", Output: "hello\r\n"}, true},
+		{`%output %3 back\134slash`, Event{Kind: EventOutput, Pane: "%3", Output: `back\slash`}, true},
+		{"%window-add @4", Event{Kind: EventWindowAdd, Window: "@4"}, true},
+		{"%unlinked-window-add @5", Event{Kind: EventWindowAdd, Window: "@5"}, true},
+		{"%window-close @4", Event{Kind: EventWindowClose, Window: "@4"}, true},
+		{"%unlinked-window-close @5", Event{Kind: EventWindowClose, Window: "@5"}, true},
+		{"%client-detached /dev/pts/3", Event{Kind: EventClientDetached, Client: "/dev/pts/3"}, true},
+		{"%window-renamed @4 vim", Event{}, false},
+		{"%output", Event{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseNotification(tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseNotification(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	got, err := commandLine([]string{"display-message", "-p", "#{pane_id} it's $HOME; ~"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `'display-message' '-p' '#{pane_id} it'\''s $HOME; ~'`
+	if got != want {
+		t.Errorf("commandLine = %s, want %s", got, want)
+	}
+	if _, err := commandLine([]string{"send-keys", "a\nb"}); !errors.Is(err, errNewline) {
+		t.Errorf("newline: err = %v, want errNewline", err)
+	}
+}
+
+func TestVersionSupported(t *testing.T) {
+	tests := map[string]bool{
+		"tmux 3.3a\n":      true,
+		"tmux 3.2":         true,
+		"tmux next-3.6":    true,
+		"tmux master":      true,
+		"tmux 3.1c":        false,
+		"tmux 2.9a":        false,
+		"tmux 10.0":        true,
+		"tmux openbsd-7.5": true,
+	}
+	for version, want := range tests {
+		if got := versionSupported(version); got != want {
+			t.Errorf("versionSupported(%q) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	t.Setenv("TMUX", "")
+	t.Cleanup(func() { exec.Command("tmux", "kill-server").Run() })
+
+	if _, err := Dial("pr-test"); err == nil {
+		t.Fatal("Dial to a missing session succeeded")
+	}
+
+	dir := t.TempDir()
+	if err := CreateSession("pr-test", dir); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Dial("pr-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := c.Run("display-message", "-p", "it's #{session_name}"); err != nil || out != "it's pr-test" {
+		t.Errorf("Run = %q, %v", out, err)
+	}
+	if _, err := c.Run("no-such-command"); err == nil {
+		t.Error("unknown command: no error")
+	}
+
+	// Output and windows show up as events
+	pane, err := NewWindow("pr-test", "feat", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !WindowExists("pr-test", "feat") {
+		t.Fatal("WindowExists = false after NewWindow")
+	}
+	if err := exec.Command("tmux", "respawn-pane", "-k", "-t", pane, "echo marker-1234; sleep 5").Run(); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err := c.TakeSnapshot(); err != nil || len(snap.Sessions) != 1 || len(snap.Sessions[0].Windows) != 2 {
+		t.Errorf("TakeSnapshot = %+v, %v", snap, err)
+	}
+	var added, output bool
+	timeout := time.After(5 * time.Second)
+	for !added || !output {
+		select {
+		case ev := <-c.Events():
+			switch {
+			case ev.Kind == EventWindowAdd:
+				added = true
+			case ev.Kind == EventOutput && ev.Pane == pane && strings.Contains(ev.Output, "marker-1234"):
+				output = true
+			}
+		case <-timeout:
+			t.Fatalf("events: window added %v, output %v", added, output)
+		}
+	}
+
+	if err := KillSession("pr-test"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection still open after its session was killed")
+	}
+	if _, err := c.Run("display-message", "-p", ""); !errors.Is(err, ErrClosed) {
+		t.Errorf("Run after close: err = %v, want ErrClosed", err)
+	}
+	// Reads fall back to forking tmux
+	if err := CreateSession("pr-again", dir); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err := c.TakeSnapshot(); err != nil || len(snap.Sessions) != 1 || snap.Sessions[0].Name != "pr-again" {
+		t.Errorf("TakeSnapshot after close = %+v, %v", snap, err)
+	}
+}
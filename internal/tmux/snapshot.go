@@ -0,0 +1,152 @@
+package tmux
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Snapshot is every session, window and pane of the tmux server at one
+// moment, read with a single list-panes call. Pollers take one per cycle
+// instead of querying each window and pane on its own.
+type Snapshot struct {
+	Sessions []Session
+}
+
+// Session is a tmux session in a Snapshot.
+type Session struct {
+	ID       string // stable $id
+	Name     string
+	Activity time.Time // last activity in any of its windows
+	Windows  []Window  // in index order
+}
+
+// Window is a window in a Snapshot.
+type Window struct {
+	ID       string // stable @id
+	Index    int
+	Name     string
+	Activity time.Time // last output in any of its panes
+	Panes    []Pane    // in pane order
+}
+
+// snapshotFormat lists a pane with its window and session, one line each.
+// The title comes last: programs may put anything in it, tabs included.
+const snapshotFormat = "#{session_id}\t#{session_name}\t#{session_activity}\t" +
+	"#{window_id}\t#{window_index}\t#{window_name}\t#{window_activity}\t" +
+	"#{pane_id}\t#{pane_index}\t#{pane_pid}\t#{pane_current_command}\t#{" + AgentOption + "}\t" +
+	"#{pane_dead}\t#{pane_width}\t#{pane_height}\t#{pane_title}"
+
+const snapshotFields = 16
+
+// TakeSnapshot lists every pane of the server. No server running is an
+// empty snapshot, not an error.
+func TakeSnapshot() (Snapshot, error) {
+	return takeSnapshot(run)
+}
+
+func takeSnapshot(run runner) (Snapshot, error) {
+	out, err := run("list-panes", "-a", "-F", snapshotFormat)
+	if err != nil {
+		if noServer(err) {
+			return Snapshot{}, nil
+		}
+		return Snapshot{}, err
+	}
+	return parseSnapshot(out), nil
+}
+
+// noServer reports whether a failed command found no tmux server: the
+// socket is missing or nothing listens on it.
+func noServer(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "no server running") ||
+		strings.Contains(msg, "error connecting to") && strings.Contains(msg, "No such file or directory")
+}
+
+// parseSnapshot parses snapshotFormat lines. tmux lists panes grouped by
+// session and window, in order.
+func parseSnapshot(out string) Snapshot {
+	var snap Snapshot
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.SplitN(line, "\t", snapshotFields)
+		if len(f) != snapshotFields {
+			continue
+		}
+
+		if n := len(snap.Sessions); n == 0 || snap.Sessions[n-1].ID != f[0] {
+			snap.Sessions = append(snap.Sessions, Session{ID: f[0], Name: f[1], Activity: unixTime(f[2])})
+		}
+		s := &snap.Sessions[len(snap.Sessions)-1]
+		if n := len(s.Windows); n == 0 || s.Windows[n-1].ID != f[3] {
+			index, _ := strconv.Atoi(f[4])
+			s.Windows = append(s.Windows, Window{ID: f[3], Index: index, Name: f[5], Activity: unixTime(f[6])})
+		}
+		w := &s.Windows[len(s.Windows)-1]
+
+		index, _ := strconv.Atoi(f[8])
+		pid, _ := strconv.Atoi(f[9])
+		width, _ := strconv.Atoi(f[13])
+		height, _ := strconv.Atoi(f[14])
+		w.Panes = append(w.Panes, Pane{
+			ID:      f[7],
+			Index:   index,
+			PID:     pid,
+			Command: f[10],
+			Agent:   f[11] == "1",
+			Dead:    f[12] == "1",
+			Width:   width,
+			Height:  height,
+			Title:   f[15],
+		})
+	}
+	return snap
+}
+
+func unixTime(s string) time.Time {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// Session returns the session with the given name.
+func (s Snapshot) Session(name string) (Session, bool) {
+	for _, sess := range s.Sessions {
+		if sess.Name == name {
+			return sess, true
+		}
+	}
+	return Session{}, false
+}
+
+// Window returns the first window of session with the given name. Names are
+// matched exactly, unlike a "session:window" target, which tmux also
+// resolves by prefix.
+func (s Snapshot) Window(session, window string) (Window, bool) {
+	sess, ok := s.Session(session)
+	if !ok {
+		return Window{}, false
+	}
+	for _, w := range sess.Windows {
+		if w.Name == window {
+			return w, true
+		}
+	}
+	return Window{}, false
+}
+
+// Pane returns the pane with the given %id.
+func (s Snapshot) Pane(id string) (Pane, bool) {
+	for _, sess := range s.Sessions {
+		for _, w := range sess.Windows {
+			for _, p := range w.Panes {
+				if p.ID == id {
+					return p, true
+				}
+			}
+		}
+	}
+	return Pane{}, false
+}
@@ -50,10 +50,21 @@ func PaneTarget(repo, worktree string, pane int) string {
 // SessionExists returns true if the named tmux session exists.
 // Returns false (not an error) if the tmux server is not running.
 func SessionExists(name string) bool {
-	err := exec.Command("tmux", "has-session", "-t", name).Run()
+	_, err := run("has-session", "-t", name)
 	return err == nil
 }
 
+// AttachedClients returns how many clients are attached to the named
+// session, 0 if it doesn't exist.
+func AttachedClients(name string) int {
+	out, err := run("display-message", "-p", "-t", name, "#{session_attached}")
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(out)
+	return n
+}
+
 // CreateSession creates a detached tmux session in the given directory.
 func CreateSession(name, workDir string) error {
 	_, err := run("new-session", "-d", "-s", name, "-c", workDir)
@@ -106,6 +117,32 @@ func CapturePane(target string) (string, error) {
 // This ensures we always read the most recent activity regardless of pane size.
 // Returns "" (not an error) if the pane or session doesn't exist.
 func CapturePaneBottom(target string, lines int) (string, error) {
+	return captureBottom(run, target, lines, false)
+}
+
+// CapturePaneBottomStyled is CapturePaneBottom with colours and attributes
+// kept as escape sequences (capture-pane -e).
+func CapturePaneBottomStyled(target string, lines int) (string, error) {
+	return captureBottom(run, target, lines, true)
+}
+
+// CaptureBottomStyled is CapturePaneBottomStyled for a pane whose height is
+// already known, e.g. from a Snapshot, which saves asking tmux for it.
+func CaptureBottomStyled(target string, height, lines int) (string, error) {
+	return captureBottomStyled(run, target, height, lines)
+}
+
+func captureBottomStyled(run runner, target string, height, lines int) (string, error) {
+	if height <= 0 {
+		return captureBottom(run, target, lines, true)
+	}
+	return captureRows(run, target, height, lines, true)
+}
+
+// runner runs one tmux command: run, or over a control connection.
+type runner func(args ...string) (string, error)
+
+func captureBottom(run runner, target string, lines int, escapes bool) (string, error) {
 	heightStr, err := run("display-message", "-p", "-t", target, "#{pane_height}")
 	if err != nil {
 		return "", nil // pane doesn't exist
@@ -114,6 +151,11 @@ func CapturePaneBottom(target string, lines int) (string, error) {
 	if height <= 0 {
 		return "", nil
 	}
+	return captureRows(run, target, height, lines, escapes)
+}
+
+// captureRows captures the bottom lines rows of a pane height rows tall.
+func captureRows(run runner, target string, height, lines int, escapes bool) (string, error) {
 
 	// Calculate start offset so we only capture the bottom `lines` rows.
 	// tmux capture-pane -S uses 0-indexed rows from the top of the visible pane.
@@ -122,7 +164,11 @@ func CapturePaneBottom(target string, lines int) (string, error) {
 		start = height - lines
 	}
 
-	out, err := run("capture-pane", "-p", "-J", "-S", strconv.Itoa(start), "-t", target)
+	args := []string{"capture-pane", "-p", "-J", "-S", strconv.Itoa(start), "-t", target}
+	if escapes {
+		args = append(args, "-e")
+	}
+	out, err := run(args...)
 	if err != nil {
 		return "", nil
 	}
@@ -135,10 +181,9 @@ func KillSession(name string) error {
 	return err
 }
 
-// EnsureSession creates the repo-level tmux session with a "dashboard" window
-// if it doesn't already exist. Returns true if a new session was created.
-func EnsureSession(repo, repoRootDir string) (bool, error) {
-	name := SessionName(repo)
+// EnsureSession creates the named session with a "dashboard" window if it
+// doesn't already exist. Returns true if a new session was created.
+func EnsureSession(name, repoRootDir string) (bool, error) {
 	if SessionExists(name) {
 		return false, nil
 	}
@@ -202,3 +247,96 @@ func AttachWindow(session, windowName string) error {
 func IsInsideTmux() bool {
 	return os.Getenv("TMUX") != ""
 }
+
+// DisplayMessage shows msg in the status line of the attached tmux clients
+// for the given duration in milliseconds.
+func DisplayMessage(msg string, durationMs int) error {
+	_, err := run("display-message", "-d", strconv.Itoa(durationMs), msg)
+	return err
+}
+
+// AgentOption is the tmux user option marking panes that run an agent.
+const AgentOption = "@parkranger_agent"
+
+// NewWindow creates a named window and returns the ID of its first pane.
+func NewWindow(session, name, workDir string) (string, error) {
+	return run("new-window", "-t", session, "-n", name, "-c", workDir, "-P", "-F", "#{pane_id}")
+}
+
+// SplitPane splits target and returns the new pane's ID. horizontal places
+// the new pane to the right (side by side), otherwise below. size is passed
+// to -l (e.g. "30%"); empty splits in half.
+func SplitPane(target, workDir string, horizontal bool, size string) (string, error) {
+	args := []string{"split-window", "-t", target, "-c", workDir, "-P", "-F", "#{pane_id}"}
+	if horizontal {
+		args = append(args, "-h")
+	} else {
+		args = append(args, "-v")
+	}
+	if size != "" {
+		args = append(args, "-l", size)
+	}
+	return run(args...)
+}
+
+// SelectPane makes target the active pane of its window.
+func SelectPane(target string) error {
+	_, err := run("select-pane", "-t", target)
+	return err
+}
+
+// TagAgentPane marks a pane as running an agent, so detection follows it
+// wherever it ends up in the window.
+func TagAgentPane(target string) error {
+	_, err := run("set-option", "-p", "-t", target, AgentOption, "1")
+	return err
+}
+
+// Pane is one pane of a window, as listed by ListPanes and TakeSnapshot.
+type Pane struct {
+	ID      string // stable %id, valid as a target for the pane's lifetime
+	Index   int    // pane_index within its window
+	PID     int    // pid of the pane's initial process (usually a shell)
+	Command string // pane_current_command: the foreground process name
+	Agent   bool   // tagged with AgentOption
+	Dead    bool   // pane_dead: the process exited and remain-on-exit kept the pane
+	Width   int
+	Height  int
+	Title   string // pane_title, set by the program via escape sequences
+}
+
+// ListPanes returns the panes of the named window in pane order. Returns nil
+// if the session or window doesn't exist. Windows are matched by exact name,
+// unlike a "session:window" target, which tmux also resolves by prefix.
+func ListPanes(session, window string) []Pane {
+	out, err := run("list-panes", "-s", "-t", session, "-F", snapshotFormat)
+	if err != nil {
+		return nil
+	}
+	w, _ := parseSnapshot(out).Window(session, window)
+	return w.Panes
+}
+
+// AgentPanes returns the IDs of the tagged agent panes in a window, in pane
+// order. Returns nil if the window doesn't exist or has no tagged panes.
+func AgentPanes(session, window string) []string {
+	var ids []string
+	for _, p := range ListPanes(session, window) {
+		if p.Agent {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids
+}
+
+// RespawnPane restarts a dead pane with command, in workDir.
+func RespawnPane(target, workDir, command string) error {
+	_, err := run("respawn-pane", "-t", target, "-c", workDir, command)
+	return err
+}
+
+// SelectWindow makes the named window the current window of its session.
+func SelectWindow(session, window string) error {
+	_, err := run("select-window", "-t", session+":"+window)
+	return err
+}
@@ -1,7 +1,11 @@
 package tmux
 
 import (
+	"os/exec"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSessionName(t *testing.T) {
@@ -82,3 +86,55 @@ func TestCapturePaneNonexistent(t *testing.T) {
 		t.Errorf("expected empty output, got %q", out)
 	}
 }
+
+func TestParseSnapshot(t *testing.T) {
+	line := func(fields ...string) string { return strings.Join(fields, "\t") + "\n" }
+	out := line("$0", "pr-app", "1700000100", "@0", "0", "dashboard", "1700000050", "%0", "0", "100", "parkranger", "", "0", "80", "24", "host") +
+		line("$0", "pr-app", "1700000100", "@1", "1", "feat-x", "1700000100", "%1", "0", "200", "nvim", "", "0", "40", "24", "main.go") +
+		line("$0", "pr-app", "1700000100", "@1", "1", "feat-x", "1700000100", "%2", "1", "300", "claude", "1", "0", "39", "24", "✳ Fix", "tests") +
+		line("$1", "pr-lib", "0", "@2", "0", "feat-x", "", "%4", "0", "500", "claude", "1", "1", "80", "24", "host")
+
+	got := parseSnapshot(out)
+	want := Snapshot{Sessions: []Session{
+		{ID: "$0", Name: "pr-app", Activity: time.Unix(1700000100, 0), Windows: []Window{
+			{ID: "@0", Index: 0, Name: "dashboard", Activity: time.Unix(1700000050, 0), Panes: []Pane{
+				{ID: "%0", PID: 100, Command: "parkranger", Width: 80, Height: 24, Title: "host"},
+			}},
+			{ID: "@1", Index: 1, Name: "feat-x", Activity: time.Unix(1700000100, 0), Panes: []Pane{
+				{ID: "%1", PID: 200, Command: "nvim", Width: 40, Height: 24, Title: "main.go"},
+				{ID: "%2", Index: 1, PID: 300, Command: "claude", Agent: true, Width: 39, Height: 24, Title: "✳ Fix\ttests"},
+			}},
+		}},
+		{ID: "$1", Name: "pr-lib", Windows: []Window{
+			{ID: "@2", Name: "feat-x", Panes: []Pane{
+				{ID: "%4", PID: 500, Command: "claude", Agent: true, Dead: true, Width: 80, Height: 24, Title: "host"},
+			}},
+		}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseSnapshot = %+v\nwant %+v", got, want)
+	}
+
+	if w, ok := got.Window("pr-app", "feat-x"); !ok || w.ID != "@1" {
+		t.Errorf("Window(pr-app, feat-x) = %+v, %v", w, ok)
+	}
+	if _, ok := got.Window("pr-app", "feat"); ok {
+		t.Error("Window matched a name prefix")
+	}
+	if p, ok := got.Pane("%4"); !ok || !p.Dead {
+		t.Errorf("Pane(%%4) = %+v, %v", p, ok)
+	}
+}
+
+func TestTakeSnapshot_NoServer(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	t.Setenv("TMUX_TMPDIR", t.TempDir())
+	t.Setenv("TMUX", "")
+
+	snap, err := TakeSnapshot()
+	if err != nil || len(snap.Sessions) != 0 {
+		t.Errorf("TakeSnapshot = %+v, %v; want empty", snap, err)
+	}
+}
@@ -93,10 +93,15 @@ func parseWorktreeList(output string) []Worktree {
 	return wts
 }
 
-// Add creates a new worktree with a new branch based on baseBranch.
+// Add creates a new worktree with a new branch based on baseBranch, at
+// DefaultPath.
 func Add(repoRoot, name, baseBranch string) (Worktree, error) {
-	wtPath := DefaultPath(repoRoot, name)
+	return AddAt(repoRoot, DefaultPath(repoRoot, name), name, baseBranch)
+}
 
+// AddAt creates a new worktree at wtPath with a new branch name based on
+// baseBranch.
+func AddAt(repoRoot, wtPath, name, baseBranch string) (Worktree, error) {
 	cmd := exec.Command("git", "worktree", "add", "-b", name, wtPath, baseBranch)
 	cmd.Dir = repoRoot
 	var stderr bytes.Buffer
@@ -0,0 +1,52 @@
+// Package xdg resolves parkranger's per-user directories following the XDG
+// base directory spec, falling back to the usual ~/.config style defaults on
+// every platform (including macOS) so paths are predictable.
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const appName = "parkranger"
+
+// home returns the user's home directory, preferring $HOME when set.
+func home() string {
+	if h, err := os.UserHomeDir(); err == nil {
+		return h
+	}
+	return os.Getenv("HOME")
+}
+
+// dir returns $<env>/parkranger if env is set to an absolute path,
+// otherwise ~/<fallback>/parkranger.
+func dir(env, fallback string) string {
+	if v := os.Getenv(env); v != "" && filepath.IsAbs(v) {
+		return filepath.Join(v, appName)
+	}
+	return filepath.Join(home(), fallback, appName)
+}
+
+// ConfigDir returns $XDG_CONFIG_HOME/parkranger (default ~/.config/parkranger).
+func ConfigDir() string {
+	return dir("XDG_CONFIG_HOME", ".config")
+}
+
+// StateDir returns $XDG_STATE_HOME/parkranger (default ~/.local/state/parkranger).
+func StateDir() string {
+	return dir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// CacheDir returns $XDG_CACHE_HOME/parkranger (default ~/.cache/parkranger).
+func CacheDir() string {
+	return dir("XDG_CACHE_HOME", ".cache")
+}
+
+// RuntimeDir returns $XDG_RUNTIME_DIR/parkranger, falling back to StateDir
+// on systems without a runtime dir (e.g. macOS).
+func RuntimeDir() string {
+	if v := os.Getenv("XDG_RUNTIME_DIR"); v != "" && filepath.IsAbs(v) {
+		return filepath.Join(v, appName)
+	}
+	return StateDir()
+}
@@ -0,0 +1,49 @@
+package xdg
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigDir_Env(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdgtest")
+	if got, want := ConfigDir(), "/tmp/xdgtest/parkranger"; got != want {
+		t.Errorf("ConfigDir = %q, want %q", got, want)
+	}
+}
+
+func TestConfigDir_Fallback(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "/home/tester")
+	if got, want := ConfigDir(), filepath.Join("/home/tester", ".config", "parkranger"); got != want {
+		t.Errorf("ConfigDir = %q, want %q", got, want)
+	}
+}
+
+func TestConfigDir_RelativeIgnored(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "relative/dir")
+	t.Setenv("HOME", "/home/tester")
+	if got, want := ConfigDir(), "/home/tester/.config/parkranger"; got != want {
+		t.Errorf("ConfigDir = %q, want %q", got, want)
+	}
+}
+
+func TestStateDir_Fallback(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("HOME", "/home/tester")
+	if got, want := StateDir(), "/home/tester/.local/state/parkranger"; got != want {
+		t.Errorf("StateDir = %q, want %q", got, want)
+	}
+}
+
+func TestCacheDir(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("HOME", "/home/tester")
+	if got, want := CacheDir(), "/home/tester/.cache/parkranger"; got != want {
+		t.Errorf("CacheDir = %q, want %q", got, want)
+	}
+	t.Setenv("XDG_CACHE_HOME", "/tmp/cache")
+	if got, want := CacheDir(), "/tmp/cache/parkranger"; got != want {
+		t.Errorf("CacheDir = %q, want %q", got, want)
+	}
+}